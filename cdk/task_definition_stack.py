@@ -222,6 +222,11 @@ class TaskDefinitionStack(Stack):
             value=mc_email_efs_folder_name + "/json"
         )
 
+        container.add_environment(
+            name='ATTACHMENTS_ROOT_PATH',
+            value=md_rest_efs_folder_name
+        )
+
         container.add_port_mappings(
             ecs.PortMapping(
                 container_port=int(service_container_port),
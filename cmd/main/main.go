@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"log"
 	"net/http"
@@ -25,6 +26,8 @@ func newAppServer() *http.Server {
 		return nil
 	}
 
+	appInstance.StartBackgroundJobs(context.Background())
+
 	return appInstance.NewServer(cfg.Server.Port)
 }
 
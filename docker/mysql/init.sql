@@ -5,21 +5,33 @@ USE mailculator;
 -- Emails table
 CREATE TABLE IF NOT EXISTS emails (
     id CHAR(36) PRIMARY KEY,
+    -- tenant of the API key that submitted the email
+    tenant_id VARCHAR(100) NOT NULL DEFAULT 'default',
     status ENUM(
+        'SCHEDULED','CANCELLED',
         'ACCEPTED','INTAKING','READY','PROCESSING',
         'SENT','FAILED','INVALID',
         'CALLING-SENT-CALLBACK','CALLING-FAILED-CALLBACK',
         'SENT-ACKNOWLEDGED','FAILED-ACKNOWLEDGED'
     ) NOT NULL,
+    -- declaration order is the claiming order of READY emails
+    priority ENUM('high','normal','bulk') NOT NULL DEFAULT 'normal',
     eml_file_path VARCHAR(500),
+    -- recipients of the message of eml_file_path, Bcc included, as {"recipients": [...]}
+    envelope_file_path VARCHAR(500),
     payload_file_path VARCHAR(500),
+    payload_hash CHAR(64),
     reason TEXT,
+    send_at TIMESTAMP NULL DEFAULT NULL,
     version INT NOT NULL DEFAULT 1,
     created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
     INDEX idx_status (status),
-    INDEX idx_status_updated (status, updated_at)
+    INDEX idx_status_updated (status, updated_at),
+    INDEX idx_status_send_at (status, send_at),
+    INDEX idx_status_priority_created (status, priority, created_at),
+    INDEX idx_tenant_status (tenant_id, status)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- Email statuses history table
@@ -34,3 +46,63 @@ CREATE TABLE IF NOT EXISTS email_statuses (
     FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- Opt-outs recorded through one-click unsubscribe links, kept when the emails are deleted. Each
+-- opt-out also adds a manual suppression of the recipient for the tenant, which intake enforces.
+CREATE TABLE IF NOT EXISTS unsubscriptions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL,
+    email_id CHAR(36) NOT NULL,
+    recipient VARCHAR(320) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_tenant_email_recipient (tenant_id, email_id, recipient),
+    INDEX idx_tenant_recipient (tenant_id, recipient)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Recipients emails must not be sent to. An empty tenant_id suppresses the address for every tenant.
+CREATE TABLE IF NOT EXISTS suppressions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    tenant_id VARCHAR(100) NOT NULL,
+    address VARCHAR(320) NOT NULL,
+    reason ENUM('bounce','complaint','manual') NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uq_tenant_address_reason (tenant_id, address, reason),
+    INDEX idx_address (address)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Producer tags of emails, searchable through GET /emails?tag=. Tags are case sensitive, so that
+-- tags differing only by case are distinct rows.
+CREATE TABLE IF NOT EXISTS email_tags (
+    email_id CHAR(36) NOT NULL,
+    tag VARCHAR(100) COLLATE utf8mb4_bin NOT NULL,
+
+    PRIMARY KEY (email_id, tag),
+    INDEX idx_tag (tag),
+    FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Producer metadata of emails, searchable through GET /emails?meta.<key>=. Keys are case sensitive,
+-- as the keys of the metadata object are.
+CREATE TABLE IF NOT EXISTS email_metadata (
+    email_id CHAR(36) NOT NULL,
+    meta_key VARCHAR(64) COLLATE utf8mb4_bin NOT NULL,
+    meta_value VARCHAR(500) NOT NULL,
+
+    PRIMARY KEY (email_id, meta_key),
+    INDEX idx_key_value (meta_key, meta_value),
+    FOREIGN KEY (email_id) REFERENCES emails(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Email templates, one row per version. Ids are scoped to the tenant owning the template.
+CREATE TABLE IF NOT EXISTS templates (
+    tenant_id VARCHAR(100) NOT NULL DEFAULT 'default',
+    id VARCHAR(100) NOT NULL,
+    version INT NOT NULL,
+    subject TEXT NOT NULL,
+    body_html MEDIUMTEXT NOT NULL,
+    body_text MEDIUMTEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    PRIMARY KEY (tenant_id, id, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -1,25 +1,53 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"net/http"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/dkim"
 	"multicarrier-email-api/internal/email"
 	"multicarrier-email-api/internal/healthcheck"
+	"multicarrier-email-api/internal/suppressions"
+	"multicarrier-email-api/internal/templates"
+	"multicarrier-email-api/internal/unsubscribe"
 )
 
 type App struct {
-	emailService *email.Service
-	db           *sql.DB
+	emailService            *email.Service
+	templateService         *templates.Service
+	suppressionService      *suppressions.Service
+	headerPolicy            *email.HeaderPolicy
+	unsubscribeService      *unsubscribe.Service
+	senderRegistry          *email.SenderRegistry
+	generatePlainText       bool
+	scheduledEmailsPromoter *email.ScheduledEmailsPromoter
+	authenticator           *auth.Authenticator
+	db                      *sql.DB
 }
 
 type configProvider interface {
 	GetMySQLDSN() string
 	GetPayloadStoragePath() string
+	GetMaxAttachmentSizeBytes() int64
+	GetMaxTotalAttachmentsSizeBytes() int64
+	GetAttachmentAllowedRoots() []string
+	GetDeniedHeaderNames() []string
+	GetAllowedHeaderPrefixes() []string
+	GetUnsubscribeBaseURL() string
+	GetUnsubscribeSigningKey() string
+	GetSenderIdentities() []email.SenderIdentity
+	GetDKIMKeyFiles() []dkim.KeyFile
+	GetGeneratePlainText() bool
+	GetSaveConcurrency() int
 	GetStaleEmailsThresholdMinutes() int
+	GetScheduledEmailsPromotionIntervalSeconds() int
+	GetAPIKeys() []auth.APIKey
 }
 
 func NewApp(cp configProvider) (*App, error) {
@@ -36,14 +64,71 @@ func NewApp(cp configProvider) (*App, error) {
 	payloadStorage := email.NewPayloadStorage(cp.GetPayloadStoragePath())
 	emailDB := email.NewDatabase(db, cp.GetStaleEmailsThresholdMinutes())
 
-	emailService := email.NewService(payloadStorage, emailDB)
+	suppressionService := suppressions.NewService(suppressions.NewDatabase(db))
+
+	serviceOpts := []email.ServiceOption{
+		email.WithAttachmentSizeLimits(cp.GetMaxAttachmentSizeBytes(), cp.GetMaxTotalAttachmentsSizeBytes()),
+		email.WithSuppressionList(suppressionService),
+		email.WithSaveConcurrency(cp.GetSaveConcurrency()),
+	}
+
+	if roots := cp.GetAttachmentAllowedRoots(); len(roots) > 0 {
+		attachmentValidator, err := email.NewAttachmentValidator(roots, cp.GetMaxAttachmentSizeBytes())
+		if err != nil {
+			return nil, err
+		}
+		serviceOpts = append(serviceOpts, email.WithAttachmentValidator(attachmentValidator))
+	}
+
+	if keyFiles := cp.GetDKIMKeyFiles(); len(keyFiles) > 0 {
+		keys, err := dkim.LoadKeys(keyFiles)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := dkim.NewSigner(keys)
+		if err != nil {
+			return nil, err
+		}
+		serviceOpts = append(serviceOpts, email.WithDKIMSigner(signer))
+	}
+
+	emailService := email.NewService(payloadStorage, emailDB, serviceOpts...)
+
+	templateService := templates.NewService(templates.NewDatabase(db))
+
+	var unsubscribeService *unsubscribe.Service
+	if signingKey := cp.GetUnsubscribeSigningKey(); signingKey != "" {
+		signer := unsubscribe.NewSigner([]byte(signingKey))
+		unsubscribeService = unsubscribe.NewService(signer, unsubscribe.NewDatabase(db), cp.GetUnsubscribeBaseURL())
+	}
+
+	var senderRegistry *email.SenderRegistry
+	if identities := cp.GetSenderIdentities(); len(identities) > 0 {
+		senderRegistry = email.NewSenderRegistry(identities)
+	}
+
+	promotionInterval := time.Duration(cp.GetScheduledEmailsPromotionIntervalSeconds()) * time.Second
+	scheduledEmailsPromoter := email.NewScheduledEmailsPromoter(emailDB, promotionInterval)
 
 	return &App{
-		emailService: emailService,
-		db:           db,
+		emailService:            emailService,
+		templateService:         templateService,
+		suppressionService:      suppressionService,
+		headerPolicy:            email.NewHeaderPolicy(cp.GetDeniedHeaderNames(), cp.GetAllowedHeaderPrefixes()),
+		unsubscribeService:      unsubscribeService,
+		senderRegistry:          senderRegistry,
+		generatePlainText:       cp.GetGeneratePlainText(),
+		scheduledEmailsPromoter: scheduledEmailsPromoter,
+		authenticator:           auth.NewAuthenticator(cp.GetAPIKeys()),
+		db:                      db,
 	}, nil
 }
 
+// StartBackgroundJobs runs the periodic jobs of the app until the context is done
+func (a *App) StartBackgroundJobs(ctx context.Context) {
+	go a.scheduledEmailsPromoter.Run(ctx)
+}
+
 func (a *App) Close() error {
 	if a.db != nil {
 		return a.db.Close()
@@ -54,8 +139,29 @@ func (a *App) Close() error {
 func (a *App) NewServer(port int) *http.Server {
 	mux := http.NewServeMux()
 
-	createEmail := email.NewCreateEmailHandler(a.emailService)
+	createEmailOpts := []email.CreateEmailHandlerOption{
+		email.WithTemplateRenderer(a.templateService),
+		email.WithHeaderPolicy(a.headerPolicy),
+	}
+
+	if a.unsubscribeService != nil {
+		createEmailOpts = append(createEmailOpts, email.WithUnsubscribeLinks(a.unsubscribeService))
+
+		unsubscribeHandler := unsubscribe.NewUnsubscribeHandler(a.unsubscribeService)
+		mux.Handle("POST /unsubscribe/{token}", unsubscribeHandler)
+	}
+
+	if a.senderRegistry != nil {
+		createEmailOpts = append(createEmailOpts, email.WithSenderRegistry(a.senderRegistry))
+	}
+
+	if a.generatePlainText {
+		createEmailOpts = append(createEmailOpts, email.WithPlainTextGeneration())
+	}
+
+	createEmail := email.NewCreateEmailHandler(a.emailService, createEmailOpts...)
 	mux.Handle("POST /emails", createEmail)
+	mux.HandleFunc("POST /emails/raw", createEmail.ServeRaw)
 
 	getStaleEmails := email.NewGetStaleEmailsHandler(a.emailService)
 	mux.Handle("GET /stale-emails", getStaleEmails)
@@ -63,14 +169,59 @@ func (a *App) NewServer(port int) *http.Server {
 	getInvalidEmails := email.NewGetInvalidEmailsHandler(a.emailService)
 	mux.Handle("GET /invalid-emails", getInvalidEmails)
 
+	searchEmails := email.NewSearchEmailsHandler(a.emailService)
+	mux.Handle("GET /emails", searchEmails)
+
 	requeueEmail := email.NewRequeueEmailHandler(a.emailService)
 	mux.Handle("POST /emails/{id}/requeue", requeueEmail)
 
+	previewEmail := email.NewPreviewEmailHandler(a.emailService)
+	mux.Handle("GET /emails/{id}/preview.eml", previewEmail)
+
+	rescheduleEmail := email.NewRescheduleEmailHandler(a.emailService)
+	mux.Handle("PUT /emails/{id}/schedule", rescheduleEmail)
+
+	cancelScheduledEmail := email.NewCancelScheduledEmailHandler(a.emailService)
+	mux.Handle("DELETE /emails/{id}/schedule", cancelScheduledEmail)
+
+	createSuppression := suppressions.NewCreateSuppressionHandler(a.suppressionService)
+	mux.Handle("POST /suppressions", createSuppression)
+
+	listSuppressions := suppressions.NewListSuppressionsHandler(a.suppressionService)
+	mux.Handle("GET /suppressions", listSuppressions)
+
+	deleteSuppression := suppressions.NewDeleteSuppressionHandler(a.suppressionService)
+	mux.Handle("DELETE /suppressions/{address}", deleteSuppression)
+
+	importSuppressions := suppressions.NewImportSuppressionsHandler(a.suppressionService)
+	mux.Handle("POST /suppressions/import", importSuppressions)
+
+	exportSuppressions := suppressions.NewExportSuppressionsHandler(a.suppressionService)
+	mux.Handle("GET /suppressions/export", exportSuppressions)
+
+	createTemplate := templates.NewCreateTemplateHandler(a.templateService)
+	mux.Handle("POST /templates", createTemplate)
+
+	listTemplates := templates.NewListTemplatesHandler(a.templateService)
+	mux.Handle("GET /templates", listTemplates)
+
+	getTemplate := templates.NewGetTemplateHandler(a.templateService)
+	mux.Handle("GET /templates/{id}", getTemplate)
+
+	updateTemplate := templates.NewUpdateTemplateHandler(a.templateService)
+	mux.Handle("PUT /templates/{id}", updateTemplate)
+
+	deleteTemplate := templates.NewDeleteTemplateHandler(a.templateService)
+	mux.Handle("DELETE /templates/{id}", deleteTemplate)
+
+	listTemplateVersions := templates.NewListTemplateVersionsHandler(a.templateService)
+	mux.Handle("GET /templates/{id}/versions", listTemplateVersions)
+
 	health := new(healthcheck.Handler)
 	mux.Handle("GET /health-check", health)
 
 	return &http.Server{
 		Addr:    fmt.Sprintf(":%d", port),
-		Handler: mux,
+		Handler: a.authenticator.Middleware(mux, "/health-check", "/unsubscribe/"),
 	}
 }
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strings"
+
+	"multicarrier-email-api/internal/response"
+)
+
+// APIKey grants the bearer of Key access to the emails of Tenant, or of every tenant for super admins
+type APIKey struct {
+	Key        string
+	Tenant     string
+	SuperAdmin bool
+}
+
+// Authenticator maps the bearer token of requests to a Principal. Keys are looked up by their
+// SHA-256, so that lookups do not depend on how much of a key matches.
+type Authenticator struct {
+	principals map[[sha256.Size]byte]Principal
+}
+
+// NewAuthenticator builds an authenticator from the configured keys. Without keys, every caller
+// is the default tenant, as single tenant deployments do not need credentials.
+func NewAuthenticator(keys []APIKey) *Authenticator {
+	a := &Authenticator{principals: make(map[[sha256.Size]byte]Principal, len(keys))}
+
+	for _, key := range keys {
+		a.principals[sha256.Sum256([]byte(key.Key))] = Principal{
+			Tenant:     key.Tenant,
+			SuperAdmin: key.SuperAdmin,
+		}
+	}
+
+	return a
+}
+
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, bool) {
+	if len(a.principals) == 0 {
+		return Principal{Tenant: DefaultTenant}, true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, false
+	}
+
+	principal, ok := a.principals[sha256.Sum256([]byte(token))]
+	return principal, ok
+}
+
+// Middleware stores the principal of authenticated requests in their context and rejects the
+// others with 401. Paths ending with a slash exempt the whole subtree.
+func (a *Authenticator) Middleware(next http.Handler, exemptPaths ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, path := range exemptPaths {
+			if r.URL.Path == path || (strings.HasSuffix(path, "/") && strings.HasPrefix(r.URL.Path, path)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		principal, ok := a.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			response.WriteError(http.StatusUnauthorized, w, "missing or invalid API key")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), principal)))
+	})
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	t.Parallel()
+
+	keys := []APIKey{
+		{Key: "acme-key", Tenant: "acme"},
+		{Key: "ops-key", Tenant: "ops", SuperAdmin: true},
+	}
+
+	type caseStruct struct {
+		name               string
+		keys               []APIKey
+		path               string
+		authorization      string
+		expectedStatusCode int
+		expectedPrincipal  *Principal
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "tenant key",
+			keys:               keys,
+			path:               "/emails",
+			authorization:      "Bearer acme-key",
+			expectedStatusCode: http.StatusOK,
+			expectedPrincipal:  &Principal{Tenant: "acme"},
+		},
+		{
+			name:               "super admin key",
+			keys:               keys,
+			path:               "/stale-emails",
+			authorization:      "Bearer ops-key",
+			expectedStatusCode: http.StatusOK,
+			expectedPrincipal:  &Principal{Tenant: "ops", SuperAdmin: true},
+		},
+		{
+			name:               "unknown key",
+			keys:               keys,
+			path:               "/emails",
+			authorization:      "Bearer acme-key-2",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "missing key",
+			keys:               keys,
+			path:               "/emails",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "not a bearer token",
+			keys:               keys,
+			path:               "/emails",
+			authorization:      "Basic YWNtZS1rZXk6",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "exempt path",
+			keys:               keys,
+			path:               "/health-check",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "exempt subtree",
+			keys:               keys,
+			path:               "/public/anything",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "no keys configured",
+			path:               "/emails",
+			expectedStatusCode: http.StatusOK,
+			expectedPrincipal:  &Principal{Tenant: DefaultTenant},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var principal *Principal
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if p, ok := FromContext(r.Context()); ok {
+					principal = &p
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			request := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			response := httptest.NewRecorder()
+
+			sut := NewAuthenticator(tc.keys).Middleware(next, "/health-check", "/public/")
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.Equal(t, tc.expectedPrincipal, principal)
+			if tc.expectedStatusCode == http.StatusUnauthorized {
+				assert.JSONEq(t, `{"error": "missing or invalid API key"}`, response.Body.String())
+			}
+		})
+	}
+}
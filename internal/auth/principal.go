@@ -0,0 +1,37 @@
+package auth
+
+import "context"
+
+// DefaultTenant owns the emails of deployments without API keys, and the rows created before tenants existed
+const DefaultTenant = "default"
+
+// Principal is the authenticated caller. Tenants only see and operate on their own emails, super
+// admins operate across tenants.
+type Principal struct {
+	Tenant     string
+	SuperAdmin bool
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+func FromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(contextKey{}).(Principal)
+	return principal, ok
+}
+
+// TenantOf returns the tenant of the caller found in the context, or DefaultTenant when there is none
+func TenantOf(ctx context.Context) string {
+	if principal, ok := FromContext(ctx); ok && principal.Tenant != "" {
+		return principal.Tenant
+	}
+	return DefaultTenant
+}
+
+// NewSystemContext is used by background jobs, which operate on the emails of every tenant
+func NewSystemContext(ctx context.Context) context.Context {
+	return NewContext(ctx, Principal{SuperAdmin: true})
+}
@@ -5,10 +5,15 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 
 	"github.com/go-playground/validator/v10"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/dkim"
+	"multicarrier-email-api/internal/email"
 )
 
 type MySQLConfig struct {
@@ -21,11 +26,85 @@ type MySQLConfig struct {
 }
 
 type PayloadStorageConfig struct {
-	Path string `yaml:"path" validate:"required"`
+	Path                         string `yaml:"path" validate:"required"`
+	MaxAttachmentSizeBytes       int64  `yaml:"max-attachment-size-bytes" validate:"gte=0"`
+	MaxTotalAttachmentsSizeBytes int64  `yaml:"max-total-attachments-size-bytes" validate:"gte=0"`
+}
+
+// AttachmentsConfig lists the directories attachment paths may point into. Without allowed roots,
+// attachment paths are not checked at intake.
+type AttachmentsConfig struct {
+	AllowedRoots []string `yaml:"allowed-roots"`
+}
+
+// CustomHeadersConfig restricts the custom headers producers may set. Denied names are denied on
+// top of the structural headers listed by email.DefaultDeniedHeaders. Without allowed prefixes,
+// any other valid name is accepted.
+type CustomHeadersConfig struct {
+	DeniedNames     []string `yaml:"denied-names"`
+	AllowedPrefixes []string `yaml:"allowed-prefixes"`
+}
+
+// UnsubscribeConfig enables the one-click unsubscribe links served by POST /unsubscribe/{token},
+// which are signed with the signing key. Links are not served without a signing key.
+type UnsubscribeConfig struct {
+	BaseURL    string `yaml:"base-url" validate:"required_with=SigningKey,omitempty,http_url"`
+	SigningKey string `yaml:"signing-key"`
+}
+
+// SenderIdentityConfig is a sender address, or a whole domain, verified for SPF and DKIM. The
+// reply-to is used for emails of the identity without reply_to. Without sender identities, any from
+// address is accepted.
+type SenderIdentityConfig struct {
+	Address string `yaml:"address" validate:"required_without=Domain,excluded_with=Domain,omitempty,email"`
+	Domain  string `yaml:"domain" validate:"required_without=Address,omitempty,fqdn"`
+	ReplyTo string `yaml:"reply-to" validate:"omitempty,email"`
+	Tenant  string `yaml:"tenant"`
+}
+
+// DKIMKeyConfig is a DKIM signing key of a sender domain, published in DNS under its selector.
+// Keys are rotated by adding a key with a new selector, which signs from its active-from time.
+type DKIMKeyConfig struct {
+	Domain         string    `yaml:"domain" validate:"required,fqdn"`
+	Selector       string    `yaml:"selector" validate:"required"`
+	PrivateKeyPath string    `yaml:"private-key-path" validate:"required"`
+	ActiveFrom     time.Time `yaml:"active-from"`
+}
+
+// DKIMConfig lists the keys signing rendered messages. Messages from domains without keys are not
+// signed, leaving signing to the carrier.
+type DKIMConfig struct {
+	Keys []DKIMKeyConfig `yaml:"keys" validate:"dive"`
+}
+
+// PlainTextConfig enables the generation of body_text from body_html for emails without body_text.
+// Emails can still request or refuse the generation with generate_text.
+type PlainTextConfig struct {
+	GenerateFromHTML bool `yaml:"generate-from-html"`
+}
+
+// APIKeyConfig grants a key access to the emails of a tenant, or of every tenant for super admins
+type APIKeyConfig struct {
+	Key        string `yaml:"key"`
+	Tenant     string `yaml:"tenant" validate:"required"`
+	SuperAdmin bool   `yaml:"super-admin"`
+}
+
+// AuthConfig lists the API keys accepted by the server. Without API keys, requests are not
+// authenticated and every email belongs to the default tenant.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig `yaml:"api-keys" validate:"dive"`
+}
+
+// IntakeConfig tunes the saving of batches. Save concurrency is the number of emails of a batch saved
+// at the same time, each using a database connection. Zero uses the default of the service.
+type IntakeConfig struct {
+	SaveConcurrency int `yaml:"save-concurrency" validate:"gte=0"`
 }
 
 type OutboxConfig struct {
-	StaleEmailsThresholdMinutes int `yaml:"stale-emails-threshold-minutes" validate:"required"`
+	StaleEmailsThresholdMinutes             int `yaml:"stale-emails-threshold-minutes" validate:"required"`
+	ScheduledEmailsPromotionIntervalSeconds int `yaml:"scheduled-emails-promotion-interval-seconds" validate:"required,gt=0"`
 }
 
 type ServerConfig struct {
@@ -33,14 +112,44 @@ type ServerConfig struct {
 }
 
 type Config struct {
-	MySQL          MySQLConfig          `yaml:"mysql,flow" validate:"required"`
-	PayloadStorage PayloadStorageConfig `yaml:"payload-storage,flow" validate:"required"`
-	Outbox         OutboxConfig         `yaml:"outbox,flow" validate:"required"`
-	Server         ServerConfig         `yaml:"server,flow" validate:"required"`
+	MySQL            MySQLConfig            `yaml:"mysql,flow" validate:"required"`
+	PayloadStorage   PayloadStorageConfig   `yaml:"payload-storage,flow" validate:"required"`
+	Attachments      AttachmentsConfig      `yaml:"attachments,flow"`
+	CustomHeaders    CustomHeadersConfig    `yaml:"custom-headers,flow"`
+	Unsubscribe      UnsubscribeConfig      `yaml:"unsubscribe,flow"`
+	SenderIdentities []SenderIdentityConfig `yaml:"sender-identities" validate:"dive"`
+	DKIM             DKIMConfig             `yaml:"dkim,flow"`
+	PlainText        PlainTextConfig        `yaml:"plain-text,flow"`
+	Auth             AuthConfig             `yaml:"auth,flow"`
+	Intake           IntakeConfig           `yaml:"intake,flow"`
+	Outbox           OutboxConfig           `yaml:"outbox,flow" validate:"required"`
+	Server           ServerConfig           `yaml:"server,flow" validate:"required"`
+}
+
+// defaultScheduledEmailsPromotionIntervalSeconds is the promotion interval of configs without one
+const defaultScheduledEmailsPromotionIntervalSeconds = 30
+
+// Default attachment size limits of configs without them. A configured zero means unlimited.
+const (
+	defaultMaxAttachmentSizeBytes       = 10 << 20
+	defaultMaxTotalAttachmentsSizeBytes = 25 << 20
+)
+
+// newDefaultConfig returns the values of optional keys missing from the yaml content
+func newDefaultConfig() *Config {
+	return &Config{
+		PayloadStorage: PayloadStorageConfig{
+			MaxAttachmentSizeBytes:       defaultMaxAttachmentSizeBytes,
+			MaxTotalAttachmentsSizeBytes: defaultMaxTotalAttachmentsSizeBytes,
+		},
+		Outbox: OutboxConfig{
+			ScheduledEmailsPromotionIntervalSeconds: defaultScheduledEmailsPromotionIntervalSeconds,
+		},
+	}
 }
 
 func NewFromYamlContent(yamlContent []byte) (*Config, error) {
-	cfg := &Config{}
+	cfg := newDefaultConfig()
 	yamlString := os.ExpandEnv(string(yamlContent))
 	reader := strings.NewReader(yamlString)
 
@@ -87,10 +196,84 @@ func (c *Config) GetPayloadStoragePath() string {
 	return c.PayloadStorage.Path
 }
 
+func (c *Config) GetMaxAttachmentSizeBytes() int64 {
+	return c.PayloadStorage.MaxAttachmentSizeBytes
+}
+
+func (c *Config) GetMaxTotalAttachmentsSizeBytes() int64 {
+	return c.PayloadStorage.MaxTotalAttachmentsSizeBytes
+}
+
+// GetAttachmentAllowedRoots skips empty entries, such as roots taken from unset environment variables
+func (c *Config) GetAttachmentAllowedRoots() []string {
+	var roots []string
+	for _, root := range c.Attachments.AllowedRoots {
+		if root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
+}
+
+func (c *Config) GetDeniedHeaderNames() []string {
+	return c.CustomHeaders.DeniedNames
+}
+
+func (c *Config) GetAllowedHeaderPrefixes() []string {
+	return c.CustomHeaders.AllowedPrefixes
+}
+
+func (c *Config) GetUnsubscribeBaseURL() string {
+	return c.Unsubscribe.BaseURL
+}
+
+func (c *Config) GetUnsubscribeSigningKey() string {
+	return c.Unsubscribe.SigningKey
+}
+
+func (c *Config) GetSenderIdentities() []email.SenderIdentity {
+	var identities []email.SenderIdentity
+	for _, i := range c.SenderIdentities {
+		identities = append(identities, email.SenderIdentity{Address: i.Address, Domain: i.Domain, ReplyTo: i.ReplyTo, Tenant: i.Tenant})
+	}
+	return identities
+}
+
+func (c *Config) GetDKIMKeyFiles() []dkim.KeyFile {
+	var files []dkim.KeyFile
+	for _, k := range c.DKIM.Keys {
+		files = append(files, dkim.KeyFile{Domain: k.Domain, Selector: k.Selector, Path: k.PrivateKeyPath, ActiveFrom: k.ActiveFrom})
+	}
+	return files
+}
+
+func (c *Config) GetGeneratePlainText() bool {
+	return c.PlainText.GenerateFromHTML
+}
+
+// GetAPIKeys skips entries with an empty key, such as keys taken from unset environment variables
+func (c *Config) GetAPIKeys() []auth.APIKey {
+	var keys []auth.APIKey
+	for _, k := range c.Auth.APIKeys {
+		if k.Key != "" {
+			keys = append(keys, auth.APIKey{Key: k.Key, Tenant: k.Tenant, SuperAdmin: k.SuperAdmin})
+		}
+	}
+	return keys
+}
+
+func (c *Config) GetSaveConcurrency() int {
+	return c.Intake.SaveConcurrency
+}
+
 func (c *Config) GetStaleEmailsThresholdMinutes() int {
 	return c.Outbox.StaleEmailsThresholdMinutes
 }
 
+func (c *Config) GetScheduledEmailsPromotionIntervalSeconds() int {
+	return c.Outbox.ScheduledEmailsPromotionIntervalSeconds
+}
+
 func (c *Config) GetServerPort() int {
 	return c.Server.Port
 }
@@ -5,8 +5,14 @@ import (
 	"math/rand"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/go-playground/validator/v10"
 	"github.com/stretchr/testify/assert"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/dkim"
+	"multicarrier-email-api/internal/email"
 )
 
 func getYamlContent(fileName string) ([]byte, error) {
@@ -31,6 +37,9 @@ func TestNewFromYamlContent(t *testing.T) {
 		{"Valid", "testdata/valid.yaml", false},
 		{"Invalid unknown field", "testdata/invalid-unknown-field.yaml", true},
 		{"Invalid missing fields", "testdata/invalid-missing-fields.yaml", true},
+		{"Valid without optional fields", "testdata/valid-minimal.yaml", false},
+		{"Invalid promotion interval", "testdata/invalid-promotion-interval.yaml", true},
+		{"Invalid attachment size", "testdata/invalid-attachment-size.yaml", true},
 	}
 
 	for _, c := range cases {
@@ -63,3 +72,168 @@ func TestExpandEnvVars(t *testing.T) {
 	cfg, _ := NewFromYamlContent(yamlContent)
 	assert.Equal(t, randomString, cfg.MySQL.Host)
 }
+
+func TestDefaults(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid-minimal.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+	assert.Equal(t, 30, cfg.GetScheduledEmailsPromotionIntervalSeconds())
+	assert.Equal(t, int64(10485760), cfg.GetMaxAttachmentSizeBytes())
+	assert.Equal(t, int64(26214400), cfg.GetMaxTotalAttachmentsSizeBytes())
+}
+
+func TestUnlimitedAttachmentSizes(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid-unlimited-attachments.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+	assert.Zero(t, cfg.GetMaxAttachmentSizeBytes())
+	assert.Zero(t, cfg.GetMaxTotalAttachmentsSizeBytes())
+}
+
+func TestGetAttachmentAllowedRoots(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{Attachments: AttachmentsConfig{AllowedRoots: []string{"/efs/attachments", "", "/efs/shared"}}}
+
+	assert.Equal(t, []string{"/efs/attachments", "/efs/shared"}, cfg.GetAttachmentAllowedRoots())
+	assert.Empty(t, (&Config{}).GetAttachmentAllowedRoots())
+}
+
+func TestGetAPIKeys(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{Auth: AuthConfig{APIKeys: []APIKeyConfig{
+		{Key: "tenant-a-key", Tenant: "tenant-a"},
+		{Key: "", Tenant: "tenant-b"},
+		{Key: "admin-key", Tenant: "ops", SuperAdmin: true},
+	}}}
+
+	expected := []auth.APIKey{
+		{Key: "tenant-a-key", Tenant: "tenant-a"},
+		{Key: "admin-key", Tenant: "ops", SuperAdmin: true},
+	}
+
+	assert.Equal(t, expected, cfg.GetAPIKeys())
+	assert.Empty(t, (&Config{}).GetAPIKeys())
+}
+
+func TestCustomHeadersConfig(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"From", "To", "Content-Type"}, cfg.GetDeniedHeaderNames())
+	assert.Equal(t, []string{"X-", "List-"}, cfg.GetAllowedHeaderPrefixes())
+}
+
+func TestGetSenderIdentities(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+
+	expected := []email.SenderIdentity{
+		{Domain: "example.com", ReplyTo: "support@example.com"},
+		{Address: "billing@tenant-a.com", Tenant: "tenant-a"},
+	}
+	assert.Equal(t, expected, cfg.GetSenderIdentities())
+	assert.Empty(t, (&Config{}).GetSenderIdentities())
+}
+
+func TestSenderIdentityConfig_Validation(t *testing.T) {
+	t.Parallel()
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	assert.NoError(t, validate.Struct(SenderIdentityConfig{Domain: "example.com"}))
+	assert.NoError(t, validate.Struct(SenderIdentityConfig{Address: "billing@example.com"}))
+	assert.Error(t, validate.Struct(SenderIdentityConfig{}))
+	assert.Error(t, validate.Struct(SenderIdentityConfig{Address: "billing@example.com", Domain: "example.com"}))
+	assert.Error(t, validate.Struct(SenderIdentityConfig{Domain: "not a domain"}))
+	assert.Error(t, validate.Struct(SenderIdentityConfig{Domain: "example.com", ReplyTo: "invalid"}))
+}
+
+func TestGetDKIMKeyFiles(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+
+	expected := []dkim.KeyFile{
+		{Domain: "example.com", Selector: "2024-01", Path: "/secrets/dkim/example.com/2024-01.pem"},
+		{Domain: "example.com", Selector: "2024-07", Path: "/secrets/dkim/example.com/2024-07.pem", ActiveFrom: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	assert.Equal(t, expected, cfg.GetDKIMKeyFiles())
+	assert.Empty(t, (&Config{}).GetDKIMKeyFiles())
+}
+
+func TestGetGeneratePlainText(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+
+	assert.True(t, cfg.GetGeneratePlainText())
+	assert.False(t, (&Config{}).GetGeneratePlainText())
+}
+
+func TestGetSaveConcurrency(t *testing.T) {
+	t.Parallel()
+
+	yamlContent, err := getYamlContent("testdata/valid.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewFromYamlContent(yamlContent)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 16, cfg.GetSaveConcurrency())
+	assert.Zero(t, (&Config{}).GetSaveConcurrency())
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	assert.Error(t, validate.Struct(IntakeConfig{SaveConcurrency: -1}))
+}
+
+func TestDKIMKeyConfig_Validation(t *testing.T) {
+	t.Parallel()
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	assert.NoError(t, validate.Struct(DKIMKeyConfig{Domain: "example.com", Selector: "mail", PrivateKeyPath: "/secrets/mail.pem"}))
+	assert.Error(t, validate.Struct(DKIMKeyConfig{Domain: "example.com", PrivateKeyPath: "/secrets/mail.pem"}))
+	assert.Error(t, validate.Struct(DKIMKeyConfig{Domain: "example.com", Selector: "mail"}))
+	assert.Error(t, validate.Struct(DKIMKeyConfig{Domain: "not a domain", Selector: "mail", PrivateKeyPath: "/secrets/mail.pem"}))
+}
@@ -0,0 +1,99 @@
+package dkim
+
+import (
+	"bytes"
+	"strings"
+)
+
+const crlf = "\r\n"
+
+// field is a raw header field, including its folded lines without the final CRLF
+type field struct {
+	name string
+	raw  string
+}
+
+// normalizeLineEndings turns bare LF line endings into CRLF, as they are sent over SMTP
+func normalizeLineEndings(message []byte) []byte {
+	normalized := bytes.ReplaceAll(message, []byte(crlf), []byte("\n"))
+	return bytes.ReplaceAll(normalized, []byte("\n"), []byte(crlf))
+}
+
+// splitMessage returns the header fields and the body of a message with CRLF line endings
+func splitMessage(message []byte) ([]field, []byte) {
+	header, body, found := bytes.Cut(message, []byte(crlf+crlf))
+	if !found {
+		header = bytes.TrimSuffix(message, []byte(crlf))
+	}
+
+	var fields []field
+	for _, line := range strings.Split(string(header), crlf) {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(fields) > 0 {
+			fields[len(fields)-1].raw += crlf + line
+			continue
+		}
+		name, _, _ := strings.Cut(line, ":")
+		fields = append(fields, field{name: strings.TrimRight(name, " \t"), raw: line})
+	}
+
+	return fields, body
+}
+
+// selectFields returns the fields signed by a list of header names. Each name selects the last
+// instance not selected yet, and names without instances select nothing (RFC 6376 5.4.2).
+func selectFields(fields []field, names []string) []field {
+	used := make([]bool, len(fields))
+	var selected []field
+	for _, name := range names {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if !used[i] && strings.EqualFold(fields[i].name, name) {
+				used[i] = true
+				selected = append(selected, fields[i])
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// compressWSP turns runs of spaces and tabs into a single space
+func compressWSP(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	inWSP := false
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\t' {
+			if !inWSP {
+				b.WriteByte(' ')
+			}
+			inWSP = true
+			continue
+		}
+		inWSP = false
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// relaxedHeader canonicalizes a header field with the relaxed algorithm (RFC 6376 3.4.2), without
+// the final CRLF
+func relaxedHeader(raw string) string {
+	name, value, _ := strings.Cut(raw, ":")
+	value = strings.ReplaceAll(value, crlf, "")
+	return strings.ToLower(strings.Trim(name, " \t")) + ":" + strings.Trim(compressWSP(value), " ")
+}
+
+// relaxedBody canonicalizes a body with the relaxed algorithm (RFC 6376 3.4.4)
+func relaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), crlf)
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(compressWSP(line), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, crlf) + crlf)
+}
@@ -0,0 +1,91 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Key is a signing key of a domain, published in DNS under its selector. Keys are rotated by adding
+// a key with a new selector and the time it starts signing; the previous key stops signing from
+// then, and its DNS record can be removed once messages signed with it are delivered.
+type Key struct {
+	Domain   string
+	Selector string
+	Signer   crypto.Signer
+	// ActiveFrom is the time the key starts signing, the zero time means it always signs
+	ActiveFrom time.Time
+}
+
+// KeyFile is a Key whose private key is a PEM file
+type KeyFile struct {
+	Domain     string
+	Selector   string
+	Path       string
+	ActiveFrom time.Time
+}
+
+// ParsePrivateKey parses an RSA key in PKCS #1 or PKCS #8 form, or an Ed25519 key in PKCS #8 form
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key := key.(type) {
+		case *rsa.PrivateKey:
+			return key, nil
+		case ed25519.PrivateKey:
+			return key, nil
+		default:
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+}
+
+// LoadKeys reads the private keys of key files
+func LoadKeys(files []KeyFile) ([]Key, error) {
+	keys := make([]Key, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DKIM key %s of %s: %w", f.Selector, f.Domain, err)
+		}
+
+		signer, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DKIM key %s of %s: %w", f.Selector, f.Domain, err)
+		}
+
+		keys = append(keys, Key{Domain: f.Domain, Selector: f.Selector, Signer: signer, ActiveFrom: f.ActiveFrom})
+	}
+	return keys, nil
+}
+
+// algorithm returns the signing algorithm of a key, as in the a= tag
+func algorithm(key crypto.PublicKey) (string, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return "rsa-sha256", nil
+	case ed25519.PublicKey:
+		return "ed25519-sha256", nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", key)
+	}
+}
@@ -0,0 +1,218 @@
+// Package dkim signs messages with DKIM (RFC 6376), with RSA-SHA256 or Ed25519-SHA256 (RFC 8463)
+// keys and relaxed/relaxed canonicalization.
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrNoKey = errors.New("no DKIM key for domain")
+
+// signatureLineLength is the length of the folded lines of the b= tag
+const signatureLineLength = 64
+
+// signedHeaders are the header fields signed when present. From is listed twice so that a second
+// From cannot be added without breaking the signature.
+var signedHeaders = []string{
+	"From", "From", "Reply-To", "To", "Cc", "Subject", "Date", "Message-ID", "MIME-Version",
+	"Content-Type", "Content-Transfer-Encoding", "List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+type signingKey struct {
+	Key
+	algorithm string
+}
+
+// Signer signs messages with the key of the domain of their From address
+type Signer struct {
+	keys map[string][]signingKey
+	now  func() time.Time
+}
+
+type SignerOption func(*Signer)
+
+// WithClock sets the clock used for the t= tag and to pick the active keys, time.Now by default
+func WithClock(now func() time.Time) SignerOption {
+	return func(s *Signer) {
+		s.now = now
+	}
+}
+
+func NewSigner(keys []Key, opts ...SignerOption) (*Signer, error) {
+	s := &Signer{
+		keys: make(map[string][]signingKey),
+		now:  time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, key := range keys {
+		if key.Domain == "" || key.Selector == "" || key.Signer == nil {
+			return nil, fmt.Errorf("DKIM key %q of %q needs a domain, a selector and a private key", key.Selector, key.Domain)
+		}
+
+		alg, err := algorithm(key.Signer.Public())
+		if err != nil {
+			return nil, fmt.Errorf("invalid DKIM key %s of %s: %w", key.Selector, key.Domain, err)
+		}
+
+		domain := strings.ToLower(key.Domain)
+		s.keys[domain] = append(s.keys[domain], signingKey{Key: key, algorithm: alg})
+	}
+
+	return s, nil
+}
+
+// activeKey returns the key of a domain activated last, the last configured one between keys
+// activated at the same time
+func (s *Signer) activeKey(domain string) (signingKey, bool) {
+	now := s.now()
+
+	var active signingKey
+	found := false
+	for _, key := range s.keys[domain] {
+		if key.ActiveFrom.After(now) {
+			continue
+		}
+		if !found || !key.ActiveFrom.Before(active.ActiveFrom) {
+			active = key
+			found = true
+		}
+	}
+	return active, found
+}
+
+// HasKey tells whether messages from a domain are signed, that is whether it has an active key
+func (s *Signer) HasKey(domain string) bool {
+	_, ok := s.activeKey(strings.ToLower(domain))
+	return ok
+}
+
+// PublicKey returns the public key published under the selector of a domain, as a PublicKeyLookup
+// for Verify
+func (s *Signer) PublicKey(domain string, selector string) (crypto.PublicKey, error) {
+	for _, key := range s.keys[strings.ToLower(domain)] {
+		if key.Selector == selector {
+			return key.Signer.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s._domainkey.%s", ErrNoKey, selector, domain)
+}
+
+// fromDomain returns the domain of the From address of a message
+func fromDomain(fields []field) (string, error) {
+	from := selectFields(fields, []string{"From"})
+	if len(from) == 0 {
+		return "", errors.New("message has no From header")
+	}
+
+	_, value, _ := strings.Cut(from[0].raw, ":")
+	address, err := mail.ParseAddress(strings.ReplaceAll(value, crlf, ""))
+	if err != nil {
+		return "", fmt.Errorf("invalid From header: %w", err)
+	}
+
+	at := strings.LastIndex(address.Address, "@")
+	return strings.ToLower(address.Address[at+1:]), nil
+}
+
+// headerData returns the signed data of a message: the canonicalized signed fields followed by the
+// canonicalized DKIM-Signature field with an empty b= tag
+func headerData(fields []field, names []string, signatureField string) []byte {
+	var data strings.Builder
+	for _, f := range selectFields(fields, names) {
+		data.WriteString(relaxedHeader(f.raw))
+		data.WriteString(crlf)
+	}
+	data.WriteString(relaxedHeader(signatureField))
+	return []byte(data.String())
+}
+
+// hashSigningInput returns the digest signed by a key. Ed25519 keys sign the SHA-256 digest
+// itself, as pure Ed25519 (RFC 8463).
+func hashSigningInput(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
+
+func signatureOpts(key crypto.PublicKey) crypto.SignerOpts {
+	if _, ok := key.(ed25519.PublicKey); ok {
+		return crypto.Hash(0)
+	}
+	return crypto.SHA256
+}
+
+// foldSignature splits the base64 signature in lines, whitespace within the b= tag is ignored
+func foldSignature(signature string) string {
+	var lines []string
+	for len(signature) > signatureLineLength {
+		lines = append(lines, signature[:signatureLineLength])
+		signature = signature[signatureLineLength:]
+	}
+	lines = append(lines, signature)
+	return strings.Join(lines, crlf+" ")
+}
+
+// Sign returns the message with a DKIM-Signature header prepended. Line endings are normalized to
+// CRLF first, as the message is sent. Messages from domains without an active key are not signed
+// and ErrNoKey is returned.
+func (s *Signer) Sign(message []byte) ([]byte, error) {
+	message = normalizeLineEndings(message)
+	fields, body := splitMessage(message)
+
+	domain, err := fromDomain(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := s.activeKey(domain)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoKey, domain)
+	}
+
+	var names []string
+	for _, name := range signedHeaders {
+		if slices.ContainsFunc(fields, func(f field) bool { return strings.EqualFold(f.name, name) }) {
+			names = append(names, strings.ToLower(name))
+		}
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+
+	tags := []string{
+		"v=1",
+		"a=" + key.algorithm,
+		"c=relaxed/relaxed",
+		"d=" + domain,
+		"s=" + key.Selector,
+		fmt.Sprintf("t=%d", s.now().Unix()),
+		"h=" + strings.Join(names, ":"),
+		"bh=" + base64.StdEncoding.EncodeToString(bodyHash[:]),
+		"b=",
+	}
+	signatureField := "DKIM-Signature: " + strings.Join(tags, ";"+crlf+" ")
+
+	signature, err := key.Signer.Sign(rand.Reader, hashSigningInput(headerData(fields, names, signatureField)), signatureOpts(key.Signer.Public()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign message with %s of %s: %w", key.Selector, domain, err)
+	}
+
+	signed := make([]byte, 0, len(signatureField)+len(signature)*2+len(message))
+	signed = append(signed, signatureField...)
+	signed = append(signed, foldSignature(base64.StdEncoding.EncodeToString(signature))...)
+	signed = append(signed, crlf...)
+	signed = append(signed, message...)
+
+	return signed, nil
+}
@@ -0,0 +1,291 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTime = time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+
+const testMessage = "From: \"Acme News\" <news@example.com>\r\n" +
+	"To: user@example.net\r\n" +
+	"Subject: Hello\r\n" +
+	"Date: Mon, 01 Jul 2024 12:00:00 +0000\r\n" +
+	"Message-ID: <1@example.com>\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Hello,  World!\r\n" +
+	"\r\n"
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func newTestEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func fixedClock(now time.Time) func() time.Time {
+	return func() time.Time { return now }
+}
+
+func TestSigner_Sign(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name              string
+		key               crypto.Signer
+		expectedAlgorithm string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:              "rsa",
+			key:               newTestRSAKey(t),
+			expectedAlgorithm: "rsa-sha256",
+		},
+		{
+			name:              "ed25519",
+			key:               newTestEd25519Key(t),
+			expectedAlgorithm: "ed25519-sha256",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sut, err := NewSigner([]Key{{Domain: "Example.com", Selector: "mail", Signer: tc.key}}, WithClock(fixedClock(testTime)))
+			require.NoError(t, err)
+
+			signed, err := sut.Sign([]byte(testMessage))
+			require.NoError(t, err)
+
+			assert.True(t, strings.HasSuffix(string(signed), testMessage), "the message must be left as it is")
+			signature, _, _ := strings.Cut(string(signed), "\r\nFrom:")
+			assert.Contains(t, signature, "a="+tc.expectedAlgorithm+";")
+			assert.Contains(t, signature, "d=example.com;")
+			assert.Contains(t, signature, "s=mail;")
+			assert.Contains(t, signature, "t=1719835200;")
+			assert.Contains(t, signature, "h=from:from:to:subject:date:message-id:mime-version:content-type;")
+			for _, line := range strings.Split(signature, "\r\n") {
+				assert.LessOrEqual(t, len(line), 998)
+			}
+
+			assert.NoError(t, Verify(signed, sut.PublicKey))
+		})
+	}
+}
+
+func TestSigner_Sign_RelaxedCanonicalization(t *testing.T) {
+	t.Parallel()
+
+	sut, err := NewSigner([]Key{{Domain: "example.com", Selector: "mail", Signer: newTestEd25519Key(t)}})
+	require.NoError(t, err)
+
+	signed, err := sut.Sign([]byte(testMessage))
+	require.NoError(t, err)
+
+	// changes allowed by relaxed canonicalization keep the signature valid
+	relaxed := strings.Replace(string(signed), "Subject: Hello", "subject:   Hello ", 1)
+	relaxed = strings.Replace(relaxed, "Hello,  World!\r\n\r\n", "Hello, World!   \r\n\r\n\r\n", 1)
+	relaxed = strings.Replace(relaxed, "To: user@example.net", "To:\r\n\tuser@example.net", 1)
+	assert.NoError(t, Verify([]byte(relaxed), sut.PublicKey))
+
+	// bare LF line endings are turned into CRLF, as they are sent
+	assert.NoError(t, Verify([]byte(strings.ReplaceAll(string(signed), "\r\n", "\n")), sut.PublicKey))
+
+	tampered := strings.Replace(string(signed), "Subject: Hello", "Subject: Hi", 1)
+	assert.ErrorIs(t, Verify([]byte(tampered), sut.PublicKey), ErrVerification)
+
+	tamperedBody := strings.Replace(string(signed), "World", "Moon", 1)
+	assert.ErrorIs(t, Verify([]byte(tamperedBody), sut.PublicKey), ErrVerification)
+
+	addedFrom := "From: attacker@example.org\r\n" + string(signed)
+	assert.ErrorIs(t, Verify([]byte(addedFrom), sut.PublicKey), ErrVerification)
+}
+
+func TestSigner_Sign_NormalizesLineEndings(t *testing.T) {
+	t.Parallel()
+
+	sut, err := NewSigner([]Key{{Domain: "example.com", Selector: "mail", Signer: newTestEd25519Key(t)}})
+	require.NoError(t, err)
+
+	signed, err := sut.Sign([]byte(strings.ReplaceAll(testMessage, "\r\n", "\n")))
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasSuffix(string(signed), testMessage))
+	assert.NoError(t, Verify(signed, sut.PublicKey))
+}
+
+func TestSigner_Sign_KeyRotation(t *testing.T) {
+	t.Parallel()
+
+	keys := []Key{
+		{Domain: "example.com", Selector: "2024-01", Signer: newTestEd25519Key(t)},
+		{Domain: "example.com", Selector: "2024-07", Signer: newTestEd25519Key(t), ActiveFrom: testTime},
+		{Domain: "example.com", Selector: "2025-01", Signer: newTestEd25519Key(t), ActiveFrom: testTime.AddDate(0, 6, 0)},
+	}
+
+	type caseStruct struct {
+		name             string
+		now              time.Time
+		expectedSelector string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:             "before rotation",
+			now:              testTime.Add(-time.Second),
+			expectedSelector: "2024-01",
+		},
+		{
+			name:             "after rotation",
+			now:              testTime,
+			expectedSelector: "2024-07",
+		},
+		{
+			name:             "after second rotation",
+			now:              testTime.AddDate(1, 0, 0),
+			expectedSelector: "2025-01",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sut, err := NewSigner(keys, WithClock(fixedClock(tc.now)))
+			require.NoError(t, err)
+
+			signed, err := sut.Sign([]byte(testMessage))
+			require.NoError(t, err)
+
+			assert.Contains(t, string(signed), "s="+tc.expectedSelector+";")
+			assert.NoError(t, Verify(signed, sut.PublicKey))
+		})
+	}
+}
+
+func TestSigner_Sign_NoKey(t *testing.T) {
+	t.Parallel()
+
+	sut, err := NewSigner([]Key{
+		{Domain: "example.org", Selector: "mail", Signer: newTestEd25519Key(t)},
+		{Domain: "example.com", Selector: "mail", Signer: newTestEd25519Key(t), ActiveFrom: testTime},
+	}, WithClock(fixedClock(testTime.Add(-time.Hour))))
+	require.NoError(t, err)
+
+	_, err = sut.Sign([]byte(testMessage))
+	assert.ErrorIs(t, err, ErrNoKey, "keys not active yet must not sign")
+
+	_, err = sut.Sign([]byte("To: user@example.net\r\n\r\nHello\r\n"))
+	assert.ErrorContains(t, err, "message has no From header")
+
+	assert.True(t, sut.HasKey("Example.org"))
+	assert.False(t, sut.HasKey("example.com"), "keys not active yet must not sign")
+	assert.False(t, sut.HasKey("example.net"))
+}
+
+func TestNewSigner_InvalidKeys(t *testing.T) {
+	t.Parallel()
+
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewSigner([]Key{{Domain: "example.com", Selector: "mail", Signer: ecdsaKey}})
+	assert.ErrorContains(t, err, "invalid DKIM key mail of example.com: unsupported key type *ecdsa.PublicKey")
+
+	_, err = NewSigner([]Key{{Domain: "example.com", Signer: newTestEd25519Key(t)}})
+	assert.ErrorContains(t, err, "needs a domain, a selector and a private key")
+}
+
+// TestVerify_RFC8463 verifies the Ed25519 example of RFC 8463, appendix A
+func TestVerify_RFC8463(t *testing.T) {
+	t.Parallel()
+
+	seed, err := base64.StdEncoding.DecodeString("nWGxne/9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A=")
+	require.NoError(t, err)
+	publicKey := ed25519.NewKeyFromSeed(seed).Public()
+	assert.Equal(t, "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=", base64.StdEncoding.EncodeToString(publicKey.(ed25519.PublicKey)))
+
+	message := "DKIM-Signature: v=1; a=ed25519-sha256; c=relaxed/relaxed;\r\n" +
+		" d=football.example.com; i=@football.example.com;\r\n" +
+		" q=dns/txt; s=brisbane; t=1528637909; h=from : to :\r\n" +
+		" subject : date : message-id : from : subject : date;\r\n" +
+		" bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8=;\r\n" +
+		" b=/gCrinpcQOoIfuHNQIbq4pgh9kyIK3AQUdt9OdqQehSwhEIug4D11Bus\r\n" +
+		" Fa3bT3FY5OsU7ZbnKELq+eXdp1Q1Dw==\r\n" +
+		"From: Joe SixPack <joe@football.example.com>\r\n" +
+		"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+		"Subject: Is dinner ready?\r\n" +
+		"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+		"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+		"\r\n" +
+		"Hi.\r\n" +
+		"\r\n" +
+		"We lost the game.  Are you hungry yet?\r\n" +
+		"\r\n" +
+		"Joe.\r\n"
+
+	lookup := func(domain string, selector string) (crypto.PublicKey, error) {
+		assert.Equal(t, "football.example.com", domain)
+		assert.Equal(t, "brisbane", selector)
+		return publicKey, nil
+	}
+
+	assert.NoError(t, Verify([]byte(message), lookup))
+}
+
+func TestLoadKeys(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	rsaKey := newTestRSAKey(t)
+	ed25519Key := newTestEd25519Key(t)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	require.NoError(t, err)
+
+	files := map[string][]byte{
+		"rsa.pem":     pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		"ed25519.pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		"invalid.pem": []byte("not a key"),
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0o600))
+	}
+
+	keys, err := LoadKeys([]KeyFile{
+		{Domain: "example.com", Selector: "rsa", Path: filepath.Join(dir, "rsa.pem")},
+		{Domain: "example.com", Selector: "ed25519", Path: filepath.Join(dir, "ed25519.pem"), ActiveFrom: testTime},
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.True(t, rsaKey.Equal(keys[0].Signer))
+	assert.True(t, ed25519Key.Equal(keys[1].Signer))
+	assert.Equal(t, testTime, keys[1].ActiveFrom)
+
+	_, err = LoadKeys([]KeyFile{{Domain: "example.com", Selector: "invalid", Path: filepath.Join(dir, "invalid.pem")}})
+	assert.ErrorContains(t, err, "invalid DKIM key invalid of example.com: no PEM block found")
+
+	_, err = LoadKeys([]KeyFile{{Domain: "example.com", Selector: "missing", Path: filepath.Join(dir, "missing.pem")}})
+	assert.ErrorContains(t, err, "failed to read DKIM key missing of example.com")
+}
@@ -0,0 +1,107 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var ErrVerification = errors.New("DKIM verification failed")
+
+// signatureValue matches the value of the b= tag, which is not part of the signed data
+var signatureValue = regexp.MustCompile(`([:;][ \t\r\n]*b[ \t\r\n]*=)[^;]*`)
+
+// PublicKeyLookup returns the public key published under a selector of a domain, as DNS does
+type PublicKeyLookup func(domain string, selector string) (crypto.PublicKey, error)
+
+// parseTags parses a tag list, removing the whitespace within values
+func parseTags(value string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		name, tagValue, found := strings.Cut(tag, "=")
+		if !found {
+			continue
+		}
+		tags[strings.TrimSpace(name)] = strings.Join(strings.Fields(tagValue), "")
+	}
+	return tags
+}
+
+func verificationError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrVerification, fmt.Sprintf(format, args...))
+}
+
+// Verify checks the first DKIM-Signature of a message, signed with relaxed/relaxed
+// canonicalization. It is meant for tests, and does not check the i=, l= or x= tags.
+func Verify(message []byte, lookup PublicKeyLookup) error {
+	fields, body := splitMessage(normalizeLineEndings(message))
+
+	// signatures are prepended, the first one is the signature added last
+	var signatureField string
+	for _, f := range fields {
+		if strings.EqualFold(f.name, "DKIM-Signature") {
+			signatureField = f.raw
+			break
+		}
+	}
+	if signatureField == "" {
+		return verificationError("no DKIM-Signature header")
+	}
+
+	_, value, _ := strings.Cut(signatureField, ":")
+	tags := parseTags(strings.ReplaceAll(value, crlf, ""))
+
+	if tags["v"] != "1" {
+		return verificationError("unsupported version %q", tags["v"])
+	}
+	if c := tags["c"]; c != "relaxed/relaxed" {
+		return verificationError("unsupported canonicalization %q", c)
+	}
+
+	key, err := lookup(tags["d"], tags["s"])
+	if err != nil {
+		return verificationError("no key %s of %s: %v", tags["s"], tags["d"], err)
+	}
+	alg, err := algorithm(key)
+	if err != nil {
+		return verificationError("%v", err)
+	}
+	if tags["a"] != alg {
+		return verificationError("algorithm %q does not match the key", tags["a"])
+	}
+
+	bodyHash := sha256.Sum256(relaxedBody(body))
+	expectedBodyHash, err := base64.StdEncoding.DecodeString(tags["bh"])
+	if err != nil || !bytes.Equal(bodyHash[:], expectedBodyHash) {
+		return verificationError("body hash does not match")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return verificationError("invalid signature encoding")
+	}
+
+	names := strings.Split(tags["h"], ":")
+	digest := hashSigningInput(headerData(fields, names, signatureValue.ReplaceAllString(signatureField, "$1")))
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest, signature) {
+			err = errors.New("invalid Ed25519 signature")
+		}
+	}
+	if err != nil {
+		return verificationError("signature does not match: %v", err)
+	}
+
+	return nil
+}
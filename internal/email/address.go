@@ -0,0 +1,94 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"multicarrier-email-api/internal/mailbox"
+)
+
+// Address is a mailbox with an optional display name. It can be decoded from a bare address
+// ("billing@acme.com"), an RFC 5322 name-addr ("\"Acme Billing\" <billing@acme.com>") or an
+// object with name and address properties. It is always encoded back as a single string.
+type Address struct {
+	Name    string `json:"name,omitempty"`
+	Address string `json:"address" validate:"required,email"`
+}
+
+type addressObject struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// parseAddress normalizes the given value with net/mail, converting internationalized domains to
+// punycode. Values that cannot be parsed are kept as they are, so that validation reports them as
+// invalid addresses.
+func parseAddress(value string) Address {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Address{}
+	}
+
+	parsed, err := mail.ParseAddress(value)
+	if err != nil {
+		return Address{Address: value}
+	}
+
+	return Address{Name: parsed.Name, Address: mailbox.ToASCII(parsed.Address)}
+}
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*a = parseAddress(value)
+		return nil
+	}
+
+	var object addressObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("address must be either a string or an object with name and address: %w", err)
+	}
+
+	parsed := parseAddress(object.Address)
+	*a = Address{
+		Name:    strings.TrimSpace(object.Name),
+		Address: parsed.Address,
+	}
+	return nil
+}
+
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// String returns the bare address when there is no display name, the RFC 5322 name-addr form otherwise
+func (a Address) String() string {
+	if a.Name == "" {
+		return a.Address
+	}
+	return (&mail.Address{Name: a.Name, Address: a.Address}).String()
+}
+
+// RecipientList accepts either a single address (legacy) or an array of addresses
+type RecipientList []Address
+
+func (r *RecipientList) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] != '[' {
+		var single Address
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		*r = RecipientList{single}
+		return nil
+	}
+
+	var recipients []Address
+	if err := json.Unmarshal(data, &recipients); err != nil {
+		return fmt.Errorf("recipients must be either an address or an array of addresses: %w", err)
+	}
+	*r = recipients
+	return nil
+}
@@ -0,0 +1,86 @@
+package email
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddress_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected Address
+	}{
+		{"bare address", `"billing@acme.com"`, Address{Address: "billing@acme.com"}},
+		{"angle address", `"<billing@acme.com>"`, Address{Address: "billing@acme.com"}},
+		{"name-addr", `"Acme Billing <billing@acme.com>"`, Address{Name: "Acme Billing", Address: "billing@acme.com"}},
+		{"quoted name-addr", `"\"Doe, Jane\" <jane@example.com>"`, Address{Name: "Doe, Jane", Address: "jane@example.com"}},
+		{"object", `{"name": " Acme Billing ", "address": "billing@acme.com"}`, Address{Name: "Acme Billing", Address: "billing@acme.com"}},
+		{"object without name", `{"address": "billing@acme.com"}`, Address{Address: "billing@acme.com"}},
+		{"unparsable value is kept for validation", `"not-an-email"`, Address{Address: "not-an-email"}},
+		{"internationalized domain", `"Jörg Müller <jörg@müller.de>"`, Address{Name: "Jörg Müller", Address: "jörg@xn--mller-kva.de"}},
+		{"encoded display name", `"=?utf-8?q?J=C3=B6rg_M=C3=BCller?= <joerg@example.com>"`, Address{Name: "Jörg Müller", Address: "joerg@example.com"}},
+		{"object with internationalized domain", `{"name": "Jörg", "address": "joerg@müller.de"}`, Address{Name: "Jörg", Address: "joerg@xn--mller-kva.de"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual Address
+			assert.NoError(t, json.Unmarshal([]byte(tc.input), &actual))
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestAddress_UnmarshalJSON_WrongType(t *testing.T) {
+	t.Parallel()
+
+	var actual Address
+	assert.Error(t, json.Unmarshal([]byte(`123`), &actual))
+}
+
+func TestAddress_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	bare, err := json.Marshal(Address{Address: "billing@acme.com"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `"billing@acme.com"`, string(bare))
+
+	named, err := json.Marshal(Address{Name: "Doe, Jane", Address: "jane@example.com"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `"\"Doe, Jane\" <jane@example.com>"`, string(named))
+
+	international, err := json.Marshal(Address{Name: "Jörg Müller", Address: "joerg@example.com"})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `"=?utf-8?q?J=C3=B6rg_M=C3=BCller?= <joerg@example.com>"`, string(international))
+}
+
+func TestRecipientList_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected RecipientList
+	}{
+		{"single string", `"jane@example.com"`, RecipientList{{Address: "jane@example.com"}}},
+		{"single object", `{"name": "Jane", "address": "jane@example.com"}`, RecipientList{{Name: "Jane", Address: "jane@example.com"}}},
+		{
+			"mixed array",
+			`["Jane <jane@example.com>", {"name": "John", "address": "john@example.com"}]`,
+			RecipientList{{Name: "Jane", Address: "jane@example.com"}, {Name: "John", Address: "john@example.com"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var actual RecipientList
+			assert.NoError(t, json.Unmarshal([]byte(tc.input), &actual))
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// ErrorCodeBatchAborted is returned for the emails of an atomic batch that were not saved because
+// another email of the batch failed
+const ErrorCodeBatchAborted = "BATCH_ABORTED"
+
+const ErrorMessageDuplicatedInBatch = "Email ID appears more than once in the batch"
+
+// validateBatch runs the checks of every email of an atomic batch, which must also have distinct IDs
+func (s *Service) validateBatch(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+	results := make([]SaveResult, len(emailRequests))
+	seen := make(map[string]bool, len(emailRequests))
+
+	for i, req := range emailRequests {
+		if seen[req.MessageId] {
+			results[i] = SaveResult{
+				MessageId:    req.MessageId,
+				ErrorCode:    ErrorCodeDuplicatedID,
+				ErrorMessage: ErrorMessageDuplicatedInBatch,
+			}
+			continue
+		}
+		seen[req.MessageId] = true
+
+		results[i] = s.validateOne(ctx, req)
+	}
+
+	return results
+}
+
+// abortBatch marks the successful results of a batch as aborted when another email failed, naming
+// the first failed email. It tells whether the batch was aborted.
+func abortBatch(results []SaveResult) bool {
+	failed := -1
+	for i, result := range results {
+		if !result.Success {
+			failed = i
+			break
+		}
+	}
+	if failed < 0 {
+		return false
+	}
+
+	for i, result := range results {
+		// emails accepted before the batch stay accepted
+		if !result.Success || result.AlreadyAccepted {
+			continue
+		}
+		results[i] = SaveResult{
+			MessageId:    result.MessageId,
+			ErrorCode:    ErrorCodeBatchAborted,
+			ErrorMessage: fmt.Sprintf("Batch not saved because email %s failed", results[failed].MessageId),
+		}
+	}
+
+	return true
+}
+
+// SaveAtomic saves all the emails or none of them. Every email is checked before anything is stored,
+// and the rows of the emails are inserted in a single transaction. When an email fails, the stored
+// files are removed and the other emails get BATCH_ABORTED results naming it. Emails already
+// accepted under their ID are reported as such, and are not stored again.
+func (s *Service) SaveAtomic(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+	results := s.validateBatch(ctx, emailRequests)
+	if abortBatch(results) {
+		return results
+	}
+
+	var (
+		insertParams []InsertParams
+		files        []storedFiles
+		// indexes are the request indexes of the stored emails
+		indexes []int
+	)
+	deleteAll := func() {
+		for _, f := range files {
+			s.deleteStored(f)
+		}
+	}
+
+	for i, req := range emailRequests {
+		if results[i].AlreadyAccepted {
+			continue
+		}
+
+		params, stored, err := s.store(ctx, req, payloadHash(req.PayloadBytes))
+		if err != nil {
+			deleteAll()
+			results[i] = storageFailure(req.MessageId, err)
+			abortBatch(results)
+			return results
+		}
+
+		insertParams = append(insertParams, params)
+		files = append(files, stored)
+		indexes = append(indexes, i)
+	}
+
+	if len(insertParams) == 0 {
+		return results
+	}
+
+	if err := s.db.InsertBatch(ctx, insertParams); err != nil {
+		log.Printf("failed to insert atomic batch in database: %v", err)
+
+		var batchErr *BatchInsertError
+		if !errors.As(err, &batchErr) {
+			// the commit failed, no email is to blame
+			deleteAll()
+			for _, i := range indexes {
+				results[i] = SaveResult{
+					MessageId:    results[i].MessageId,
+					ErrorCode:    ErrorCodeDatabaseError,
+					ErrorMessage: ErrorMessageDatabaseError,
+				}
+			}
+			return results
+		}
+
+		for j, f := range files {
+			if j != batchErr.Index {
+				s.deleteStored(f)
+			}
+		}
+		failed := indexes[batchErr.Index]
+		req := emailRequests[failed]
+		results[failed] = s.insertFailure(ctx, req.MessageId, insertParams[batchErr.Index].PayloadHash, files[batchErr.Index], batchErr.Err)
+		if results[failed].Success {
+			// a concurrent submission of the same payload was inserted first, which still rolled back the batch
+			results[failed] = SaveResult{
+				MessageId:    req.MessageId,
+				ErrorCode:    ErrorCodeDuplicatedID,
+				ErrorMessage: ErrorMessageDuplicatedID,
+			}
+		}
+		abortBatch(results)
+	}
+
+	return results
+}
+
+// ValidateAtomic returns the results SaveAtomic would return for the emails, without writing
+// payload files or database records
+func (s *Service) ValidateAtomic(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+	results := s.validateBatch(ctx, emailRequests)
+	abortBatch(results)
+	return results
+}
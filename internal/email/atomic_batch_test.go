@@ -0,0 +1,172 @@
+package email
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_SaveAtomic(t *testing.T) {
+	t.Parallel()
+
+	emailRequests := []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1"), Recipients: []string{"to1@example.com"}},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2"), Recipients: []string{"to2@example.com"}},
+	}
+
+	aborted := SaveResult{MessageId: "msg1", ErrorCode: ErrorCodeBatchAborted, ErrorMessage: "Batch not saved because email msg2 failed"}
+
+	type caseStruct struct {
+		name                              string
+		emailRequests                     []EmailRequest
+		opts                              []ServiceOption
+		existingHashes                    map[string]string
+		payloadStorageErrorAfterCallCount int
+		databaseErrorAfterInsertCallCount int
+		insertError                       error
+		expectedResults                   []SaveResult
+		expectedInsertedIds               []string
+		expectedStoreCount                int
+		expectedDeletedPaths              []string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:                              "all saved",
+			payloadStorageErrorAfterCallCount: 2,
+			databaseErrorAfterInsertCallCount: 2,
+			expectedResults: []SaveResult{
+				{MessageId: "msg1", Success: true},
+				{MessageId: "msg2", Success: true},
+			},
+			expectedInsertedIds: []string{"msg1", "msg2"},
+			expectedStoreCount:  2,
+		},
+		{
+			name: "duplicated ID in the batch",
+			emailRequests: []EmailRequest{
+				{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+				{MessageId: "msg2", PayloadBytes: []byte("test payload 2")},
+				{MessageId: "msg2", PayloadBytes: []byte("test payload 3")},
+			},
+			payloadStorageErrorAfterCallCount: 3,
+			databaseErrorAfterInsertCallCount: 3,
+			expectedResults: []SaveResult{
+				{MessageId: "msg1", ErrorCode: ErrorCodeBatchAborted, ErrorMessage: "Batch not saved because email msg2 failed"},
+				{MessageId: "msg2", ErrorCode: ErrorCodeBatchAborted, ErrorMessage: "Batch not saved because email msg2 failed"},
+				{MessageId: "msg2", ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessageDuplicatedInBatch},
+			},
+		},
+		{
+			name:                              "check failure",
+			opts:                              []ServiceOption{WithSuppressionList(&suppressionListMock{suppressed: map[string]bool{"to2@example.com": true}})},
+			payloadStorageErrorAfterCallCount: 2,
+			databaseErrorAfterInsertCallCount: 2,
+			expectedResults: []SaveResult{
+				aborted,
+				{MessageId: "msg2", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: to2@example.com"},
+			},
+		},
+		{
+			name:                              "storage failure",
+			payloadStorageErrorAfterCallCount: 1,
+			databaseErrorAfterInsertCallCount: 2,
+			expectedResults: []SaveResult{
+				aborted,
+				{MessageId: "msg2", ErrorCode: ErrorCodeStorageError, ErrorMessage: ErrorMessageStorageError},
+			},
+			expectedStoreCount:   2,
+			expectedDeletedPaths: []string{"payload_file"},
+		},
+		{
+			name:                              "database failure rolls back the batch",
+			payloadStorageErrorAfterCallCount: 2,
+			databaseErrorAfterInsertCallCount: 1,
+			expectedResults: []SaveResult{
+				aborted,
+				{MessageId: "msg2", ErrorCode: ErrorCodeDatabaseError, ErrorMessage: ErrorMessageDatabaseError},
+			},
+			expectedStoreCount:   2,
+			expectedDeletedPaths: []string{"payload_file", "payload_file"},
+		},
+		{
+			name:                              "concurrent duplicate rolls back the batch",
+			payloadStorageErrorAfterCallCount: 2,
+			databaseErrorAfterInsertCallCount: 1,
+			insertError:                       &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
+			expectedResults: []SaveResult{
+				aborted,
+				{MessageId: "msg2", ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessageDuplicatedID},
+			},
+			expectedStoreCount:   2,
+			expectedDeletedPaths: []string{"payload_file", "payload_file"},
+		},
+		{
+			name:                              "already accepted emails are not stored again",
+			existingHashes:                    map[string]string{"msg1": payloadHash([]byte("test payload 1"))},
+			payloadStorageErrorAfterCallCount: 2,
+			databaseErrorAfterInsertCallCount: 2,
+			expectedResults: []SaveResult{
+				{MessageId: "msg1", Success: true, AlreadyAccepted: true},
+				{MessageId: "msg2", Success: true},
+			},
+			expectedInsertedIds: []string{"msg2"},
+			expectedStoreCount:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			requests := tc.emailRequests
+			if requests == nil {
+				requests = emailRequests
+			}
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: tc.payloadStorageErrorAfterCallCount}
+			database := &databaseMock{
+				errorAfterInsertCallCount: tc.databaseErrorAfterInsertCallCount,
+				insertError:               tc.insertError,
+				existingHashes:            tc.existingHashes,
+			}
+
+			sut := NewService(payloadStorage, database, tc.opts...)
+
+			results := sut.SaveAtomic(context.TODO(), requests)
+
+			assert.Equal(t, tc.expectedResults, results)
+
+			var insertedIds []string
+			for _, params := range database.insertedParams {
+				insertedIds = append(insertedIds, params.Id)
+			}
+			assert.Equal(t, tc.expectedInsertedIds, insertedIds)
+			assert.Equal(t, tc.expectedStoreCount, payloadStorage.callCount)
+			assert.Equal(t, tc.expectedDeletedPaths, payloadStorage.deletedPaths)
+		})
+	}
+}
+
+func TestService_ValidateAtomic(t *testing.T) {
+	t.Parallel()
+
+	payloadStorage := &payloadStorageMock{errorAfterCallCount: 2}
+	database := &databaseMock{errorAfterInsertCallCount: 2, existingHashes: map[string]string{"msg2": "other"}}
+
+	sut := NewService(payloadStorage, database)
+
+	results := sut.ValidateAtomic(context.TODO(), []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2")},
+	})
+
+	assert.Equal(t, []SaveResult{
+		{MessageId: "msg1", ErrorCode: ErrorCodeBatchAborted, ErrorMessage: "Batch not saved because email msg2 failed"},
+		{MessageId: "msg2", ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessagePayloadChanged},
+	}, results)
+	assert.Zero(t, payloadStorage.callCount)
+	assert.Zero(t, database.insertCallCount)
+}
@@ -0,0 +1,158 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	errInvalidAttachmentContent = errors.New("invalid attachment content")
+	errAttachmentTooLarge       = errors.New("attachment too large")
+)
+
+// payloadAttachments decodes a payload and its attachment objects. It returns false for payloads
+// without an attachment object list, including legacy string attachments.
+func payloadAttachments(payload []byte) (map[string]json.RawMessage, []map[string]json.RawMessage, bool) {
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return nil, nil, false
+	}
+
+	var attachments []map[string]json.RawMessage
+	if err := json.Unmarshal(document["attachments"], &attachments); err != nil {
+		return nil, nil, false
+	}
+
+	return document, attachments, true
+}
+
+// validateAttachmentPaths checks the attachments referenced by path with the attachment validator,
+// when one is configured. Uploaded content is not checked, since it is written by the service.
+func (s *Service) validateAttachmentPaths(payload []byte) error {
+	if s.attachmentValidator == nil {
+		return nil
+	}
+
+	_, attachments, ok := payloadAttachments(payload)
+	if !ok {
+		return nil
+	}
+
+	for i, attachment := range attachments {
+		var path string
+		if err := json.Unmarshal(attachment["path"], &path); err != nil || path == "" {
+			continue
+		}
+		if err := s.attachmentValidator.Validate(path); err != nil {
+			return fmt.Errorf("attachments[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// uploadedAttachment is an attachment of a payload whose content was sent as content_base64
+type uploadedAttachment struct {
+	index   int
+	name    string
+	content []byte
+}
+
+// decodeAttachmentContents decodes the content_base64 attachments of a payload and checks them
+// against the size limits, where a limit of zero means unlimited
+func decodeAttachmentContents(attachments []map[string]json.RawMessage, maxSize int64, maxTotalSize int64) ([]uploadedAttachment, error) {
+	var uploads []uploadedAttachment
+	var totalSize int64
+
+	for i, attachment := range attachments {
+		rawContent, ok := attachment["content_base64"]
+		if !ok {
+			continue
+		}
+
+		var encoded, name string
+		if err := json.Unmarshal(rawContent, &encoded); err != nil {
+			return nil, fmt.Errorf("%w: attachments[%d]: %w", errInvalidAttachmentContent, i, err)
+		}
+		if err := json.Unmarshal(attachment["name"], &name); err != nil {
+			return nil, fmt.Errorf("%w: attachments[%d]: name: %w", errInvalidAttachmentContent, i, err)
+		}
+
+		content, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("%w: attachments[%d]: %w", errInvalidAttachmentContent, i, err)
+		}
+
+		size := int64(len(content))
+		if maxSize > 0 && size > maxSize {
+			return nil, fmt.Errorf("%w: attachments[%d] is %d bytes, the limit is %d", errAttachmentTooLarge, i, size, maxSize)
+		}
+
+		totalSize += size
+		if maxTotalSize > 0 && totalSize > maxTotalSize {
+			return nil, fmt.Errorf("%w: attachments exceed the total limit of %d bytes", errAttachmentTooLarge, maxTotalSize)
+		}
+
+		uploads = append(uploads, uploadedAttachment{index: i, name: name, content: content})
+	}
+
+	return uploads, nil
+}
+
+// checkAttachmentContents decodes the content_base64 attachments of a payload and checks them
+// against the size limits, without storing them
+func (s *Service) checkAttachmentContents(payload []byte) error {
+	_, attachments, ok := payloadAttachments(payload)
+	if !ok {
+		return nil
+	}
+
+	_, err := decodeAttachmentContents(attachments, s.maxAttachmentSize, s.maxTotalAttachmentsSize)
+	return err
+}
+
+// storeAttachmentContents writes the content_base64 attachments of a payload through the payload
+// storage, and returns the payload rewritten to reference the stored files by path, together with
+// the paths to clean up if the email is not saved. Payloads without uploads are returned unchanged.
+func (s *Service) storeAttachmentContents(tenant string, messageId string, payload []byte) ([]byte, []string, error) {
+	document, attachments, ok := payloadAttachments(payload)
+	if !ok {
+		return payload, nil, nil
+	}
+
+	uploads, err := decodeAttachmentContents(attachments, s.maxAttachmentSize, s.maxTotalAttachmentsSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(uploads) == 0 {
+		return payload, nil, nil
+	}
+
+	var storedPaths []string
+	for _, upload := range uploads {
+		path, err := s.payloadStorage.StoreAttachment(tenant, messageId, upload.index, upload.name, upload.content)
+		if err != nil {
+			return nil, storedPaths, err
+		}
+		storedPaths = append(storedPaths, path)
+
+		attachment := attachments[upload.index]
+		delete(attachment, "content_base64")
+		if attachment["path"], err = json.Marshal(path); err != nil {
+			return nil, storedPaths, err
+		}
+	}
+
+	if document["attachments"], err = json.Marshal(attachments); err != nil {
+		return nil, storedPaths, err
+	}
+
+	rewritten, err := json.Marshal(document)
+	if err != nil {
+		return nil, storedPaths, err
+	}
+
+	return rewritten, storedPaths, nil
+}
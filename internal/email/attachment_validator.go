@@ -0,0 +1,111 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type attachmentRoot struct {
+	path string
+	root *os.Root
+}
+
+// AttachmentValidator checks at intake that attachment paths are readable regular files within the
+// allowed root directories, so that a bad path is reported to the producer instead of making the
+// email INVALID later on. Lookups go through os.Root, which rejects symlinks escaping the root as
+// well as absolute symlinks.
+type AttachmentValidator struct {
+	roots   []attachmentRoot
+	maxSize int64
+}
+
+// NewAttachmentValidator opens the allowed roots. A maxSize of zero means unlimited.
+func NewAttachmentValidator(rootPaths []string, maxSize int64) (*AttachmentValidator, error) {
+	v := &AttachmentValidator{maxSize: maxSize}
+
+	for _, rootPath := range rootPaths {
+		absPath, err := filepath.Abs(rootPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid attachment root %s: %w", rootPath, err)
+		}
+
+		root, err := os.OpenRoot(absPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open attachment root %s: %w", rootPath, err)
+		}
+
+		v.roots = append(v.roots, attachmentRoot{path: absPath, root: root})
+	}
+
+	return v, nil
+}
+
+// rootOf returns the allowed root containing the path, or nil
+func (v *AttachmentValidator) rootOf(path string) *attachmentRoot {
+	for i, r := range v.roots {
+		rel, err := filepath.Rel(r.path, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return &v.roots[i]
+		}
+	}
+	return nil
+}
+
+// Validate checks a single attachment path. Remote URLs are fetched by the sender and are not checked.
+func (v *AttachmentValidator) Validate(path string) error {
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		if u.Scheme != "file" {
+			return nil
+		}
+		path = u.Path
+	}
+
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("attachment %s is not an absolute path", path)
+	}
+
+	for _, segment := range strings.Split(filepath.ToSlash(path), "/") {
+		if segment == ".." {
+			return fmt.Errorf("attachment %s contains a parent directory reference", path)
+		}
+	}
+
+	r := v.rootOf(filepath.Clean(path))
+	if r == nil {
+		return fmt.Errorf("attachment %s is outside of the allowed directories", path)
+	}
+
+	rel, err := filepath.Rel(r.path, filepath.Clean(path))
+	if err != nil {
+		return fmt.Errorf("attachment %s is outside of the allowed directories", path)
+	}
+
+	file, err := r.root.Open(rel)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("attachment %s does not exist", path)
+	}
+	if err != nil {
+		return fmt.Errorf("attachment %s cannot be read: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("attachment %s cannot be read: %w", path, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("attachment %s is not a regular file", path)
+	}
+
+	if v.maxSize > 0 && info.Size() > v.maxSize {
+		return fmt.Errorf("attachment %s is %d bytes, the limit is %d", path, info.Size(), v.maxSize)
+	}
+
+	return nil
+}
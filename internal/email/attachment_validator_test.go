@@ -0,0 +1,109 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachmentValidator_Validate(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "docs"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "invoice.pdf"), []byte("invoice"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "docs", "large.pdf"), make([]byte, 100), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "docs", "escape.txt")))
+	require.NoError(t, os.Symlink("invoice.pdf", filepath.Join(root, "docs", "link.pdf")))
+
+	sut, err := NewAttachmentValidator([]string{root}, 10)
+	require.NoError(t, err)
+
+	type caseStruct struct {
+		name          string
+		path          string
+		expectedError string
+	}
+
+	testCases := []caseStruct{
+		{
+			name: "regular file",
+			path: filepath.Join(root, "docs", "invoice.pdf"),
+		},
+		{
+			name: "file url",
+			path: "file://" + filepath.Join(root, "docs", "invoice.pdf"),
+		},
+		{
+			name: "symlink within the root",
+			path: filepath.Join(root, "docs", "link.pdf"),
+		},
+		{
+			name: "remote url is not checked",
+			path: "https://example.com/files/image.jpg",
+		},
+		{
+			name:          "missing file",
+			path:          filepath.Join(root, "docs", "missing.pdf"),
+			expectedError: "does not exist",
+		},
+		{
+			name:          "directory",
+			path:          filepath.Join(root, "docs"),
+			expectedError: "is not a regular file",
+		},
+		{
+			name:          "too large",
+			path:          filepath.Join(root, "docs", "large.pdf"),
+			expectedError: "is 100 bytes, the limit is 10",
+		},
+		{
+			name:          "outside of the roots",
+			path:          filepath.Join(outside, "secret.txt"),
+			expectedError: "is outside of the allowed directories",
+		},
+		{
+			name:          "parent directory traversal",
+			path:          root + "/docs/../../" + filepath.Base(outside) + "/secret.txt",
+			expectedError: "contains a parent directory reference",
+		},
+		{
+			name:          "symlink escaping the root",
+			path:          filepath.Join(root, "docs", "escape.txt"),
+			expectedError: "cannot be read",
+		},
+		{
+			name:          "relative path",
+			path:          "docs/invoice.pdf",
+			expectedError: "is not an absolute path",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := sut.Validate(tc.path)
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestNewAttachmentValidator_MissingRoot(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewAttachmentValidator([]string{filepath.Join(t.TempDir(), "missing")}, 0)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,421 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Callback is the HTTP request sent by the CALLING-SENT-CALLBACK and CALLING-FAILED-CALLBACK stages
+type Callback struct {
+	URL    string `json:"url" validate:"omitempty,http_url"`
+	Method string `json:"method,omitempty" validate:"omitempty,oneof=GET POST PUT PATCH DELETE"`
+	// Headers are sent as-is with the request
+	Headers map[string]string `json:"headers,omitempty" validate:"omitempty,dive,keys,required,printascii,excludes=:,endkeys,excludesall=\r\n"`
+	// BodyTemplate is a text/template executed with CallbackTemplateData
+	BodyTemplate string `json:"body_template,omitempty" validate:"omitempty,callback_body_template"`
+	// ignored is set for the blank legacy commands, which no request is sent for
+	ignored bool
+}
+
+// UnmarshalJSON accepts the legacy curl command strings besides the callback objects. Commands are
+// converted to the request they send, blank commands are ignored.
+func (c *Callback) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		if strings.TrimSpace(command) == "" {
+			*c = Callback{ignored: true}
+			return nil
+		}
+
+		callback, err := callbackFromCommand(command)
+		if err != nil {
+			return fmt.Errorf("invalid callback command: %w", err)
+		}
+		*c = callback
+		return nil
+	}
+
+	type callbackObject Callback
+	var object callbackObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("callback must be either a curl command or an object: %w", err)
+	}
+	*c = Callback(object)
+	return nil
+}
+
+// curlShortOptions are the long names of the supported short curl options
+var curlShortOptions = map[byte]string{
+	'X': "--request",
+	'H': "--header",
+	'd': "--data",
+	'u': "--user",
+	'A': "--user-agent",
+	'e': "--referer",
+	'G': "--get",
+	'k': "--insecure",
+	'm': "--max-time",
+	'o': "--output",
+	's': "--silent",
+	'S': "--show-error",
+	'f': "--fail",
+	'v': "--verbose",
+	'i': "--include",
+	'L': "--location",
+}
+
+// curlValueOptions are the supported curl options taking a value
+var curlValueOptions = map[string]bool{
+	"--request":         true,
+	"--header":          true,
+	"--data":            true,
+	"--data-raw":        true,
+	"--data-binary":     true,
+	"--data-ascii":      true,
+	"--json":            true,
+	"--url":             true,
+	"--user":            true,
+	"--oauth2-bearer":   true,
+	"--user-agent":      true,
+	"--referer":         true,
+	"--max-time":        true,
+	"--connect-timeout": true,
+	"--retry":           true,
+	"--output":          true,
+}
+
+// curlCommand is the request of a curl command being parsed
+type curlCommand struct {
+	callback      Callback
+	data          strings.Builder
+	hasData       bool
+	get           bool
+	json          bool
+	authorization string
+}
+
+// callbackFromCommand converts a curl command, such as curl -X POST -H 'Content-Type: application/json'
+// -d '{"ok": true}' https://example.com/hook, to the request it sends. Options which do not change
+// the request, such as -s or -L, are dropped, and the options which cannot be converted are an error.
+func callbackFromCommand(command string) (Callback, error) {
+	args, err := splitCommand(command)
+	if err != nil {
+		return Callback{}, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var c curlCommand
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		var names []string
+		value, inline := "", false
+		switch {
+		case strings.HasPrefix(arg, "--"):
+			var name string
+			name, value, inline = strings.Cut(arg, "=")
+			if inline && !curlValueOptions[name] {
+				return Callback{}, fmt.Errorf("option %s takes no value", name)
+			}
+			names = []string{name}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// short options can be grouped, as in -sSL, the first one taking a value takes the rest
+			// of the argument, as in -XPOST
+			for j := 1; j < len(arg); j++ {
+				name, ok := curlShortOptions[arg[j]]
+				if !ok {
+					return Callback{}, fmt.Errorf("unsupported option -%c", arg[j])
+				}
+				names = append(names, name)
+				if curlValueOptions[name] {
+					value, inline = arg[j+1:], j+1 < len(arg)
+					break
+				}
+			}
+		default:
+			if err := c.setURL(arg); err != nil {
+				return Callback{}, err
+			}
+			continue
+		}
+
+		for _, name := range names {
+			if curlValueOptions[name] && !inline {
+				if i+1 == len(args) {
+					return Callback{}, fmt.Errorf("option %s has no value", name)
+				}
+				i++
+				value = args[i]
+			}
+			if err := c.apply(name, value); err != nil {
+				return Callback{}, err
+			}
+		}
+	}
+
+	return c.build()
+}
+
+// apply converts a curl option to the request it changes
+func (c *curlCommand) apply(name string, value string) error {
+	switch name {
+	case "--request":
+		c.callback.Method = strings.ToUpper(value)
+	case "--header":
+		headerName, headerValue, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("header %q has no value", value)
+		}
+		c.setHeader(strings.TrimSpace(headerName), strings.TrimSpace(headerValue))
+	case "--user-agent":
+		c.setHeader("User-Agent", value)
+	case "--referer":
+		c.setHeader("Referer", value)
+	case "--data", "--data-binary", "--data-ascii", "--json":
+		if strings.HasPrefix(value, "@") {
+			return fmt.Errorf("option %s cannot read the data from a file", name)
+		}
+		if name == "--json" {
+			c.json = true
+			// curl concatenates the JSON data options
+			c.addData(value, "")
+		} else {
+			c.addData(value, "&")
+		}
+	case "--data-raw":
+		c.addData(value, "&")
+	case "--url":
+		return c.setURL(value)
+	case "--user":
+		user, password, ok := strings.Cut(value, ":")
+		if !ok {
+			return fmt.Errorf("user %q has no password", user)
+		}
+		c.authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	case "--oauth2-bearer":
+		c.authorization = "Bearer " + value
+	case "--get":
+		c.get = true
+	case "--insecure":
+		return errors.New("option --insecure is not supported, the TLS certificates are always verified")
+	case "--max-time", "--connect-timeout", "--retry", "--output",
+		"--silent", "--show-error", "--fail", "--fail-with-body", "--verbose", "--include", "--location", "--compressed":
+		// these options do not change the request
+	default:
+		return fmt.Errorf("unsupported option %s", name)
+	}
+	return nil
+}
+
+// addData appends the data of a data option, after the separator curl joins them with
+func (c *curlCommand) addData(data string, separator string) {
+	if c.hasData {
+		c.data.WriteString(separator)
+	}
+	c.data.WriteString(data)
+	c.hasData = true
+}
+
+// setHeader sets a header of the request, replacing any header with the same name
+func (c *curlCommand) setHeader(name string, value string) {
+	if c.callback.Headers == nil {
+		c.callback.Headers = make(map[string]string)
+	}
+	for existing := range c.callback.Headers {
+		if strings.EqualFold(existing, name) {
+			delete(c.callback.Headers, existing)
+		}
+	}
+	c.callback.Headers[name] = value
+}
+
+// setDefaultHeader sets a header curl generates, unless the command sets it with -H
+func (c *curlCommand) setDefaultHeader(name string, value string) {
+	for existing := range c.callback.Headers {
+		if strings.EqualFold(existing, name) {
+			return
+		}
+	}
+	c.setHeader(name, value)
+}
+
+// setURL sets the URL of the request, only one http or https URL is supported
+func (c *curlCommand) setURL(url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("unsupported argument %q, only http or https URLs are supported", url)
+	}
+	if c.callback.URL != "" {
+		return errors.New("more than one URL")
+	}
+	c.callback.URL = url
+	return nil
+}
+
+// build returns the request of the parsed command
+func (c *curlCommand) build() (Callback, error) {
+	callback := c.callback
+	if callback.URL == "" {
+		return Callback{}, errors.New("no http or https URL")
+	}
+
+	switch {
+	case c.get:
+		// -G sends the data as the query string of a GET request
+		if c.hasData {
+			separator := "?"
+			if strings.Contains(callback.URL, "?") {
+				separator = "&"
+			}
+			callback.URL += separator + c.data.String()
+		}
+		if callback.Method == "" {
+			callback.Method = http.MethodGet
+		}
+	case c.hasData:
+		// the data is sent as-is, so its actions delimiters are escaped
+		callback.BodyTemplate = strings.ReplaceAll(c.data.String(), "{{", `{{"{{"}}`)
+	case callback.Method == "":
+		// as curl does, requests without data default to GET
+		callback.Method = http.MethodGet
+	}
+
+	if c.json {
+		c.setDefaultHeader("Content-Type", "application/json")
+		c.setDefaultHeader("Accept", "application/json")
+	}
+	if c.authorization != "" {
+		c.setDefaultHeader("Authorization", c.authorization)
+	}
+	callback.Headers = c.callback.Headers
+
+	return callback, nil
+}
+
+// splitCommand splits a command line into its arguments, as a POSIX shell does for single quotes,
+// double quotes and backslashes
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			switch {
+			case r == '\n':
+				// line continuation
+			case quote == '"' && !strings.ContainsRune("\"\\$`", r):
+				arg.WriteRune('\\')
+				arg.WriteRune(r)
+			default:
+				arg.WriteRune(r)
+				inArg = true
+			}
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// CallbackTemplateData is the data available to callback body templates
+type CallbackTemplateData struct {
+	// Id of the email
+	Id string
+	// Status reached by the email, SENT or FAILED
+	Status string
+	// Reason of the failure, empty for sent emails
+	Reason string
+}
+
+const defaultCallbackMethod = http.MethodPost
+
+// withDefaults returns a copy of the callback with the default method set, or nil for ignored callbacks
+func (c *Callback) withDefaults() *Callback {
+	if c == nil || c.ignored {
+		return nil
+	}
+
+	withDefaults := *c
+	if withDefaults.Method == "" {
+		withDefaults.Method = defaultCallbackMethod
+	}
+	return &withDefaults
+}
+
+// RenderBody executes the body template with the given data
+func (c *Callback) RenderBody(w io.Writer, data CallbackTemplateData) error {
+	if c.BodyTemplate == "" {
+		return nil
+	}
+
+	tmpl, err := template.New("callback").Option("missingkey=error").Parse(c.BodyTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse callback body template: %w", err)
+	}
+
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to execute callback body template: %w", err)
+	}
+
+	return nil
+}
+
+// validateCallback requires the URL of the callbacks which are not ignored
+func validateCallback(sl validator.StructLevel) {
+	c := sl.Current().Interface().(Callback)
+
+	if !c.ignored && c.URL == "" {
+		sl.ReportError(c.URL, "URL", "url", "required", "")
+	}
+}
+
+// validateCallbackBodyTemplate checks that the template parses and only references CallbackTemplateData fields
+func validateCallbackBodyTemplate(fl validator.FieldLevel) bool {
+	callback := Callback{BodyTemplate: fl.Field().String()}
+	return callback.RenderBody(io.Discard, CallbackTemplateData{}) == nil
+}
@@ -0,0 +1,211 @@
+package email
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallback_RenderBody(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name         string
+		bodyTemplate string
+		expectedBody string
+		expectError  bool
+	}{
+		{
+			name:         "no template",
+			bodyTemplate: "",
+			expectedBody: "",
+		},
+		{
+			name:         "all fields",
+			bodyTemplate: `{"id": "{{.Id}}", "status": "{{.Status}}", "reason": {{printf "%q" .Reason}}}`,
+			expectedBody: `{"id": "65ed6bfa-063c-5219-844d-e099c88a17f4", "status": "FAILED", "reason": "mailbox full"}`,
+		},
+		{
+			name:         "unknown field",
+			bodyTemplate: `{{.InvoiceId}}`,
+			expectError:  true,
+		},
+		{
+			name:         "parse error",
+			bodyTemplate: `{{.Id`,
+			expectError:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			callback := &Callback{URL: "https://example.com", BodyTemplate: tc.bodyTemplate}
+
+			var body bytes.Buffer
+			err := callback.RenderBody(&body, CallbackTemplateData{
+				Id:     "65ed6bfa-063c-5219-844d-e099c88a17f4",
+				Status: StatusFailed,
+				Reason: "mailbox full",
+			})
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBody, body.String())
+		})
+	}
+}
+
+func TestCallback_WithDefaults(t *testing.T) {
+	t.Parallel()
+
+	var missing *Callback
+	assert.Nil(t, missing.withDefaults())
+	assert.Nil(t, (&Callback{ignored: true}).withDefaults())
+
+	callback := &Callback{URL: "https://example.com"}
+	assert.Equal(t, http.MethodPost, callback.withDefaults().Method)
+	assert.Empty(t, callback.Method, "the original callback must not be modified")
+
+	assert.Equal(t, http.MethodGet, (&Callback{URL: "https://example.com", Method: http.MethodGet}).withDefaults().Method)
+}
+
+func TestCallback_UnmarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name        string
+		json        string
+		expected    Callback
+		expectError bool
+	}{
+		{
+			name:     "object",
+			json:     `{"url": "https://example.com/hook", "method": "PUT"}`,
+			expected: Callback{URL: "https://example.com/hook", Method: http.MethodPut},
+		},
+		{
+			name:     "blank legacy command is ignored",
+			json:     `"  "`,
+			expected: Callback{ignored: true},
+		},
+		{
+			name:     "legacy command without data",
+			json:     `"curl -s https://example.com/hook?id=1"`,
+			expected: Callback{URL: "https://example.com/hook?id=1", Method: http.MethodGet},
+		},
+		{
+			name: "legacy command with method, headers and data",
+			json: `"curl -XPOST --header=\"Authorization: Bearer secret\" -H 'Content-Type: application/json' \\\n --data '{\"ok\": true}' --url https://example.com/hook"`,
+			expected: Callback{
+				URL:          "https://example.com/hook",
+				Method:       http.MethodPost,
+				Headers:      map[string]string{"Authorization": "Bearer secret", "Content-Type": "application/json"},
+				BodyTemplate: `{"ok": true}`,
+			},
+		},
+		{
+			name:     "legacy command data is not a template",
+			json:     `"curl -d 'a={{b}}' -d c=d https://example.com/hook"`,
+			expected: Callback{URL: "https://example.com/hook", BodyTemplate: `a={{"{{"}}b}}&c=d`},
+		},
+		{
+			name: "legacy command with grouped flags and basic authentication",
+			json: `"curl -sSL -u user:secret -A producer https://example.com/hook"`,
+			expected: Callback{
+				URL:     "https://example.com/hook",
+				Method:  http.MethodGet,
+				Headers: map[string]string{"Authorization": "Basic dXNlcjpzZWNyZXQ=", "User-Agent": "producer"},
+			},
+		},
+		{
+			name: "legacy command header replaces the authentication",
+			json: `"curl --oauth2-bearer token -H 'authorization: Token other' https://example.com/hook"`,
+			expected: Callback{
+				URL:     "https://example.com/hook",
+				Method:  http.MethodGet,
+				Headers: map[string]string{"authorization": "Token other"},
+			},
+		},
+		{
+			name: "legacy command with JSON data",
+			json: `"curl --json '{\"ok\": true}' https://example.com/hook"`,
+			expected: Callback{
+				URL:          "https://example.com/hook",
+				Headers:      map[string]string{"Content-Type": "application/json", "Accept": "application/json"},
+				BodyTemplate: `{"ok": true}`,
+			},
+		},
+		{
+			name:     "legacy command sending the data as query string",
+			json:     `"curl -G -d status=sent -d id=1 https://example.com/hook?source=email"`,
+			expected: Callback{URL: "https://example.com/hook?source=email&status=sent&id=1", Method: http.MethodGet},
+		},
+		{
+			name:        "legacy command with unsupported option",
+			json:        `"curl --proxy http://proxy.example.com https://example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with unsupported grouped option",
+			json:        `"curl -sk https://example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with user without password",
+			json:        `"curl -u user https://example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with data read from a file",
+			json:        `"curl -d @body.json https://example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with more than one URL",
+			json:        `"curl https://example.com/hook https://example.com/other"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with argument which is not a URL",
+			json:        `"curl example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command without URL",
+			json:        `"curl -X POST"`,
+			expectError: true,
+		},
+		{
+			name:        "legacy command with unterminated quote",
+			json:        `"curl -d 'ok https://example.com/hook"`,
+			expectError: true,
+		},
+		{
+			name:        "neither a command nor an object",
+			json:        `42`,
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var callback Callback
+			err := json.Unmarshal([]byte(tc.json), &callback)
+
+			if tc.expectError {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, callback)
+		})
+	}
+}
@@ -3,14 +3,20 @@ package email
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
+
+	"multicarrier-email-api/internal/auth"
 )
 
 const (
 	StatusMeta                  = "_META"
+	StatusScheduled             = "SCHEDULED"
+	StatusCancelled             = "CANCELLED"
 	StatusAccepted              = "ACCEPTED"
 	StatusIntaking              = "INTAKING"
 	StatusProcessing            = "PROCESSING"
@@ -26,6 +32,8 @@ const (
 
 const (
 	statusInitial               = StatusAccepted
+	statusScheduled             = StatusScheduled
+	statusCancelled             = StatusCancelled
 	statusIntaking              = StatusIntaking
 	statusProcessing            = StatusProcessing
 	statusCallingSentCallback   = StatusCallingSentCallback
@@ -35,11 +43,41 @@ const (
 	statusFailed                = StatusFailed
 )
 
+// Priorities order the READY queue, high first. Their order matches the priority ENUM of the emails table.
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+	PriorityBulk   = "bulk"
+)
+
+var (
+	ErrEmailNotFound     = errors.New("email not found")
+	ErrEmailNotScheduled = errors.New("email is not scheduled")
+	ErrNoTenant          = errors.New("no tenant in context")
+	ErrPayloadNotFound   = errors.New("email payload not found")
+	ErrEmailIdTaken      = errors.New("email ID is used by another tenant")
+)
+
 // MySQL error codes
 const (
 	mysqlDuplicateEntryCode = 1062
 )
 
+// tenantCondition restricts a query to the emails of the tenant of the caller found in the context.
+// Super admins are not restricted.
+func tenantCondition(ctx context.Context, column string) (string, []any, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", nil, ErrNoTenant
+	}
+
+	if principal.SuperAdmin {
+		return "TRUE", nil, nil
+	}
+
+	return column + " = ?", []any{principal.Tenant}, nil
+}
+
 type Database struct {
 	db                          *sql.DB
 	staleEmailsThresholdMinutes int
@@ -52,17 +90,123 @@ func NewDatabase(db *sql.DB, staleEmailsThresholdMinutes int) *Database {
 	}
 }
 
-func (d *Database) Insert(ctx context.Context, id string, payloadFilePath string) error {
+// InsertParams holds the values of a new emails row
+type InsertParams struct {
+	Id              string
+	PayloadFilePath string
+	PayloadHash     string
+	SendAt          *time.Time
+	Priority        string
+	Tags            []string
+	Metadata        map[string]string
+	// EMLFilePath is set for pre-rendered messages, which have no JSON payload
+	EMLFilePath string
+	// EnvelopeFilePath holds the recipients of the message of EMLFilePath, Bcc included
+	EnvelopeFilePath string
+}
+
+// SearchParams filters emails by producer tags and metadata. All the given tags and metadata
+// entries must match.
+type SearchParams struct {
+	Tags     []string
+	Metadata map[string]string
+	Limit    int
+}
+
+// nullString returns NULL for empty values
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// initialStatus returns SCHEDULED for emails to be sent in the future, ACCEPTED otherwise
+func (p InsertParams) initialStatus(now time.Time) string {
+	if p.SendAt != nil && p.SendAt.After(now) {
+		return statusScheduled
+	}
+	return statusInitial
+}
+
+func (p InsertParams) priority() string {
+	if p.Priority == "" {
+		return PriorityNormal
+	}
+	return p.Priority
+}
+
+func (d *Database) Insert(ctx context.Context, params InsertParams) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Tenant == "" {
+		return ErrNoTenant
+	}
+
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
-	// Insert into emails table
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO emails (id, status, payload_file_path, version) VALUES (?, ?, ?, 1)`,
-		id, statusInitial, payloadFilePath,
+	if err := insertEmail(ctx, tx, principal.Tenant, params, time.Now()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// BatchInsertError reports the email of a batch whose row could not be inserted, which rolled back
+// the rows of the whole batch
+type BatchInsertError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchInsertError) Error() string {
+	return fmt.Sprintf("failed to insert email %d of the batch: %v", e.Index, e.Err)
+}
+
+func (e *BatchInsertError) Unwrap() error {
+	return e.Err
+}
+
+// InsertBatch inserts the rows of several emails in a single transaction, so that either all of
+// them or none are inserted. Errors of a row are returned as a *BatchInsertError.
+func (d *Database) InsertBatch(ctx context.Context, params []InsertParams) error {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Tenant == "" {
+		return ErrNoTenant
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for i, p := range params {
+		if err := insertEmail(ctx, tx, principal.Tenant, p, now); err != nil {
+			return &BatchInsertError{Index: i, Err: err}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// insertEmail inserts the rows of an email within a transaction
+func insertEmail(ctx context.Context, tx *sql.Tx, tenant string, params InsertParams, now time.Time) error {
+	status := params.initialStatus(now)
+
+	// Insert into emails table, whose error is returned as it is for IsDuplicateEntryError
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO emails (id, tenant_id, status, priority, payload_file_path, eml_file_path, envelope_file_path, payload_hash, send_at, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+		params.Id, tenant, status, params.priority(), nullString(params.PayloadFilePath), nullString(params.EMLFilePath), nullString(params.EnvelopeFilePath), params.PayloadHash, params.SendAt,
 	)
 	if err != nil {
 		return err
@@ -71,32 +215,140 @@ func (d *Database) Insert(ctx context.Context, id string, payloadFilePath string
 	// Insert initial status into email_statuses
 	_, err = tx.ExecContext(ctx,
 		`INSERT INTO email_statuses (email_id, status) VALUES (?, ?)`,
-		id, statusInitial,
+		params.Id, status,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to insert status history: %w", err)
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := insertTags(ctx, tx, params.Id, params.Tags); err != nil {
+		return err
+	}
+
+	return insertMetadata(ctx, tx, params.Id, params.Metadata)
+}
+
+func insertTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	values := strings.TrimSuffix(strings.Repeat("(?, ?), ", len(tags)), ", ")
+	args := make([]any, 0, len(tags)*2)
+	for _, tag := range tags {
+		args = append(args, id, tag)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO email_tags (email_id, tag) VALUES `+values, args...); err != nil {
+		return fmt.Errorf("failed to insert tags: %w", err)
 	}
 
 	return nil
 }
 
+func insertMetadata(ctx context.Context, tx *sql.Tx, id string, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	values := strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(metadata)), ", ")
+	args := make([]any, 0, len(metadata)*3)
+	for key, value := range metadata {
+		args = append(args, id, key, value)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO email_metadata (email_id, meta_key, meta_value) VALUES `+values, args...); err != nil {
+		return fmt.Errorf("failed to insert metadata: %w", err)
+	}
+
+	return nil
+}
+
+// GetPayloadHash returns the payload hash stored for the given email, or an empty string for
+// emails accepted before hashes were recorded. IDs are unique across tenants, so the ID of an email
+// of another tenant is reported with ErrEmailIdTaken, without disclosing its hash.
+func (d *Database) GetPayloadHash(ctx context.Context, id string) (string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+
+	var hash sql.NullString
+	var tenant string
+	err := d.db.QueryRowContext(ctx,
+		`SELECT payload_hash, tenant_id FROM emails WHERE id = ?`,
+		id,
+	).Scan(&hash, &tenant)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%w: %s", ErrEmailNotFound, id)
+		}
+		return "", fmt.Errorf("failed to get payload hash: %w", err)
+	}
+
+	if tenant != principal.Tenant {
+		return "", fmt.Errorf("%w: %s", ErrEmailIdTaken, id)
+	}
+
+	return hash.String, nil
+}
+
+// StoredFilePaths are the paths of the stored files of an email. Raw emails have a message and no
+// payload.
+type StoredFilePaths struct {
+	PayloadFilePath string
+	EMLFilePath     string
+}
+
+// GetStoredFilePaths returns the paths of the stored payload and message of the given email
+func (d *Database) GetStoredFilePaths(ctx context.Context, id string) (StoredFilePaths, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return StoredFilePaths{}, err
+	}
+
+	var payloadPath, emlPath sql.NullString
+	err = d.db.QueryRowContext(ctx,
+		`SELECT payload_file_path, eml_file_path FROM emails WHERE id = ? AND `+tenantCond,
+		append([]any{id}, tenantArgs...)...,
+	).Scan(&payloadPath, &emlPath)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return StoredFilePaths{}, fmt.Errorf("%w: %s", ErrEmailNotFound, id)
+		}
+		return StoredFilePaths{}, fmt.Errorf("failed to get stored file paths: %w", err)
+	}
+
+	if payloadPath.String == "" && emlPath.String == "" {
+		return StoredFilePaths{}, fmt.Errorf("%w: %s", ErrPayloadNotFound, id)
+	}
+
+	return StoredFilePaths{PayloadFilePath: payloadPath.String, EMLFilePath: emlPath.String}, nil
+}
+
 func (d *Database) GetStaleEmails(ctx context.Context) ([]Email, error) {
 	thresholdTime := time.Now().Add(-time.Duration(d.staleEmailsThresholdMinutes) * time.Minute)
 
-	rows, err := d.db.QueryContext(ctx,
-		`SELECT id, status, created_at, updated_at 
-		FROM emails 
-		WHERE status IN (?, ?, ?, ?) 
-		AND updated_at < ?`,
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]any{
 		statusIntaking,
 		statusProcessing,
 		statusCallingSentCallback,
 		statusCallingFailedCallback,
 		thresholdTime,
+	}, tenantArgs...)
+
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT id, tenant_id, status, priority, created_at, updated_at 
+		FROM emails 
+		WHERE status IN (?, ?, ?, ?) 
+		AND updated_at < ? 
+		AND `+tenantCond,
+		args...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query stale emails: %w", err)
@@ -106,7 +358,7 @@ func (d *Database) GetStaleEmails(ctx context.Context) ([]Email, error) {
 	var emails []Email
 	for rows.Next() {
 		var e Email
-		if err := rows.Scan(&e.Id, &e.Status, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		if err := rows.Scan(&e.Id, &e.Tenant, &e.Status, &e.Priority, &e.CreatedAt, &e.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan email row: %w", err)
 		}
 		emails = append(emails, e)
@@ -120,11 +372,17 @@ func (d *Database) GetStaleEmails(ctx context.Context) ([]Email, error) {
 }
 
 func (d *Database) GetInvalidEmails(ctx context.Context) ([]Email, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := d.db.QueryContext(ctx,
-		`SELECT id, status, reason, created_at, updated_at 
+		`SELECT id, tenant_id, status, priority, reason, created_at, updated_at 
 		FROM emails 
-		WHERE status = ?`,
-		StatusInvalid,
+		WHERE status = ? 
+		AND `+tenantCond,
+		append([]any{StatusInvalid}, tenantArgs...)...,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query invalid emails: %w", err)
@@ -135,7 +393,58 @@ func (d *Database) GetInvalidEmails(ctx context.Context) ([]Email, error) {
 	for rows.Next() {
 		var e Email
 		var reason sql.NullString
-		if err := rows.Scan(&e.Id, &e.Status, &reason, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		if err := rows.Scan(&e.Id, &e.Tenant, &e.Status, &e.Priority, &reason, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan email row: %w", err)
+		}
+		if reason.Valid {
+			e.ErrorMessage = reason.String
+		}
+		emails = append(emails, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email rows: %w", err)
+	}
+
+	return emails, nil
+}
+
+// SearchEmails returns the most recent emails having all the tags and metadata entries of the params
+func (d *Database) SearchEmails(ctx context.Context, params SearchParams) ([]Email, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "e.tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{tenantCond}
+	args := tenantArgs
+
+	for _, tag := range params.Tags {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM email_tags t WHERE t.email_id = e.id AND t.tag = ?)`)
+		args = append(args, tag)
+	}
+
+	for key, value := range params.Metadata {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM email_metadata m WHERE m.email_id = e.id AND m.meta_key = ? AND m.meta_value = ?)`)
+		args = append(args, key, value)
+	}
+
+	query := `SELECT e.id, e.tenant_id, e.status, e.priority, e.reason, e.created_at, e.updated_at FROM emails e` +
+		` WHERE ` + strings.Join(conditions, ` AND `) +
+		` ORDER BY e.created_at DESC, e.id LIMIT ?`
+	args = append(args, params.Limit)
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search emails: %w", err)
+	}
+	defer rows.Close()
+
+	var emails []Email
+	for rows.Next() {
+		var e Email
+		var reason sql.NullString
+		if err := rows.Scan(&e.Id, &e.Tenant, &e.Status, &e.Priority, &reason, &e.CreatedAt, &e.UpdatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan email row: %w", err)
 		}
 		if reason.Valid {
@@ -152,6 +461,11 @@ func (d *Database) GetInvalidEmails(ctx context.Context) ([]Email, error) {
 }
 
 func (d *Database) RequeueEmail(ctx context.Context, id string) error {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return err
+	}
+
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -162,8 +476,8 @@ func (d *Database) RequeueEmail(ctx context.Context, id string) error {
 	var currentStatus string
 	var version int
 	err = tx.QueryRowContext(ctx,
-		`SELECT status, version FROM emails WHERE id = ? FOR UPDATE`,
-		id,
+		`SELECT status, version FROM emails WHERE id = ? AND `+tenantCond+` FOR UPDATE`,
+		append([]any{id}, tenantArgs...)...,
 	).Scan(&currentStatus, &version)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -220,7 +534,236 @@ func (d *Database) RequeueEmail(ctx context.Context, id string) error {
 	return nil
 }
 
-// IsDuplicateEntryError checks if the error is a MySQL duplicate entry error
+// PromoteScheduledEmails moves up to limit SCHEDULED emails whose send_at is due to ACCEPTED,
+// returning how many were promoted. Rows locked by a concurrent promoter are skipped.
+func (d *Database) PromoteScheduledEmails(ctx context.Context, limit int) (int, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id FROM emails 
+		WHERE status = ? AND send_at <= ? AND `+tenantCond+` 
+		ORDER BY send_at 
+		LIMIT ? 
+		FOR UPDATE SKIP LOCKED`,
+		append(append([]any{statusScheduled, time.Now()}, tenantArgs...), limit)...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query scheduled emails: %w", err)
+	}
+
+	var ids []any
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan email row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating email rows: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	updateArgs := append([]any{statusInitial, statusScheduled}, ids...)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE emails SET status = ?, version = version + 1 WHERE status = ? AND id IN (`+placeholders+`)`,
+		updateArgs...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update email status: %w", err)
+	}
+
+	historyValues := strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(ids)), ", ")
+	historyArgs := make([]any, 0, len(ids)*3)
+	for _, id := range ids {
+		historyArgs = append(historyArgs, id, statusInitial, "Released from schedule")
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO email_statuses (email_id, status, reason) VALUES `+historyValues,
+		historyArgs...,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(ids), nil
+}
+
+// ClaimReadyEmails moves up to limit READY emails to PROCESSING for sending and returns them.
+// Higher priorities are claimed first, then older emails. Rows locked by a concurrent claimer are skipped.
+func (d *Database) ClaimReadyEmails(ctx context.Context, limit int) ([]Email, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, tenant_id, priority, created_at 
+		FROM emails 
+		WHERE status = ? AND `+tenantCond+` 
+		ORDER BY priority, created_at 
+		LIMIT ? 
+		FOR UPDATE SKIP LOCKED`,
+		append(append([]any{statusReady}, tenantArgs...), limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ready emails: %w", err)
+	}
+
+	var emails []Email
+	var ids []any
+	for rows.Next() {
+		e := Email{Status: statusProcessing}
+		if err := rows.Scan(&e.Id, &e.Tenant, &e.Priority, &e.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan email row: %w", err)
+		}
+		emails = append(emails, e)
+		ids = append(ids, e.Id)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email rows: %w", err)
+	}
+
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+
+	updateArgs := append([]any{statusProcessing, statusReady}, ids...)
+	_, err = tx.ExecContext(ctx,
+		`UPDATE emails SET status = ?, version = version + 1 WHERE status = ? AND id IN (`+placeholders+`)`,
+		updateArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update email status: %w", err)
+	}
+
+	historyValues := strings.TrimSuffix(strings.Repeat("(?, ?, ?), ", len(ids)), ", ")
+	historyArgs := make([]any, 0, len(ids)*3)
+	for _, id := range ids {
+		historyArgs = append(historyArgs, id, statusProcessing, "Claimed for sending")
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO email_statuses (email_id, status, reason) VALUES `+historyValues,
+		historyArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return emails, nil
+}
+
+// RescheduleEmail changes the send_at of an email that is still SCHEDULED
+func (d *Database) RescheduleEmail(ctx context.Context, id string, sendAt time.Time) error {
+	reason := fmt.Sprintf("Rescheduled to %s", sendAt.UTC().Format(time.RFC3339))
+	return d.updateScheduledEmail(ctx, id, statusScheduled, &sendAt, reason)
+}
+
+// CancelScheduledEmail moves an email that is still SCHEDULED to CANCELLED
+func (d *Database) CancelScheduledEmail(ctx context.Context, id string) error {
+	return d.updateScheduledEmail(ctx, id, statusCancelled, nil, "Cancelled before release")
+}
+
+// updateScheduledEmail sets the status, and the send_at when not nil, of a SCHEDULED email
+func (d *Database) updateScheduledEmail(ctx context.Context, id string, newStatus string, sendAt *time.Time, reason string) error {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentStatus string
+	var version int
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, version FROM emails WHERE id = ? AND `+tenantCond+` FOR UPDATE`,
+		append([]any{id}, tenantArgs...)...,
+	).Scan(&currentStatus, &version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("%w: %s", ErrEmailNotFound, id)
+		}
+		return fmt.Errorf("failed to get email: %w", err)
+	}
+
+	if currentStatus != statusScheduled {
+		return fmt.Errorf("%w: %s has status %s", ErrEmailNotScheduled, id, currentStatus)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE emails SET status = ?, send_at = COALESCE(?, send_at), version = version + 1 WHERE id = ? AND version = ?`,
+		newStatus, sendAt, id, version,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("email was modified by another process")
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO email_statuses (email_id, status, reason) VALUES (?, ?, ?)`,
+		id, newStatus, reason,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert status history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// IsDuplicateEntryError checks if the error is a MySQL duplicate entry error on the emails table,
+// that is a duplicated ID. The errors of the other tables of an email are wrapped, so that they do
+// not match.
 func IsDuplicateEntryError(err error) bool {
 	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
 		return mysqlErr.Number == mysqlDuplicateEntryCode
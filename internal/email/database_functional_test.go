@@ -5,15 +5,18 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+
+	"multicarrier-email-api/internal/auth"
 )
 
-func getTestDB(t *testing.T) *sql.DB {
+func getTestDB(t testing.TB) *sql.DB {
 	host := os.Getenv("MYSQL_HOST")
 	port := os.Getenv("MYSQL_PORT")
 	user := os.Getenv("MYSQL_USER")
@@ -37,7 +40,12 @@ func getTestDB(t *testing.T) *sql.DB {
 	return db
 }
 
-func cleanupEmail(t *testing.T, db *sql.DB, id string) {
+// tenantContext returns a context authenticated as a caller of the tenant
+func tenantContext(tenant string) context.Context {
+	return auth.NewContext(context.TODO(), auth.Principal{Tenant: tenant})
+}
+
+func cleanupEmail(t testing.TB, db *sql.DB, id string) {
 	_, err := db.Exec("DELETE FROM emails WHERE id = ?", id)
 	if err != nil {
 		t.Logf("cleanup failed for id %s: %v", id, err)
@@ -54,19 +62,19 @@ func TestOutboxComponentWorkflow(t *testing.T) {
 
 	sut := NewDatabase(db, 30)
 
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	// insert two records
 	firstId := uuid.NewString()
 	defer cleanupEmail(t, db, firstId)
 
-	err := sut.Insert(ctx, firstId, "/payload/path1.json")
+	err := sut.Insert(ctx, InsertParams{Id: firstId, PayloadFilePath: "/payload/path1.json"})
 	require.NoErrorf(t, err, "failed inserting id %s, error: %v", firstId, err)
 
 	secondId := uuid.NewString()
 	defer cleanupEmail(t, db, secondId)
 
-	err = sut.Insert(ctx, secondId, "/payload/path2.json")
+	err = sut.Insert(ctx, InsertParams{Id: secondId, PayloadFilePath: "/payload/path2.json"})
 	require.NoErrorf(t, err, "failed inserting id %s, error: %v", secondId, err)
 
 	// verify records exist with ACCEPTED status
@@ -81,7 +89,7 @@ func TestOutboxComponentWorkflow(t *testing.T) {
 	require.Equal(t, 2, count)
 
 	// should not be able to insert again same id
-	err = sut.Insert(ctx, firstId, "/")
+	err = sut.Insert(ctx, InsertParams{Id: firstId, PayloadFilePath: "/"})
 	require.Errorf(t, err, "inserted id %s, but it should have not because it's duplicated", firstId)
 	require.True(t, IsDuplicateEntryError(err))
 }
@@ -95,7 +103,7 @@ func TestGetStaleEmails(t *testing.T) {
 	defer db.Close()
 
 	sut := NewDatabase(db, 30)
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	// Insert a stale email directly (bypassing Insert to set custom updated_at)
 	staleId := uuid.NewString()
@@ -112,7 +120,7 @@ func TestGetStaleEmails(t *testing.T) {
 	recentId := uuid.NewString()
 	defer cleanupEmail(t, db, recentId)
 
-	err = sut.Insert(ctx, recentId, "/payload/recent.json")
+	err = sut.Insert(ctx, InsertParams{Id: recentId, PayloadFilePath: "/payload/recent.json"})
 	require.NoError(t, err)
 
 	// Get stale emails
@@ -125,6 +133,7 @@ func TestGetStaleEmails(t *testing.T) {
 		if e.Id == staleId {
 			found = true
 			require.Equal(t, StatusIntaking, e.Status)
+			require.Equal(t, PriorityNormal, e.Priority)
 		}
 		require.NotEqual(t, recentId, e.Id, "recent email should not be in stale list")
 	}
@@ -140,7 +149,7 @@ func TestGetInvalidEmails(t *testing.T) {
 	defer db.Close()
 
 	sut := NewDatabase(db, 30)
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	// Insert an invalid email directly
 	invalidId := uuid.NewString()
@@ -177,7 +186,7 @@ func TestRequeueEmail(t *testing.T) {
 	defer db.Close()
 
 	sut := NewDatabase(db, 30)
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	// Test cases: states that can be requeued and their expected new status
 	testCases := map[string]string{
@@ -229,7 +238,7 @@ func TestRequeueEmailErrorsForNonRequeuableStatuses(t *testing.T) {
 	defer db.Close()
 
 	sut := NewDatabase(db, 30)
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	// States that should NOT be requeuable
 	nonRequeuableStatuses := []string{
@@ -277,9 +286,461 @@ func TestRequeueEmailNotFound(t *testing.T) {
 	defer db.Close()
 
 	sut := NewDatabase(db, 30)
-	ctx := context.TODO()
+	ctx := tenantContext(auth.DefaultTenant)
 
 	err := sut.RequeueEmail(ctx, "non-existent-id")
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "not found")
 }
+
+func TestScheduledEmailsWorkflow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	// an email with a future send_at is scheduled
+	futureId := uuid.NewString()
+	defer cleanupEmail(t, db, futureId)
+
+	future := time.Now().Add(24 * time.Hour)
+	err := sut.Insert(ctx, InsertParams{Id: futureId, PayloadFilePath: "/payload/future.json", SendAt: &future})
+	require.NoError(t, err)
+
+	var status string
+	err = db.QueryRow("SELECT status FROM emails WHERE id = ?", futureId).Scan(&status)
+	require.NoError(t, err)
+	require.Equal(t, StatusScheduled, status)
+
+	// an email with a past send_at is accepted right away
+	pastId := uuid.NewString()
+	defer cleanupEmail(t, db, pastId)
+
+	past := time.Now().Add(-time.Hour)
+	err = sut.Insert(ctx, InsertParams{Id: pastId, PayloadFilePath: "/payload/past.json", SendAt: &past})
+	require.NoError(t, err)
+
+	err = db.QueryRow("SELECT status FROM emails WHERE id = ?", pastId).Scan(&status)
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, status)
+
+	// not yet due, so it is not promoted
+	_, err = sut.PromoteScheduledEmails(ctx, 1000)
+	require.NoError(t, err)
+
+	err = db.QueryRow("SELECT status FROM emails WHERE id = ?", futureId).Scan(&status)
+	require.NoError(t, err)
+	require.Equal(t, StatusScheduled, status)
+
+	// rescheduling in the past makes it due
+	err = sut.RescheduleEmail(ctx, futureId, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+
+	promoted, err := sut.PromoteScheduledEmails(ctx, 1000)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, promoted, 1)
+
+	var version int
+	err = db.QueryRow("SELECT status, version FROM emails WHERE id = ?", futureId).Scan(&status, &version)
+	require.NoError(t, err)
+	require.Equal(t, StatusAccepted, status)
+	require.Equal(t, 3, version)
+
+	var historyCount int
+	err = db.QueryRow("SELECT COUNT(*) FROM email_statuses WHERE email_id = ?", futureId).Scan(&historyCount)
+	require.NoError(t, err)
+	require.Equal(t, 3, historyCount, "should have scheduled, rescheduled and released history entries")
+
+	// released emails can no longer be rescheduled or cancelled
+	err = sut.RescheduleEmail(ctx, futureId, future)
+	require.ErrorIs(t, err, ErrEmailNotScheduled)
+
+	err = sut.CancelScheduledEmail(ctx, futureId)
+	require.ErrorIs(t, err, ErrEmailNotScheduled)
+}
+
+func TestCancelScheduledEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	id := uuid.NewString()
+	defer cleanupEmail(t, db, id)
+
+	sendAt := time.Now().Add(time.Hour)
+	err := sut.Insert(ctx, InsertParams{Id: id, PayloadFilePath: "/payload/scheduled.json", SendAt: &sendAt})
+	require.NoError(t, err)
+
+	err = sut.CancelScheduledEmail(ctx, id)
+	require.NoError(t, err)
+
+	var status string
+	err = db.QueryRow("SELECT status FROM emails WHERE id = ?", id).Scan(&status)
+	require.NoError(t, err)
+	require.Equal(t, StatusCancelled, status)
+
+	err = sut.CancelScheduledEmail(ctx, "non-existent-id")
+	require.ErrorIs(t, err, ErrEmailNotFound)
+}
+
+func TestGetPayloadHash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	id := uuid.NewString()
+	defer cleanupEmail(t, db, id)
+
+	hash := payloadHash([]byte(`{"id":"` + id + `"}`))
+	err := sut.Insert(ctx, InsertParams{Id: id, PayloadFilePath: "/payload/hashed.json", PayloadHash: hash})
+	require.NoError(t, err)
+
+	storedHash, err := sut.GetPayloadHash(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, hash, storedHash)
+
+	_, err = sut.GetPayloadHash(tenantContext("tenant-"+uuid.NewString()), id)
+	require.ErrorIs(t, err, ErrEmailIdTaken)
+
+	_, err = sut.GetPayloadHash(ctx, "non-existent-id")
+	require.ErrorIs(t, err, ErrEmailNotFound)
+}
+
+func TestGetStoredFilePaths(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	id := uuid.NewString()
+	defer cleanupEmail(t, db, id)
+
+	require.NoError(t, sut.Insert(ctx, InsertParams{Id: id, PayloadFilePath: "/payload/preview.json", PayloadHash: "hash"}))
+
+	paths, err := sut.GetStoredFilePaths(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, StoredFilePaths{PayloadFilePath: "/payload/preview.json"}, paths)
+
+	_, err = sut.GetStoredFilePaths(tenantContext("tenant-"+uuid.NewString()), id)
+	require.ErrorIs(t, err, ErrEmailNotFound)
+
+	_, err = sut.GetStoredFilePaths(ctx, "non-existent-id")
+	require.ErrorIs(t, err, ErrEmailNotFound)
+}
+
+func TestInsertRawEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	id := uuid.NewString()
+	defer cleanupEmail(t, db, id)
+
+	require.NoError(t, sut.Insert(ctx, InsertParams{Id: id, EMLFilePath: "/payload/raw.eml", EnvelopeFilePath: "/payload/raw.envelope.json", PayloadHash: "hash"}))
+
+	var emlFilePath, payloadFilePath, envelopeFilePath sql.NullString
+	err := db.QueryRow(`SELECT eml_file_path, payload_file_path, envelope_file_path FROM emails WHERE id = ?`, id).Scan(&emlFilePath, &payloadFilePath, &envelopeFilePath)
+	require.NoError(t, err)
+	require.Equal(t, "/payload/raw.eml", emlFilePath.String)
+	require.False(t, payloadFilePath.Valid)
+	require.Equal(t, "/payload/raw.envelope.json", envelopeFilePath.String)
+
+	paths, err := sut.GetStoredFilePaths(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, StoredFilePaths{EMLFilePath: "/payload/raw.eml"}, paths)
+}
+
+func TestInsertBatch(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	first, second, third := uuid.NewString(), uuid.NewString(), uuid.NewString()
+	defer cleanupEmail(t, db, first)
+	defer cleanupEmail(t, db, second)
+	defer cleanupEmail(t, db, third)
+
+	require.NoError(t, sut.InsertBatch(ctx, []InsertParams{
+		{Id: first, PayloadFilePath: "/payload/first.json", PayloadHash: "hash", Tags: []string{"contract"}},
+		{Id: second, PayloadFilePath: "/payload/second.json", PayloadHash: "hash"},
+	}))
+
+	_, err := sut.GetPayloadHash(ctx, first)
+	require.NoError(t, err)
+	_, err = sut.GetPayloadHash(ctx, second)
+	require.NoError(t, err)
+
+	// the duplicated second ID rolls back the third email
+	err = sut.InsertBatch(ctx, []InsertParams{
+		{Id: third, PayloadFilePath: "/payload/third.json", PayloadHash: "hash"},
+		{Id: second, PayloadFilePath: "/payload/second.json", PayloadHash: "hash"},
+	})
+	var batchErr *BatchInsertError
+	require.ErrorAs(t, err, &batchErr)
+	require.Equal(t, 1, batchErr.Index)
+	require.True(t, IsDuplicateEntryError(batchErr.Err))
+
+	_, err = sut.GetPayloadHash(ctx, third)
+	require.ErrorIs(t, err, ErrEmailNotFound)
+
+	require.ErrorIs(t, sut.InsertBatch(context.TODO(), []InsertParams{{Id: third}}), ErrNoTenant)
+}
+
+func TestClaimReadyEmailsByPriority(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	bulkId := uuid.NewString()
+	defer cleanupEmail(t, db, bulkId)
+	oldNormalId := uuid.NewString()
+	defer cleanupEmail(t, db, oldNormalId)
+	newNormalId := uuid.NewString()
+	defer cleanupEmail(t, db, newNormalId)
+	highId := uuid.NewString()
+	defer cleanupEmail(t, db, highId)
+
+	now := time.Now()
+	for _, row := range []struct {
+		id        string
+		priority  string
+		createdAt time.Time
+	}{
+		{bulkId, PriorityBulk, now.Add(-3 * time.Hour)},
+		{oldNormalId, PriorityNormal, now.Add(-2 * time.Hour)},
+		{newNormalId, PriorityNormal, now.Add(-1 * time.Hour)},
+		{highId, PriorityHigh, now},
+	} {
+		_, err := db.Exec(
+			`INSERT INTO emails (id, status, priority, payload_file_path, version, created_at) VALUES (?, ?, ?, ?, 1, ?)`,
+			row.id, StatusReady, row.priority, "/payload/ready.json", row.createdAt,
+		)
+		require.NoError(t, err)
+	}
+
+	ownIds := map[string]bool{bulkId: true, oldNormalId: true, newNormalId: true, highId: true}
+
+	// READY rows left by other tests may be claimed too, only the order of the rows of this test is checked
+	claimed, err := sut.ClaimReadyEmails(ctx, 1000)
+	require.NoError(t, err)
+
+	var claimedIds []string
+	for _, e := range claimed {
+		require.Equal(t, StatusProcessing, e.Status)
+		if ownIds[e.Id] {
+			claimedIds = append(claimedIds, e.Id)
+		}
+	}
+	require.Equal(t, []string{highId, oldNormalId, newNormalId, bulkId}, claimedIds)
+
+	var status string
+	require.NoError(t, db.QueryRow("SELECT status FROM emails WHERE id = ?", highId).Scan(&status))
+	require.Equal(t, StatusProcessing, status)
+
+	claimed, err = sut.ClaimReadyEmails(ctx, 1000)
+	require.NoError(t, err)
+	for _, e := range claimed {
+		require.False(t, ownIds[e.Id], "claimed emails are not claimed again")
+	}
+}
+
+func TestSearchEmailsByTagsAndMetadata(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	invoiceTag := "invoice-" + uuid.NewString()
+
+	firstId := uuid.NewString()
+	defer cleanupEmail(t, db, firstId)
+	err := sut.Insert(ctx, InsertParams{
+		Id:              firstId,
+		PayloadFilePath: "/payload/first.json",
+		Tags:            []string{invoiceTag, "reminder"},
+		Metadata:        map[string]string{"invoice_id": "INV-1"},
+	})
+	require.NoError(t, err)
+
+	secondId := uuid.NewString()
+	defer cleanupEmail(t, db, secondId)
+	err = sut.Insert(ctx, InsertParams{
+		Id:              secondId,
+		PayloadFilePath: "/payload/second.json",
+		Tags:            []string{invoiceTag},
+		Metadata:        map[string]string{"invoice_id": "INV-2"},
+	})
+	require.NoError(t, err)
+
+	emails, err := sut.SearchEmails(ctx, SearchParams{Tags: []string{invoiceTag}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, emails, 2)
+
+	emails, err = sut.SearchEmails(ctx, SearchParams{Tags: []string{invoiceTag, "reminder"}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, firstId, emails[0].Id)
+	require.Equal(t, StatusAccepted, emails[0].Status)
+
+	emails, err = sut.SearchEmails(ctx, SearchParams{
+		Tags:     []string{invoiceTag},
+		Metadata: map[string]string{"invoice_id": "INV-2"},
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, secondId, emails[0].Id)
+
+	emails, err = sut.SearchEmails(ctx, SearchParams{Tags: []string{invoiceTag}, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+
+	// side rows are removed with the email
+	cleanupEmail(t, db, firstId)
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM email_tags WHERE email_id = ?", firstId).Scan(&count))
+	require.Zero(t, count)
+}
+
+func TestInsertTagsAndMetadataDifferingByCase(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+	ctx := tenantContext(auth.DefaultTenant)
+
+	tag := "promo-" + uuid.NewString()
+
+	id := uuid.NewString()
+	defer cleanupEmail(t, db, id)
+	err := sut.Insert(ctx, InsertParams{
+		Id:              id,
+		PayloadFilePath: "/payload/case.json",
+		Tags:            []string{tag, strings.ToUpper(tag)},
+		Metadata:        map[string]string{"invoice_id": "INV-1", "Invoice_Id": "INV-2"},
+	})
+	require.NoError(t, err)
+
+	emails, err := sut.SearchEmails(ctx, SearchParams{Tags: []string{strings.ToUpper(tag)}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+
+	emails, err = sut.SearchEmails(ctx, SearchParams{Tags: []string{strings.ToUpper(tag)}, Metadata: map[string]string{"Invoice_Id": "INV-1"}, Limit: 10})
+	require.NoError(t, err)
+	require.Empty(t, emails, "metadata keys are case sensitive")
+}
+
+func TestTenantIsolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db, 30)
+
+	firstTenant := "tenant-" + uuid.NewString()
+	secondTenant := "tenant-" + uuid.NewString()
+	firstCtx := tenantContext(firstTenant)
+	secondCtx := tenantContext(secondTenant)
+	adminCtx := auth.NewContext(context.TODO(), auth.Principal{Tenant: "ops", SuperAdmin: true})
+
+	tag := "isolation-" + uuid.NewString()
+
+	firstId := uuid.NewString()
+	defer cleanupEmail(t, db, firstId)
+	require.NoError(t, sut.Insert(firstCtx, InsertParams{Id: firstId, PayloadFilePath: "/payload/first.json", PayloadHash: "hash", Tags: []string{tag}}))
+
+	secondId := uuid.NewString()
+	defer cleanupEmail(t, db, secondId)
+	require.NoError(t, sut.Insert(secondCtx, InsertParams{Id: secondId, PayloadFilePath: "/payload/second.json", Tags: []string{tag}}))
+
+	var tenant string
+	require.NoError(t, db.QueryRow("SELECT tenant_id FROM emails WHERE id = ?", firstId).Scan(&tenant))
+	require.Equal(t, firstTenant, tenant)
+
+	// each tenant only finds its own emails
+	emails, err := sut.SearchEmails(firstCtx, SearchParams{Tags: []string{tag}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, emails, 1)
+	require.Equal(t, firstId, emails[0].Id)
+	require.Equal(t, firstTenant, emails[0].Tenant)
+
+	_, err = sut.GetPayloadHash(secondCtx, firstId)
+	require.ErrorIs(t, err, ErrEmailNotFound)
+
+	// and cannot operate on the emails of other tenants
+	_, err = db.Exec("UPDATE emails SET status = ? WHERE id IN (?, ?)", StatusInvalid, firstId, secondId)
+	require.NoError(t, err)
+
+	err = sut.RequeueEmail(secondCtx, firstId)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+
+	invalidEmails, err := sut.GetInvalidEmails(secondCtx)
+	require.NoError(t, err)
+	for _, e := range invalidEmails {
+		require.Equal(t, secondTenant, e.Tenant)
+	}
+
+	// super admins operate across tenants
+	emails, err = sut.SearchEmails(adminCtx, SearchParams{Tags: []string{tag}, Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, emails, 2)
+
+	require.NoError(t, sut.RequeueEmail(adminCtx, firstId))
+
+	// a context without a caller is refused
+	_, err = sut.SearchEmails(context.TODO(), SearchParams{Tags: []string{tag}, Limit: 10})
+	require.ErrorIs(t, err, ErrNoTenant)
+}
@@ -0,0 +1,82 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+
+	"multicarrier-email-api/internal/auth"
+)
+
+func TestTenantCondition(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name              string
+		ctx               context.Context
+		expectedCondition string
+		expectedArgs      []any
+		expectedErr       error
+	}
+
+	cases := []caseStruct{
+		{
+			name:              "tenant",
+			ctx:               auth.NewContext(context.TODO(), auth.Principal{Tenant: "tenant-a"}),
+			expectedCondition: "e.tenant_id = ?",
+			expectedArgs:      []any{"tenant-a"},
+		},
+		{
+			name:              "super admin",
+			ctx:               auth.NewContext(context.TODO(), auth.Principal{Tenant: "ops", SuperAdmin: true}),
+			expectedCondition: "TRUE",
+		},
+		{
+			name:              "system",
+			ctx:               auth.NewSystemContext(context.TODO()),
+			expectedCondition: "TRUE",
+		},
+		{
+			name:        "no caller",
+			ctx:         context.TODO(),
+			expectedErr: ErrNoTenant,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			condition, args, err := tenantCondition(c.ctx, "e.tenant_id")
+
+			assert.ErrorIs(t, err, c.expectedErr)
+			assert.Equal(t, c.expectedCondition, condition)
+			assert.Equal(t, c.expectedArgs, args)
+		})
+	}
+}
+
+func TestInsertRequiresTenant(t *testing.T) {
+	t.Parallel()
+
+	sut := NewDatabase(nil, 30)
+
+	err := sut.Insert(context.TODO(), InsertParams{Id: "id"})
+	assert.ErrorIs(t, err, ErrNoTenant)
+
+	err = sut.Insert(auth.NewSystemContext(context.TODO()), InsertParams{Id: "id"})
+	assert.ErrorIs(t, err, ErrNoTenant)
+}
+
+func TestIsDuplicateEntryError(t *testing.T) {
+	t.Parallel()
+
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	assert.True(t, IsDuplicateEntryError(duplicate))
+	assert.False(t, IsDuplicateEntryError(fmt.Errorf("failed to insert tags: %w", duplicate)), "duplicated tags are not duplicated IDs")
+	assert.False(t, IsDuplicateEntryError(&mysql.MySQLError{Number: 1213, Message: "Deadlock"}))
+	assert.False(t, IsDuplicateEntryError(errors.New("failed")))
+}
@@ -0,0 +1,78 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// preferDryRun is the preference of the Prefer header (RFC 7240) asking to only validate the emails
+const preferDryRun = "dry-run"
+
+var errInvalidDryRun = errors.New("dry_run must be true or false")
+
+// saveFunc saves emails, or only validates them in dry-run mode
+type saveFunc func(ctx context.Context, emailRequests []EmailRequest) []SaveResult
+
+// isDryRun tells whether the request asks to validate the emails without queuing them, with the
+// dry_run query parameter or the dry-run preference of the Prefer header
+func isDryRun(r *http.Request) (bool, error) {
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		dryRun, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, errInvalidDryRun
+		}
+		return dryRun, nil
+	}
+
+	for _, header := range r.Header.Values("Prefer") {
+		for _, preference := range strings.Split(header, ",") {
+			// preferences may have parameters, such as "dry-run; foo=bar"
+			name, _, _ := strings.Cut(preference, ";")
+			if strings.EqualFold(strings.TrimSpace(name), preferDryRun) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// saver returns the function saving the emails of a request, which only validates them in dry-run mode
+func (h *CreateEmailHandler) saver(dryRun bool) saveFunc {
+	if dryRun {
+		return h.emailService.Validate
+	}
+	return h.emailService.Save
+}
+
+// atomicSaver returns the function saving all the emails of an atomic batch or none of them, which
+// only validates them in dry-run mode
+func (h *CreateEmailHandler) atomicSaver(dryRun bool) saveFunc {
+	if dryRun {
+		return h.emailService.ValidateAtomic
+	}
+	return h.emailService.SaveAtomic
+}
+
+// lineSaver returns the function saving the lines of an NDJSON request, which only validates them in
+// dry-run mode. The lines are validated one at a time, so the valid IDs are kept for the whole
+// request to resolve the later lines reusing them as Save would.
+func (h *CreateEmailHandler) lineSaver(dryRun bool) saveFunc {
+	if !dryRun {
+		return h.emailService.Save
+	}
+
+	validated := newValidatedIds()
+	return func(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+		results := make([]SaveResult, len(emailRequests))
+		for i, req := range emailRequests {
+			results[i] = validated.validate(req, func(req EmailRequest) SaveResult {
+				return h.emailService.Validate(ctx, []EmailRequest{req})[0]
+			})
+		}
+		return results
+	}
+}
@@ -0,0 +1,47 @@
+package email
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsDryRun(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name        string
+		target      string
+		prefer      []string
+		expected    bool
+		expectedErr error
+	}
+
+	cases := []caseStruct{
+		{"no dry run", "/", nil, false, nil},
+		{"query parameter", "/?dry_run=true", nil, true, nil},
+		{"query parameter false", "/?dry_run=false", []string{"dry-run"}, false, nil},
+		{"invalid query parameter", "/?dry_run=yes", nil, false, errInvalidDryRun},
+		{"Prefer header", "/", []string{"dry-run"}, true, nil},
+		{"Prefer header is case insensitive", "/", []string{"Dry-Run"}, true, nil},
+		{"one of many preferences", "/", []string{"return=minimal, dry-run"}, true, nil},
+		{"one of many Prefer headers", "/", []string{"return=minimal", "dry-run; scope=all"}, true, nil},
+		{"other preferences", "/", []string{"return=minimal"}, false, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, c.target, nil)
+			for _, prefer := range c.prefer {
+				request.Header.Add("Prefer", prefer)
+			}
+
+			dryRun, err := isDryRun(request)
+
+			assert.Equal(t, c.expected, dryRun)
+			assert.Equal(t, c.expectedErr, err)
+		})
+	}
+}
@@ -5,7 +5,9 @@ import "time"
 // Email represents an email record with its status and metadata
 type Email struct {
 	Id           string    `json:"id"`
+	Tenant       string    `json:"tenant,omitempty"`
 	Status       string    `json:"status"`
+	Priority     string    `json:"priority"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	ErrorMessage string    `json:"error_message,omitempty"`
@@ -4,22 +4,42 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
 
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/mailbox"
 	"multicarrier-email-api/internal/response"
+	"multicarrier-email-api/internal/templates"
 
 	"github.com/go-playground/validator/v10"
 )
 
 type AttachmentList []Attachment
 
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
 type Attachment struct {
-	Path string `json:"path" validate:"required,uri"`
-	Name string `json:"name" validate:"required"`
+	// Path is required unless the content is uploaded with ContentBase64, validateAttachment checks it
+	Path string `json:"path,omitempty" validate:"omitempty,uri"`
+	// ContentBase64 is written next to the payload by the service, which replaces it with Path
+	ContentBase64 string `json:"content_base64,omitempty" validate:"omitempty,base64"`
+	Name          string `json:"name" validate:"required"`
+	Disposition   string `json:"disposition" validate:"oneof=attachment inline"`
+	// ContentId is referenced from body_html as cid:<content_id>, without angle brackets
+	ContentId string `json:"content_id,omitempty" validate:"required_if=Disposition inline,max=255,excludesall=<> "`
 }
 
 func (a *AttachmentList) UnmarshalJSON(data []byte) error {
@@ -28,8 +48,9 @@ func (a *AttachmentList) UnmarshalJSON(data []byte) error {
 		*a = make([]Attachment, len(strings))
 		for i, s := range strings {
 			(*a)[i] = Attachment{
-				Path: s,
-				Name: filepath.Base(s),
+				Path:        s,
+				Name:        filepath.Base(s),
+				Disposition: DispositionAttachment,
 			}
 		}
 		return nil
@@ -39,30 +60,174 @@ func (a *AttachmentList) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &attachments); err != nil {
 		return fmt.Errorf("attachments must be either array of strings or array of objects: %w", err)
 	}
+	for i := range attachments {
+		if attachments[i].Disposition == "" {
+			attachments[i].Disposition = DispositionAttachment
+		}
+	}
 	*a = attachments
 	return nil
 }
 
+// inlineContentIds returns the content ids of inline attachments, and false when one is used twice
+func (a AttachmentList) inlineContentIds() (map[string]bool, bool) {
+	ids := make(map[string]bool)
+	for _, attachment := range a {
+		if attachment.Disposition != DispositionInline || attachment.ContentId == "" {
+			continue
+		}
+		if ids[attachment.ContentId] {
+			return ids, false
+		}
+		ids[attachment.ContentId] = true
+	}
+	return ids, true
+}
+
+// cidReferencePattern matches cid: URLs (RFC 2392) in src, href and CSS url() values
+var cidReferencePattern = regexp.MustCompile(`(?i)\bcid:([^"'\s()<>]+)`)
+
+// cidReferences returns the content ids referenced by an HTML body
+func cidReferences(html string) []string {
+	var references []string
+	for _, match := range cidReferencePattern.FindAllStringSubmatch(html, -1) {
+		reference, err := url.PathUnescape(match[1])
+		if err != nil {
+			reference = match[1]
+		}
+		references = append(references, reference)
+	}
+	return references
+}
+
+// maxRecipientsPerEmail caps the total number of to, cc and bcc addresses of a single email
+const maxRecipientsPerEmail = 50
+
 type emailDataInput struct {
-	Id            string            `json:"id" validate:"required,uuid"`
-	From          string            `json:"from" validate:"required,email"`
-	ReplyTo       string            `json:"reply_to" validate:"required,email"`
-	To            string            `json:"to" validate:"required,email"`
-	Subject       string            `json:"subject" validate:"required"`
-	BodyHTML      string            `json:"body_html" validate:"required_without=BodyText"`
-	BodyText      string            `json:"body_text" validate:"required_without=BodyHTML"`
-	Attachments   AttachmentList    `json:"attachments" validate:"dive"`
-	CustomHeaders map[string]string `json:"custom_headers"`
+	Id                string            `json:"id" validate:"required,uuid"`
+	From              Address           `json:"from" validate:"required,verified_sender"`
+	ReplyTo           Address           `json:"reply_to" validate:"required"`
+	To                RecipientList     `json:"to" validate:"required,min=1,dive"`
+	Cc                RecipientList     `json:"cc,omitempty" validate:"omitempty,dive"`
+	Bcc               RecipientList     `json:"bcc,omitempty" validate:"omitempty,dive"`
+	TemplateId        string            `json:"template_id,omitempty"`
+	TemplateVersion   int               `json:"template_version,omitempty" validate:"excluded_without=TemplateId,gte=0"`
+	Variables         map[string]any    `json:"variables,omitempty" validate:"excluded_without=TemplateId"`
+	Subject           string            `json:"subject" validate:"required"`
+	BodyHTML          string            `json:"body_html" validate:"required_without=BodyText"`
+	BodyText          string            `json:"body_text" validate:"required_without=BodyHTML"`
+	GenerateText      *bool             `json:"generate_text,omitempty"`
+	Attachments       AttachmentList    `json:"attachments" validate:"dive"`
+	CustomHeaders     map[string]string `json:"custom_headers" validate:"omitempty,dive,keys,header_name,header_allowed,endkeys,max=998,header_value"`
+	Unsubscribe       *Unsubscribe      `json:"unsubscribe,omitempty"`
+	SendAt            *time.Time        `json:"send_at,omitempty"`
+	Priority          string            `json:"priority,omitempty" validate:"omitempty,oneof=high normal bulk"`
+	Tags              []string          `json:"tags,omitempty" validate:"omitempty,max=20,unique,dive,required,max=100"`
+	Metadata          map[string]string `json:"metadata,omitempty" validate:"omitempty,max=20,dive,keys,required,max=64,printascii,excludesall= ,endkeys,max=500"`
+	CallbackOnSuccess *Callback         `json:"callback_on_success,omitempty"`
+	CallbackOnFailure *Callback         `json:"callback_on_failure,omitempty"`
+	// SMTPUTF8 is set by intake when an address has a UTF-8 local part, see needsSMTPUTF8
+	SMTPUTF8 bool `json:"smtputf8,omitempty"`
+}
+
+func (e emailDataInput) recipientCount() int {
+	return len(e.To) + len(e.Cc) + len(e.Bcc)
+}
+
+func (e emailDataInput) recipientAddresses() []string {
+	addresses := make([]string, 0, e.recipientCount())
+	for _, list := range []RecipientList{e.To, e.Cc, e.Bcc} {
+		for _, recipient := range list {
+			addresses = append(addresses, recipient.Address)
+		}
+	}
+	return addresses
+}
+
+// needsSMTPUTF8 tells whether an address of the email has a UTF-8 local part, so that the email
+// can only be relayed by servers supporting SMTPUTF8
+func (e emailDataInput) needsSMTPUTF8() bool {
+	if mailbox.NeedsSMTPUTF8(e.From.Address) || mailbox.NeedsSMTPUTF8(e.ReplyTo.Address) {
+		return true
+	}
+	for _, address := range e.recipientAddresses() {
+		if mailbox.NeedsSMTPUTF8(address) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateEmailDataInput(sl validator.StructLevel) {
+	e := sl.Current().Interface().(emailDataInput)
+
+	if e.recipientCount() > maxRecipientsPerEmail {
+		sl.ReportError(e.To, "To", "to", "max_recipients", strconv.Itoa(maxRecipientsPerEmail))
+	}
+
+	contentIds, unique := e.Attachments.inlineContentIds()
+	if !unique {
+		sl.ReportError(e.Attachments, "Attachments", "attachments", "unique_content_id", "")
+	}
+	for _, reference := range cidReferences(e.BodyHTML) {
+		if !contentIds[reference] {
+			sl.ReportError(e.BodyHTML, "BodyHTML", "body_html", "cid", reference)
+			break
+		}
+	}
+
+	if e.Unsubscribe != nil && hasListUnsubscribeHeader(e.CustomHeaders) {
+		sl.ReportError(e.CustomHeaders, "CustomHeaders", "custom_headers", "excluded_with", "Unsubscribe")
+	}
+
+	// every recipient gets the same message, so a served link could only unsubscribe all of them
+	if e.Unsubscribe != nil && e.Unsubscribe.OneClick && e.Unsubscribe.URL == "" && e.recipientCount() > 1 {
+		sl.ReportError(e.Unsubscribe, "Unsubscribe", "unsubscribe", "single_recipient", "")
+	}
+}
+
+func validateAttachment(sl validator.StructLevel) {
+	a := sl.Current().Interface().(Attachment)
+
+	switch {
+	case a.Path == "" && a.ContentBase64 == "":
+		sl.ReportError(a.Path, "Path", "path", "required", "")
+	case a.Path != "" && a.ContentBase64 != "":
+		sl.ReportError(a.ContentBase64, "ContentBase64", "content_base64", "excluded_with", "Path")
+	}
+}
+
+// newValidator returns the validator of the email payloads of a tenant, with the checks depending
+// on the handler options
+func (h *CreateEmailHandler) newValidator(tenant string) *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	// addresses may have UTF-8 local parts and internationalized domains, which the email tag rejects
+	_ = validate.RegisterValidation("email", mailbox.Validate)
+	validate.RegisterStructValidation(validateEmailDataInput, emailDataInput{})
+	validate.RegisterStructValidation(validateAttachment, Attachment{})
+	validate.RegisterStructValidation(validateUnsubscribe(h.unsubscribeLinker != nil), Unsubscribe{})
+	validate.RegisterStructValidation(validateCallback, Callback{})
+	_ = validate.RegisterValidation("callback_body_template", validateCallbackBodyTemplate)
+	_ = validate.RegisterValidation("header_name", validateHeaderName)
+	_ = validate.RegisterValidation("header_value", validateHeaderValue)
+	_ = validate.RegisterValidation("header_allowed", validateHeaderAllowed(h.headerPolicy))
+	_ = validate.RegisterValidation("verified_sender", validateVerifiedSender(h.senderRegistry, tenant))
+	return validate
 }
 
 type createEmailRequestBody struct {
 	Data []emailDataInput `json:"data" validate:"gt=0,dive,required"`
+	// Atomic saves all the emails or none of them
+	Atomic bool `json:"atomic"`
 }
 
 type CreateEmailResult struct {
-	ID     string       `json:"id"`
-	Status string       `json:"status"`
-	Error  *ErrorDetail `json:"error,omitempty"`
+	ID string `json:"id"`
+	// Line is the 1-based line of the item in NDJSON requests
+	Line            int          `json:"line,omitempty"`
+	Status          string       `json:"status"`
+	AlreadyAccepted bool         `json:"already_accepted,omitempty"`
+	Error           *ErrorDetail `json:"error,omitempty"`
 }
 
 type ErrorDetail struct {
@@ -70,6 +235,25 @@ type ErrorDetail struct {
 	Message string `json:"message,omitempty"`
 }
 
+func newCreateEmailResult(result SaveResult) CreateEmailResult {
+	emailResult := CreateEmailResult{
+		ID: result.MessageId,
+	}
+
+	if result.Success {
+		emailResult.Status = "success"
+		emailResult.AlreadyAccepted = result.AlreadyAccepted
+	} else {
+		emailResult.Status = "error"
+		emailResult.Error = &ErrorDetail{
+			Code:    result.ErrorCode,
+			Message: result.ErrorMessage,
+		}
+	}
+
+	return emailResult
+}
+
 type BatchEmailResponse struct {
 	Summary struct {
 		Total      int `json:"total"`
@@ -77,41 +261,230 @@ type BatchEmailResponse struct {
 		Failed     int `json:"failed"`
 	} `json:"summary"`
 	Results []CreateEmailResult `json:"results"`
+
+	alreadyAccepted int
+	// aborted is set for atomic batches with a failed email, where nothing was saved
+	aborted bool
+}
+
+func (b *BatchEmailResponse) add(result CreateEmailResult) {
+	b.Summary.Total++
+	if result.Error == nil {
+		b.Summary.Successful++
+		if result.AlreadyAccepted {
+			b.alreadyAccepted++
+		}
+	} else {
+		b.Summary.Failed++
+	}
+	b.Results = append(b.Results, result)
+}
+
+// writeBatchResponse writes the results of a request. Dry runs always get the batch details, since
+// nothing was created.
+func writeBatchResponse(w http.ResponseWriter, batchResponse BatchEmailResponse, dryRun bool) {
+	var statusCode int
+	var responseBody []byte
+
+	if batchResponse.Summary.Failed == 0 && batchResponse.alreadyAccepted == 0 && !dryRun {
+		// All succeeded - return 201 with empty body
+		statusCode = http.StatusCreated
+		responseBody = []byte("{}")
+	} else if batchResponse.Summary.Successful == 0 || batchResponse.aborted {
+		// None succeeded, or the atomic batch was not saved - return 422 with batch details
+		statusCode = http.StatusUnprocessableEntity
+		var err error
+		responseBody, err = json.Marshal(batchResponse)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error marshalling response: %v", err))
+			response.WriteError(http.StatusInternalServerError, w, "error creating response")
+			return
+		}
+	} else {
+		// At least one succeeded, or some were retries of accepted emails - return 200 with batch details
+		statusCode = http.StatusOK
+		var err error
+		responseBody, err = json.Marshal(batchResponse)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error marshalling response: %v", err))
+			response.WriteError(http.StatusInternalServerError, w, "error creating response")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(responseBody)
 }
 
 type serviceInterface interface {
 	Save(ctx context.Context, emailRequests []EmailRequest) []SaveResult
+	Validate(ctx context.Context, emailRequests []EmailRequest) []SaveResult
+	SaveAtomic(ctx context.Context, emailRequests []EmailRequest) []SaveResult
+	ValidateAtomic(ctx context.Context, emailRequests []EmailRequest) []SaveResult
+}
+
+type templateRendererInterface interface {
+	Render(ctx context.Context, id string, version int, variables map[string]any) (templates.Rendered, error)
 }
 
 type CreateEmailHandler struct {
-	emailService serviceInterface
+	emailService      serviceInterface
+	templateRenderer  templateRendererInterface
+	headerPolicy      *HeaderPolicy
+	unsubscribeLinker unsubscribeLinkerInterface
+	senderRegistry    *SenderRegistry
+	generatePlainText bool
+}
+
+type CreateEmailHandlerOption func(h *CreateEmailHandler)
+
+// WithTemplateRenderer enables emails built from stored templates through template_id
+func WithTemplateRenderer(templateRenderer templateRendererInterface) CreateEmailHandlerOption {
+	return func(h *CreateEmailHandler) {
+		h.templateRenderer = templateRenderer
+	}
+}
+
+// WithHeaderPolicy replaces the default policy of custom header names, which only denies DefaultDeniedHeaders
+func WithHeaderPolicy(headerPolicy *HeaderPolicy) CreateEmailHandlerOption {
+	return func(h *CreateEmailHandler) {
+		h.headerPolicy = headerPolicy
+	}
+}
+
+// WithUnsubscribeLinks serves the one-click links of emails with an unsubscribe block without URL
+func WithUnsubscribeLinks(unsubscribeLinker unsubscribeLinkerInterface) CreateEmailHandlerOption {
+	return func(h *CreateEmailHandler) {
+		h.unsubscribeLinker = unsubscribeLinker
+	}
+}
+
+// WithSenderRegistry rejects emails whose from is not a verified sender identity, and fills a
+// missing reply_to from the identity
+func WithSenderRegistry(senderRegistry *SenderRegistry) CreateEmailHandlerOption {
+	return func(h *CreateEmailHandler) {
+		h.senderRegistry = senderRegistry
+	}
 }
 
-func NewCreateEmailHandler(emailService serviceInterface) *CreateEmailHandler {
-	return &CreateEmailHandler{
+// WithPlainTextGeneration derives body_text from body_html for emails without body_text, unless
+// they set generate_text to false
+func WithPlainTextGeneration() CreateEmailHandlerOption {
+	return func(h *CreateEmailHandler) {
+		h.generatePlainText = true
+	}
+}
+
+func NewCreateEmailHandler(emailService serviceInterface, opts ...CreateEmailHandlerOption) *CreateEmailHandler {
+	h := &CreateEmailHandler{
 		emailService: emailService,
+		headerPolicy: NewHeaderPolicy(nil, nil),
 	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
 }
 
-func (h *CreateEmailHandler) emailRequestsFromBody(rb createEmailRequestBody) ([]EmailRequest, error) {
+// errTemplateRequest marks template errors caused by the request rather than by the server
+var errTemplateRequest = errors.New("invalid template request")
+
+// renderTemplate fills subject and bodies of emails referencing a template, and pins the rendered version
+func (h *CreateEmailHandler) renderTemplate(ctx context.Context, e *emailDataInput) error {
+	if e.TemplateId == "" {
+		return nil
+	}
+
+	if e.Subject != "" || e.BodyHTML != "" || e.BodyText != "" {
+		return fmt.Errorf("%w: template_id cannot be combined with subject, body_html or body_text", errTemplateRequest)
+	}
+
+	if h.templateRenderer == nil {
+		return fmt.Errorf("%w: templates are not enabled", errTemplateRequest)
+	}
+
+	rendered, err := h.templateRenderer.Render(ctx, e.TemplateId, e.TemplateVersion, e.Variables)
+	if err != nil {
+		if errors.Is(err, templates.ErrTemplateNotFound) || errors.Is(err, templates.ErrInvalidVariables) {
+			return fmt.Errorf("%w: %w", errTemplateRequest, err)
+		}
+		return err
+	}
+
+	e.TemplateVersion = rendered.Version
+	e.Subject = rendered.Subject
+	e.BodyHTML = rendered.BodyHTML
+	e.BodyText = rendered.BodyText
+
+	return nil
+}
+
+func (h *CreateEmailHandler) emailRequestFromInput(ctx context.Context, e emailDataInput) (EmailRequest, error) {
+	e.CallbackOnSuccess = e.CallbackOnSuccess.withDefaults()
+	e.CallbackOnFailure = e.CallbackOnFailure.withDefaults()
+	e.SMTPUTF8 = e.needsSMTPUTF8()
+
+	if e.Unsubscribe != nil {
+		headers, err := h.unsubscribeHeaders(ctx, e)
+		if err != nil {
+			return EmailRequest{}, fmt.Errorf("failed to create unsubscribe headers: %w", err)
+		}
+
+		customHeaders := make(map[string]string, len(e.CustomHeaders)+len(headers))
+		maps.Copy(customHeaders, e.CustomHeaders)
+		maps.Copy(customHeaders, headers)
+		e.CustomHeaders = customHeaders
+	}
+
+	payloadBytes, err := json.Marshal(e)
+	if err != nil {
+		return EmailRequest{}, fmt.Errorf("failed to marshal single email payload: %w", err)
+	}
+
+	return EmailRequest{
+		MessageId:    e.Id,
+		PayloadBytes: payloadBytes,
+		SendAt:       e.SendAt,
+		Priority:     e.Priority,
+		Tags:         e.Tags,
+		Metadata:     e.Metadata,
+		Recipients:   e.recipientAddresses(),
+	}, nil
+}
+
+func (h *CreateEmailHandler) emailRequestsFromBody(ctx context.Context, rb createEmailRequestBody) ([]EmailRequest, error) {
 	emailRequests := make([]EmailRequest, len(rb.Data))
 
 	for i, e := range rb.Data {
-		payloadBytes, err := json.Marshal(e)
+		emailRequest, err := h.emailRequestFromInput(ctx, e)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal single email payload: %w", err)
+			return nil, err
 		}
 
-		emailRequests[i] = EmailRequest{
-			MessageId:    e.Id,
-			PayloadBytes: payloadBytes,
-		}
+		emailRequests[i] = emailRequest
 	}
 
 	return emailRequests, nil
 }
 
 func (h *CreateEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, err.Error())
+		return
+	}
+	if dryRun {
+		w.Header().Set("Preference-Applied", preferDryRun)
+	}
+
+	if isNDJSONRequest(r) {
+		h.serveNDJSON(w, r, dryRun)
+		return
+	}
+
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error reading request body: %v", err))
@@ -130,76 +503,51 @@ func (h *CreateEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	validate := validator.New(validator.WithRequiredStructEnabled())
+	tenant := auth.TenantOf(r.Context())
+
+	for i := range requestBody.Data {
+		if err := h.renderTemplate(r.Context(), &requestBody.Data[i]); err != nil {
+			if errors.Is(err, errTemplateRequest) {
+				response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error rendering template of data[%d]: %v", i, err))
+				return
+			}
+			slog.Error(fmt.Sprintf("error rendering template: %v", err))
+			response.WriteError(http.StatusInternalServerError, w, "error rendering template")
+			return
+		}
+		h.applySenderDefaults(tenant, &requestBody.Data[i])
+		h.applyPlainText(&requestBody.Data[i])
+	}
+
+	validate := h.newValidator(tenant)
 
 	if err := validate.Struct(requestBody); err != nil {
 		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating request body: %v", err))
 		return
 	}
 
-	emailRequests, err := h.emailRequestsFromBody(requestBody)
+	emailRequests, err := h.emailRequestsFromBody(r.Context(), requestBody)
 	if err != nil {
 		slog.Error(fmt.Sprintf("error creating email requests: %v", err))
 		response.WriteError(http.StatusInternalServerError, w, "error creating email requests")
 		return
 	}
 
-	saveResults := h.emailService.Save(context.TODO(), emailRequests)
-
-	var batchResponse BatchEmailResponse
-	batchResponse.Summary.Total = len(saveResults)
-	batchResponse.Results = make([]CreateEmailResult, len(saveResults))
-
-	for i, result := range saveResults {
-		emailResult := CreateEmailResult{
-			ID: result.MessageId,
-		}
-
-		if result.Success {
-			emailResult.Status = "success"
-			batchResponse.Summary.Successful++
-		} else {
-			emailResult.Status = "error"
-			emailResult.Error = &ErrorDetail{
-				Code:    result.ErrorCode,
-				Message: result.ErrorMessage,
-			}
-			batchResponse.Summary.Failed++
-		}
-
-		batchResponse.Results[i] = emailResult
+	save := h.saver(dryRun)
+	if requestBody.Atomic {
+		save = h.atomicSaver(dryRun)
 	}
 
-	var statusCode int
-	var responseBody []byte
+	saveResults := save(r.Context(), emailRequests)
 
-	if batchResponse.Summary.Failed == 0 {
-		// All succeeded - return 201 with empty body
-		statusCode = http.StatusCreated
-		responseBody = []byte("{}")
-	} else if batchResponse.Summary.Successful == 0 {
-		// None succeeded - return 422 with batch details
-		statusCode = http.StatusUnprocessableEntity
-		var err error
-		responseBody, err = json.Marshal(batchResponse)
-		if err != nil {
-			slog.Error(fmt.Sprintf("error marshalling response: %v", err))
-			response.WriteError(http.StatusInternalServerError, w, "error creating response")
-			return
-		}
-	} else {
-		// At least one succeeded - return 200 with batch details
-		statusCode = http.StatusOK
-		var err error
-		responseBody, err = json.Marshal(batchResponse)
-		if err != nil {
-			slog.Error(fmt.Sprintf("error marshalling response: %v", err))
-			response.WriteError(http.StatusInternalServerError, w, "error creating response")
-			return
-		}
+	var batchResponse BatchEmailResponse
+	batchResponse.Results = make([]CreateEmailResult, 0, len(saveResults))
+
+	for _, result := range saveResults {
+		batchResponse.add(newCreateEmailResult(result))
 	}
+	// the emails accepted before an aborted batch still succeed, but the batch did not
+	batchResponse.aborted = requestBody.Atomic && batchResponse.Summary.Failed > 0
 
-	w.WriteHeader(statusCode)
-	w.Header().Set("Content-Type", "application/json")
-	_, _ = w.Write(responseBody)
+	writeBatchResponse(w, batchResponse, dryRun)
 }
@@ -0,0 +1,161 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/response"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// maxNDJSONLineBytes is the maximum size of a single email in NDJSON requests
+const maxNDJSONLineBytes = 10 << 20
+
+// Per-item error codes of NDJSON requests, where a bad line does not reject the whole request
+const (
+	ErrorCodeInvalidPayload  = "INVALID_PAYLOAD"
+	ErrorCodeValidationError = "VALIDATION_ERROR"
+	ErrorCodeTemplateError   = "TEMPLATE_ERROR"
+)
+
+func isNDJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == ndjsonContentType
+}
+
+// acceptsNDJSON tells whether the client asked for per-line results instead of a summary
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err == nil && mediaType == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+func newLineErrorResult(id string, code string, message string) CreateEmailResult {
+	return CreateEmailResult{
+		ID:     id,
+		Status: "error",
+		Error: &ErrorDetail{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// saveLine decodes, validates and saves a single NDJSON line
+func (h *CreateEmailHandler) saveLine(r *http.Request, validate *validator.Validate, save saveFunc, line []byte) CreateEmailResult {
+	var e emailDataInput
+	if err := json.Unmarshal(line, &e); err != nil {
+		return newLineErrorResult("", ErrorCodeInvalidPayload, fmt.Sprintf("error unmarshalling line: %v", err))
+	}
+
+	if err := h.renderTemplate(r.Context(), &e); err != nil {
+		if errors.Is(err, errTemplateRequest) {
+			return newLineErrorResult(e.Id, ErrorCodeTemplateError, fmt.Sprintf("error rendering template: %v", err))
+		}
+		slog.Error(fmt.Sprintf("error rendering template: %v", err))
+		return newLineErrorResult(e.Id, ErrorCodeTemplateError, "error rendering template")
+	}
+
+	h.applySenderDefaults(auth.TenantOf(r.Context()), &e)
+	h.applyPlainText(&e)
+
+	if err := validate.Struct(e); err != nil {
+		return newLineErrorResult(e.Id, ErrorCodeValidationError, fmt.Sprintf("error validating line: %v", err))
+	}
+
+	emailRequest, err := h.emailRequestFromInput(r.Context(), e)
+	if err != nil {
+		slog.Error(fmt.Sprintf("error creating email request: %v", err))
+		return newLineErrorResult(e.Id, ErrorCodeInvalidPayload, "error creating email request")
+	}
+
+	saveResults := save(r.Context(), []EmailRequest{emailRequest})
+
+	return newCreateEmailResult(saveResults[0])
+}
+
+// serveNDJSON saves one email per line as the body is read, so that large batches are never held
+// in memory. Results are streamed back one per line when the client accepts application/x-ndjson,
+// otherwise a BatchEmailResponse summary is returned once the body is consumed.
+func (h *CreateEmailHandler) serveNDJSON(w http.ResponseWriter, r *http.Request, dryRun bool) {
+	stream := acceptsNDJSON(r)
+	validate := h.newValidator(auth.TenantOf(r.Context()))
+	save := h.lineSaver(dryRun)
+
+	var batchResponse BatchEmailResponse
+	var encoder *json.Encoder
+	var controller *http.ResponseController
+
+	if stream {
+		controller = http.NewResponseController(w)
+		// HTTP/1.x stops reading the request body once the response is written, unless full duplex is enabled
+		_ = controller.EnableFullDuplex()
+
+		w.Header().Set("Content-Type", ndjsonContentType)
+		w.WriteHeader(http.StatusOK)
+		encoder = json.NewEncoder(w)
+	}
+
+	emit := func(result CreateEmailResult) {
+		if !stream {
+			batchResponse.add(result)
+			return
+		}
+
+		if err := encoder.Encode(result); err != nil {
+			slog.Error(fmt.Sprintf("error writing result line: %v", err))
+			return
+		}
+		_ = controller.Flush()
+	}
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLineBytes)
+
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		result := h.saveLine(r, validate, save, line)
+		result.Line = lineNumber
+		emit(result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		// the rest of the body cannot be read, lines already processed are kept
+		result := newLineErrorResult("", ErrorCodeInvalidPayload, fmt.Sprintf("error reading request body: %v", err))
+		result.Line = lineNumber + 1
+		emit(result)
+	}
+
+	if stream {
+		return
+	}
+
+	if batchResponse.Summary.Total == 0 {
+		response.WriteError(http.StatusBadRequest, w, "request body contains no emails")
+		return
+	}
+
+	writeBatchResponse(w, batchResponse, dryRun)
+}
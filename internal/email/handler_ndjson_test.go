@@ -0,0 +1,214 @@
+package email
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONSummary(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/batch.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"summary": {
+			"total": 4,
+			"successful": 2,
+			"failed": 2
+		},
+		"results": [
+			{
+				"id": "65ed6bfa-063c-5219-844d-e099c88a17f4",
+				"line": 1,
+				"status": "success"
+			},
+			{
+				"id": "",
+				"line": 3,
+				"status": "error",
+				"error": {
+					"code": "INVALID_PAYLOAD",
+					"message": "error unmarshalling line: unexpected end of JSON input"
+				}
+			},
+			{
+				"id": "a1b2c3d4-0000-4000-8000-000000000001",
+				"line": 4,
+				"status": "error",
+				"error": {
+					"code": "VALIDATION_ERROR",
+					"message": "error validating line: Key: 'emailDataInput.BodyHTML' Error:Field validation for 'BodyHTML' failed on the 'required_without' tag\nKey: 'emailDataInput.BodyText' Error:Field validation for 'BodyText' failed on the 'required_without' tag"
+				}
+			},
+			{
+				"id": "ff0fb587-e29b-4278-bbab-a525196b8917",
+				"line": 5,
+				"status": "success"
+			}
+		]
+	}`, response.Body.String())
+
+	assert.Len(t, service.requests, 2, "only valid lines are saved, one at a time")
+}
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONStream(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/batch.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	request.Header.Set("Accept", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock([]SaveResult{
+		{MessageId: "65ed6bfa-063c-5219-844d-e099c88a17f4", Success: false, ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessageDuplicatedID},
+	})
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/x-ndjson", response.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(response.Body.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.JSONEq(t, `{"id": "65ed6bfa-063c-5219-844d-e099c88a17f4", "line": 1, "status": "error", "error": {"code": "DUPLICATED_ID", "message": "Email with this ID already exists"}}`, lines[0])
+	assert.Contains(t, lines[1], `"code":"INVALID_PAYLOAD"`)
+	assert.Contains(t, lines[2], `"code":"VALIDATION_ERROR"`)
+	assert.Contains(t, lines[3], `"line":5`)
+}
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONEmptyBody(t *testing.T) {
+	t.Parallel()
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("\n\n"))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error": "request body contains no emails"}`, response.Body.String())
+}
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONLineTooLong(t *testing.T) {
+	t.Parallel()
+
+	validLine := `{"id": "65ed6bfa-063c-5219-844d-e099c88a17f4", "from": "sender@example.com", "reply_to": "reply-to@example.com", "to": "example@example.com", "subject": "Test Subject", "body_text": "Hello, World!"}`
+	tooLongLine := `{"body_text": "` + strings.Repeat("a", maxNDJSONLineBytes) + `"}`
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(validLine+"\n"+tooLongLine+"\n"))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"line":2`)
+	assert.Contains(t, response.Body.String(), "token too long")
+	assert.Len(t, service.requests, 1)
+}
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONDryRun(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/batch.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/?dry_run=true", bytes.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "dry-run", response.Header().Get("Preference-Applied"))
+	assert.Len(t, service.validated, 2)
+	assert.Empty(t, service.requests, "nothing is saved")
+}
+
+func TestCreateEmailHandler_ServeHTTP_NDJSONDryRunDuplicateId(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/batch-duplicate-id.ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/?dry_run=true", bytes.NewReader(requestBody))
+	request.Header.Set("Content-Type", "application/x-ndjson")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{
+		"summary": {
+			"total": 3,
+			"successful": 2,
+			"failed": 1
+		},
+		"results": [
+			{
+				"id": "65ed6bfa-063c-5219-844d-e099c88a17f4",
+				"line": 1,
+				"status": "success"
+			},
+			{
+				"id": "65ed6bfa-063c-5219-844d-e099c88a17f4",
+				"line": 2,
+				"status": "success",
+				"already_accepted": true
+			},
+			{
+				"id": "65ed6bfa-063c-5219-844d-e099c88a17f4",
+				"line": 3,
+				"status": "error",
+				"error": {
+					"code": "DUPLICATED_ID",
+					"message": "Email with this ID already exists with a different payload"
+				}
+			}
+		]
+	}`, response.Body.String())
+	assert.Len(t, service.validated, 1, "the repeated ID is resolved against the first line")
+	assert.Empty(t, service.requests, "nothing is saved")
+}
@@ -3,16 +3,28 @@ package email
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/templates"
+	"multicarrier-email-api/internal/unsubscribe"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type emailServiceMock struct {
-	results []SaveResult
+	results   []SaveResult
+	requests  []EmailRequest
+	validated []EmailRequest
+	// atomic is set when the requests were saved or validated as an atomic batch
+	atomic bool
 }
 
 func newEmailServiceMock(results []SaveResult) *emailServiceMock {
@@ -20,6 +32,26 @@ func newEmailServiceMock(results []SaveResult) *emailServiceMock {
 }
 
 func (m *emailServiceMock) Save(_ context.Context, requests []EmailRequest) []SaveResult {
+	m.requests = append(m.requests, requests...)
+	return m.resultsOf(requests)
+}
+
+func (m *emailServiceMock) Validate(_ context.Context, requests []EmailRequest) []SaveResult {
+	m.validated = append(m.validated, requests...)
+	return m.resultsOf(requests)
+}
+
+func (m *emailServiceMock) SaveAtomic(ctx context.Context, requests []EmailRequest) []SaveResult {
+	m.atomic = true
+	return m.Save(ctx, requests)
+}
+
+func (m *emailServiceMock) ValidateAtomic(ctx context.Context, requests []EmailRequest) []SaveResult {
+	m.atomic = true
+	return m.Validate(ctx, requests)
+}
+
+func (m *emailServiceMock) resultsOf(requests []EmailRequest) []SaveResult {
 	if m.results != nil {
 		return m.results
 	}
@@ -130,6 +162,33 @@ func TestCreateEmailHandler_ServeHTTP(t *testing.T) {
 				]
 			}`,
 		},
+		{
+			name: "all emails succeeded, one was already accepted - 200",
+			serviceResults: []SaveResult{
+				{MessageId: "65ed6bfa-063c-5219-844d-e099c88a17f4", Success: true},
+				{MessageId: "ff0fb587-e29b-4278-bbab-a525196b8917", Success: true, AlreadyAccepted: true},
+			},
+			payloadFilePath:    "testdata/handler_test/payloads/valid.json",
+			expectedStatusCode: http.StatusOK,
+			expectedBody: `{
+				"summary": {
+					"total": 2,
+					"successful": 2,
+					"failed": 0
+				},
+				"results": [
+					{
+						"id": "65ed6bfa-063c-5219-844d-e099c88a17f4",
+						"status": "success"
+					},
+					{
+						"id": "ff0fb587-e29b-4278-bbab-a525196b8917",
+						"status": "success",
+						"already_accepted": true
+					}
+				]
+			}`,
+		},
 		{
 			name:               "invalid json - 400",
 			serviceResults:     nil,
@@ -172,6 +231,188 @@ func TestCreateEmailHandler_ServeHTTP(t *testing.T) {
 			expectedStatusCode: http.StatusCreated,
 			expectedBody:       "{}",
 		},
+		{
+			name:               "duplicated tags - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/duplicated-tags.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Tags' Error:Field validation for 'Tags' failed on the 'unique' tag"}`,
+		},
+		{
+			name:               "metadata key with spaces - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-metadata-key.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Metadata[invoice id]' Error:Field validation for 'Metadata[invoice id]' failed on the 'excludesall' tag"}`,
+		},
+		{
+			name:               "invalid priority - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-priority.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Priority' Error:Field validation for 'Priority' failed on the 'oneof' tag"}`,
+		},
+		{
+			name:               "uploaded attachment content - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/uploaded-attachment.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "attachment with both path and content - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/attachment-path-and-content.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Attachments[0].ContentBase64' Error:Field validation for 'ContentBase64' failed on the 'excluded_with' tag"}`,
+		},
+		{
+			name:               "attachment content not base64 - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/attachment-invalid-base64.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Attachments[0].ContentBase64' Error:Field validation for 'ContentBase64' failed on the 'base64' tag"}`,
+		},
+		{
+			name:               "attachment too large - 422",
+			serviceResults:     []SaveResult{{MessageId: "65ed6bfa-063c-5219-844d-e099c88a17f4", Success: false, ErrorCode: ErrorCodeAttachmentTooLarge, ErrorMessage: "attachment too large: attachments[0] is 13 bytes, the limit is 10"}},
+			payloadFilePath:    "testdata/handler_test/payloads/uploaded-attachment.json",
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"summary": {"total": 1, "successful": 0, "failed": 1}, "results": [{"id": "65ed6bfa-063c-5219-844d-e099c88a17f4", "status": "error", "error": {"code": "ATTACHMENT_TOO_LARGE", "message": "attachment too large: attachments[0] is 13 bytes, the limit is 10"}}]}`,
+		},
+		{
+			name:               "inline images - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/inline-images.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "inline attachment missing content id - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/inline-missing-content-id.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Attachments[0].ContentId' Error:Field validation for 'ContentId' failed on the 'required_if' tag"}`,
+		},
+		{
+			name:               "invalid attachment disposition - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-disposition.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Attachments[0].Disposition' Error:Field validation for 'Disposition' failed on the 'oneof' tag"}`,
+		},
+		{
+			name:               "cid reference to a regular attachment - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/unresolved-cid.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].BodyHTML' Error:Field validation for 'BodyHTML' failed on the 'cid' tag"}`,
+		},
+		{
+			name:               "duplicated content id - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/duplicated-content-id.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Attachments' Error:Field validation for 'Attachments' failed on the 'unique_content_id' tag"}`,
+		},
+		{
+			name:               "multiple recipients with cc and bcc - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/multiple-recipients.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "invalid cc address - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-cc.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Cc[1].Address' Error:Field validation for 'Address' failed on the 'email' tag"}`,
+		},
+		{
+			name:               "empty recipients - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/empty-recipients.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].To' Error:Field validation for 'To' failed on the 'min' tag"}`,
+		},
+		{
+			name:               "too many recipients - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/too-many-recipients.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].To' Error:Field validation for 'To' failed on the 'max_recipients' tag"}`,
+		},
+		{
+			name:               "display names - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/display-names.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "invalid address in object form - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-display-name-address.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].From.Address' Error:Field validation for 'Address' failed on the 'email' tag"}`,
+		},
+		{
+			name:               "structured callbacks - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/callbacks.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "invalid callbacks - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/callbacks-invalid.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].CallbackOnSuccess.URL' Error:Field validation for 'URL' failed on the 'http_url' tag\nKey: 'createEmailRequestBody.Data[0].CallbackOnSuccess.Method' Error:Field validation for 'Method' failed on the 'oneof' tag\nKey: 'createEmailRequestBody.Data[0].CallbackOnFailure.BodyTemplate' Error:Field validation for 'BodyTemplate' failed on the 'callback_body_template' tag"}`,
+		},
+		{
+			name:               "legacy callback with unsupported option - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/callbacks-legacy-unsupported.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error unmarshalling request body: invalid callback command: option --insecure is not supported, the TLS certificates are always verified"}`,
+		},
+		{
+			name:               "custom headers - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/custom-headers.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "invalid custom headers - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-custom-headers.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].CustomHeaders[From]' Error:Field validation for 'CustomHeaders[From]' failed on the 'header_allowed' tag\nKey: 'createEmailRequestBody.Data[1].CustomHeaders[X Campaign]' Error:Field validation for 'CustomHeaders[X Campaign]' failed on the 'header_name' tag\nKey: 'createEmailRequestBody.Data[2].CustomHeaders[X-Campaign]' Error:Field validation for 'CustomHeaders[X-Campaign]' failed on the 'header_value' tag"}`,
+		},
+		{
+			name:               "unsubscribe - 201",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/unsubscribe.json",
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       "{}",
+		},
+		{
+			name:               "one-click unsubscribe without links served - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/unsubscribe-one-click-link.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Unsubscribe.URL' Error:Field validation for 'URL' failed on the 'required_with' tag"}`,
+		},
+		{
+			name:               "invalid unsubscribe - 400",
+			serviceResults:     nil,
+			payloadFilePath:    "testdata/handler_test/payloads/invalid-unsubscribe.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Unsubscribe.URL' Error:Field validation for 'URL' failed on the 'required_without' tag\nKey: 'createEmailRequestBody.Data[1].Unsubscribe.URL' Error:Field validation for 'URL' failed on the 'startswith' tag\nKey: 'createEmailRequestBody.Data[2].CustomHeaders' Error:Field validation for 'CustomHeaders' failed on the 'excluded_with' tag"}`,
+		},
 		{
 			name:               "legacy format with invalid URI - 400",
 			serviceResults:     nil,
@@ -201,3 +442,836 @@ func TestCreateEmailHandler_ServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateEmailHandler_ServeHTTP_StoresAllRecipients(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/multiple-recipients.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+
+	var stored map[string]any
+	assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+	assert.Equal(t, []any{"first@example.com", "second@example.com"}, stored["to"])
+	assert.Equal(t, []any{"copy@example.com"}, stored["cc"])
+	assert.Equal(t, []any{"hidden@example.com"}, stored["bcc"])
+}
+
+func TestCreateEmailHandler_ServeHTTP_NormalizesDisplayNames(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/display-names.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+
+	var stored map[string]any
+	assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+	assert.Equal(t, `"Acme Billing" <billing@acme.com>`, stored["from"])
+	assert.Equal(t, `"Acme Support" <support@acme.com>`, stored["reply_to"])
+	assert.Equal(t, []any{`"Jane Doe" <jane@example.com>`, `"John Doe" <john@example.com>`, "plain@example.com"}, stored["to"])
+}
+
+func TestCreateEmailHandler_ServeHTTP_InternationalAddresses(t *testing.T) {
+	t.Parallel()
+
+	t.Run("domains are converted to punycode", func(t *testing.T) {
+		requestBody, err := os.ReadFile("testdata/handler_test/payloads/international.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+		response := httptest.NewRecorder()
+
+		service := newEmailServiceMock(nil)
+		sut := NewCreateEmailHandler(service)
+
+		sut.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusCreated, response.Code)
+		assert.Len(t, service.requests, 2)
+
+		var stored map[string]any
+		assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+		assert.Equal(t, "=?utf-8?q?B=C3=BCcherei_M=C3=BCller?= <info@xn--bcher-kva.example>", stored["from"])
+		assert.Equal(t, []any{"=?utf-8?q?J=C3=B6rg_Sch=C3=A4fer?= <jörg@xn--mller-kva.de>", "anna@xn--strae-oqa.de"}, stored["to"])
+		assert.Equal(t, "Però la città è già più bella", stored["subject"])
+		assert.Equal(t, true, stored["smtputf8"])
+
+		var storedASCII map[string]any
+		assert.NoError(t, json.Unmarshal(service.requests[1].PayloadBytes, &storedASCII))
+		assert.Equal(t, []any{"user@xn--mller-kva.de"}, storedASCII["to"])
+		assert.NotContains(t, storedASCII, "smtputf8")
+	})
+
+	t.Run("invalid internationalized domain", func(t *testing.T) {
+		requestBody, err := os.ReadFile("testdata/handler_test/payloads/invalid-idn.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+		response := httptest.NewRecorder()
+
+		service := newEmailServiceMock(nil)
+		sut := NewCreateEmailHandler(service)
+
+		sut.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusBadRequest, response.Code)
+		assert.JSONEq(t, `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].To[0].Address' Error:Field validation for 'Address' failed on the 'email' tag"}`, response.Body.String())
+		assert.Empty(t, service.requests)
+	})
+}
+
+func TestCreateEmailHandler_ServeHTTP_PassesSendAt(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/scheduled.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+	assert.NotNil(t, service.requests[0].SendAt)
+	assert.True(t, time.Date(2030, 1, 1, 8, 0, 0, 0, time.UTC).Equal(*service.requests[0].SendAt))
+}
+
+func TestCreateEmailHandler_ServeHTTP_PassesPriority(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/priority.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+	assert.Equal(t, PriorityHigh, service.requests[0].Priority)
+}
+
+func TestCreateEmailHandler_ServeHTTP_PassesTagsAndMetadata(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/tags-and-metadata.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+	assert.Equal(t, []string{"invoice", "2024"}, service.requests[0].Tags)
+	assert.Equal(t, map[string]string{"invoice_id": "INV-42", "tenant_code": "acme"}, service.requests[0].Metadata)
+}
+
+func TestCreateEmailHandler_ServeHTTP_PassesRecipients(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/multiple-recipients.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+	assert.Equal(t, []string{"first@example.com", "second@example.com", "copy@example.com", "hidden@example.com"}, service.requests[0].Recipients)
+}
+
+func TestCreateEmailHandler_ServeHTTP_HeaderPolicy(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/custom-headers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service, WithHeaderPolicy(NewHeaderPolicy(nil, []string{"X-"})))
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].CustomHeaders[List-Id]' Error:Field validation for 'CustomHeaders[List-Id]' failed on the 'header_allowed' tag"}`, response.Body.String())
+	assert.Empty(t, service.requests)
+}
+
+func TestCreateEmailHandler_ServeHTTP_DryRun(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name    string
+		target  string
+		prefer  string
+		results []SaveResult
+		code    int
+		body    string
+	}
+
+	cases := []caseStruct{
+		{
+			name:   "dry_run query parameter",
+			target: "/?dry_run=true",
+			code:   http.StatusOK,
+			body:   `{"summary":{"total":1,"successful":1,"failed":0},"results":[{"id":"6f1c2b1e-6a0e-4f39-9a57-0d7c1c4b8a04","status":"success"}]}`,
+		},
+		{
+			name:   "Prefer header",
+			target: "/",
+			prefer: "return=minimal, dry-run",
+			code:   http.StatusOK,
+			body:   `{"summary":{"total":1,"successful":1,"failed":0},"results":[{"id":"6f1c2b1e-6a0e-4f39-9a57-0d7c1c4b8a04","status":"success"}]}`,
+		},
+		{
+			name:    "failed checks",
+			target:  "/?dry_run=1",
+			results: []SaveResult{{MessageId: "6f1c2b1e-6a0e-4f39-9a57-0d7c1c4b8a04", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: example@example.com"}},
+			code:    http.StatusUnprocessableEntity,
+			body:    `{"summary":{"total":1,"successful":0,"failed":1},"results":[{"id":"6f1c2b1e-6a0e-4f39-9a57-0d7c1c4b8a04","status":"error","error":{"code":"RECIPIENT_SUPPRESSED","message":"Recipients are suppressed: example@example.com"}}]}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile("testdata/handler_test/payloads/custom-headers.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, c.target, bytes.NewReader(requestBody))
+			if c.prefer != "" {
+				request.Header.Set("Prefer", c.prefer)
+			}
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(c.results)
+			sut := NewCreateEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, c.code, response.Code)
+			assert.JSONEq(t, c.body, response.Body.String())
+			assert.Equal(t, "dry-run", response.Header().Get("Preference-Applied"))
+			assert.Len(t, service.validated, 1)
+			assert.Empty(t, service.requests, "nothing is saved")
+		})
+	}
+}
+
+func TestCreateEmailHandler_ServeHTTP_Atomic(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name           string
+		payload        string
+		target         string
+		results        []SaveResult
+		code           int
+		body           string
+		expectedAtomic bool
+	}
+
+	cases := []caseStruct{
+		{
+			name:           "atomic batch",
+			payload:        "atomic.json",
+			target:         "/",
+			code:           http.StatusCreated,
+			body:           `{}`,
+			expectedAtomic: true,
+		},
+		{
+			name:    "aborted atomic batch",
+			payload: "atomic.json",
+			target:  "/",
+			results: []SaveResult{
+				{MessageId: "4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45", ErrorCode: ErrorCodeBatchAborted, ErrorMessage: "Batch not saved because email 5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56 failed"},
+				{MessageId: "5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: second-signer@example.com"},
+			},
+			code:           http.StatusUnprocessableEntity,
+			body:           `{"summary":{"total":2,"successful":0,"failed":2},"results":[{"id":"4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45","status":"error","error":{"code":"BATCH_ABORTED","message":"Batch not saved because email 5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56 failed"}},{"id":"5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56","status":"error","error":{"code":"RECIPIENT_SUPPRESSED","message":"Recipients are suppressed: second-signer@example.com"}}]}`,
+			expectedAtomic: true,
+		},
+		{
+			name:    "aborted atomic batch with an already accepted email",
+			payload: "atomic.json",
+			target:  "/",
+			results: []SaveResult{
+				{MessageId: "4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45", Success: true, AlreadyAccepted: true},
+				{MessageId: "5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: second-signer@example.com"},
+			},
+			code:           http.StatusUnprocessableEntity,
+			body:           `{"summary":{"total":2,"successful":1,"failed":1},"results":[{"id":"4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45","status":"success","already_accepted":true},{"id":"5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56","status":"error","error":{"code":"RECIPIENT_SUPPRESSED","message":"Recipients are suppressed: second-signer@example.com"}}]}`,
+			expectedAtomic: true,
+		},
+		{
+			name:    "aborted dry run of an atomic batch with an already accepted email",
+			payload: "atomic.json",
+			target:  "/?dry_run=true",
+			results: []SaveResult{
+				{MessageId: "4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45", Success: true, AlreadyAccepted: true},
+				{MessageId: "5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: second-signer@example.com"},
+			},
+			code:           http.StatusUnprocessableEntity,
+			body:           `{"summary":{"total":2,"successful":1,"failed":1},"results":[{"id":"4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45","status":"success","already_accepted":true},{"id":"5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56","status":"error","error":{"code":"RECIPIENT_SUPPRESSED","message":"Recipients are suppressed: second-signer@example.com"}}]}`,
+			expectedAtomic: true,
+		},
+		{
+			name:           "dry run of an atomic batch",
+			payload:        "atomic.json",
+			target:         "/?dry_run=true",
+			code:           http.StatusOK,
+			body:           `{"summary":{"total":2,"successful":2,"failed":0},"results":[{"id":"4d0f1a2b-5c6e-4f7a-9b8c-9d0e1f2a3b45","status":"success"},{"id":"5e1a2b3c-6d7f-4a8b-8c9d-0e1f2a3b4c56","status":"success"}]}`,
+			expectedAtomic: true,
+		},
+		{
+			name:    "batches are not atomic by default",
+			payload: "multiple-recipients.json",
+			target:  "/",
+			code:    http.StatusCreated,
+			body:    `{}`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile("testdata/handler_test/payloads/" + c.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, c.target, bytes.NewReader(requestBody))
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(c.results)
+			sut := NewCreateEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, c.code, response.Code)
+			assert.JSONEq(t, c.body, response.Body.String())
+			assert.Equal(t, c.expectedAtomic, service.atomic)
+		})
+	}
+}
+
+func TestCreateEmailHandler_ServeHTTP_InvalidDryRun(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/custom-headers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/?dry_run=maybe", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error": "dry_run must be true or false"}`, response.Body.String())
+	assert.Empty(t, service.validated)
+	assert.Empty(t, service.requests)
+}
+
+func TestCreateEmailHandler_ServeHTTP_SenderIdentities(t *testing.T) {
+	t.Parallel()
+
+	registry := NewSenderRegistry([]SenderIdentity{
+		{Domain: "acme.com", ReplyTo: "support@acme.com"},
+	})
+
+	t.Run("unverified sender", func(t *testing.T) {
+		requestBody, err := os.ReadFile("testdata/handler_test/payloads/unverified-sender.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+		response := httptest.NewRecorder()
+
+		service := newEmailServiceMock(nil)
+		sut := NewCreateEmailHandler(service, WithSenderRegistry(registry))
+
+		sut.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusBadRequest, response.Code)
+		assert.JSONEq(t, `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].From' Error:Field validation for 'From' failed on the 'verified_sender' tag"}`, response.Body.String())
+		assert.Empty(t, service.requests)
+	})
+
+	t.Run("reply_to defaults to the identity", func(t *testing.T) {
+		requestBody, err := os.ReadFile("testdata/handler_test/payloads/sender-identities.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+		response := httptest.NewRecorder()
+
+		service := newEmailServiceMock(nil)
+		sut := NewCreateEmailHandler(service, WithSenderRegistry(registry))
+
+		sut.ServeHTTP(response, request)
+
+		assert.Equal(t, http.StatusCreated, response.Code)
+		assert.Len(t, service.requests, 2)
+
+		expectedReplyTo := []string{"support@acme.com", "accounts@acme.com"}
+		for i, expected := range expectedReplyTo {
+			var stored map[string]any
+			assert.NoError(t, json.Unmarshal(service.requests[i].PayloadBytes, &stored))
+			assert.Equal(t, expected, stored["reply_to"])
+		}
+	})
+}
+
+func TestCreateEmailHandler_ServeHTTP_PlainTextGeneration(t *testing.T) {
+	t.Parallel()
+
+	generated := "News\n\nRead the news (https://acme.com/news):\n\n- One\n- Two"
+
+	type caseStruct struct {
+		name              string
+		opts              []CreateEmailHandlerOption
+		expectedBodyTexts []string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:              "generated on request",
+			expectedBodyTexts: []string{"", "Hello,\nWorld!", "", "Hello from the producer"},
+		},
+		{
+			name:              "generated by default",
+			opts:              []CreateEmailHandlerOption{WithPlainTextGeneration()},
+			expectedBodyTexts: []string{generated, "Hello,\nWorld!", "", "Hello from the producer"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile("testdata/handler_test/payloads/plain-text.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(nil)
+			sut := NewCreateEmailHandler(service, tc.opts...)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Len(t, service.requests, len(tc.expectedBodyTexts))
+
+			for i, expected := range tc.expectedBodyTexts {
+				var stored map[string]any
+				assert.NoError(t, json.Unmarshal(service.requests[i].PayloadBytes, &stored))
+				assert.Equal(t, expected, stored["body_text"])
+			}
+		})
+	}
+}
+
+type unsubscribeLinkerMock struct {
+	claims []unsubscribe.Claims
+}
+
+func (m *unsubscribeLinkerMock) OneClickURL(claims unsubscribe.Claims) (string, error) {
+	m.claims = append(m.claims, claims)
+	return "https://mail-api.example.com/unsubscribe/token", nil
+}
+
+func TestCreateEmailHandler_ServeHTTP_UnsubscribeHeaders(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name            string
+		payloadFilePath string
+		expectedHeaders []map[string]string
+		expectedClaims  []unsubscribe.Claims
+	}
+
+	cases := []caseStruct{
+		{
+			name:            "producer links",
+			payloadFilePath: "testdata/handler_test/payloads/unsubscribe.json",
+			expectedHeaders: []map[string]string{
+				{
+					"List-Unsubscribe":      "<https://example.com/unsubscribe/42>, <mailto:unsubscribe@example.com>",
+					"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+				},
+				{
+					"List-Unsubscribe": "<mailto:unsubscribe@example.com>",
+				},
+			},
+		},
+		{
+			name:            "served one-click link",
+			payloadFilePath: "testdata/handler_test/payloads/unsubscribe-one-click-link.json",
+			expectedHeaders: []map[string]string{
+				{
+					"List-Unsubscribe":      "<https://mail-api.example.com/unsubscribe/token>, <mailto:unsubscribe@example.com>",
+					"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+				},
+			},
+			expectedClaims: []unsubscribe.Claims{
+				{Tenant: "tenant-a", EmailId: "0b4a3c52-2b8f-4d1b-9a7e-6a0c1f2b3c03", Recipients: []string{"subscriber@example.com"}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile(c.payloadFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := auth.NewContext(context.TODO(), auth.Principal{Tenant: "tenant-a"})
+			request := httptest.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(requestBody))
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(nil)
+			linker := &unsubscribeLinkerMock{}
+			sut := NewCreateEmailHandler(service, WithUnsubscribeLinks(linker))
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Len(t, service.requests, len(c.expectedHeaders))
+			for i, expected := range c.expectedHeaders {
+				var stored emailDataInput
+				assert.NoError(t, json.Unmarshal(service.requests[i].PayloadBytes, &stored))
+				assert.Equal(t, expected, stored.CustomHeaders)
+			}
+			assert.Equal(t, c.expectedClaims, linker.claims)
+		})
+	}
+}
+
+func TestCreateEmailHandler_ServeHTTP_OneClickLinkRecipients(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/unsubscribe-one-click-link-recipients.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	linker := &unsubscribeLinkerMock{}
+	sut := NewCreateEmailHandler(service, WithUnsubscribeLinks(linker))
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error": "error validating request body: Key: 'createEmailRequestBody.Data[0].Unsubscribe' Error:Field validation for 'Unsubscribe' failed on the 'single_recipient' tag"}`, response.Body.String())
+	assert.Empty(t, service.requests)
+	assert.Empty(t, linker.claims)
+}
+
+func TestCreateEmailHandler_ServeHTTP_StoresCallbacks(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/callbacks.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+
+	var stored emailDataInput
+	assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+	assert.Equal(t, &Callback{
+		URL:     "https://producer.example.com/emails/65ed6bfa-063c-5219-844d-e099c88a17f4/sent",
+		Method:  http.MethodPost,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	}, stored.CallbackOnSuccess)
+	assert.Equal(t, http.MethodPut, stored.CallbackOnFailure.Method)
+	assert.NotEmpty(t, stored.CallbackOnFailure.BodyTemplate)
+}
+
+func TestCreateEmailHandler_ServeHTTP_LegacyCallbacks(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/callbacks-legacy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+
+	var stored emailDataInput
+	assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+	assert.Equal(t, &Callback{
+		URL:          "https://producer.example.com/emails/sent",
+		Method:       http.MethodPost,
+		Headers:      map[string]string{"Content-Type": "application/json"},
+		BodyTemplate: `{"status": "sent"}`,
+	}, stored.CallbackOnSuccess)
+	assert.Nil(t, stored.CallbackOnFailure)
+}
+
+func TestCreateEmailHandler_ServeHTTP_AttachmentDisposition(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name                string
+		payloadFilePath     string
+		expectedAttachments AttachmentList
+	}
+
+	testCases := []caseStruct{
+		{
+			name:            "legacy strings default to regular attachments",
+			payloadFilePath: "testdata/handler_test/payloads/legacy-attachments-valid.json",
+			expectedAttachments: AttachmentList{
+				{Path: "/path/to/document.pdf", Name: "document.pdf", Disposition: DispositionAttachment},
+				{Path: "/path/to/invoice.pdf", Name: "invoice.pdf", Disposition: DispositionAttachment},
+			},
+		},
+		{
+			name:            "uploaded content is passed to the service",
+			payloadFilePath: "testdata/handler_test/payloads/uploaded-attachment.json",
+			expectedAttachments: AttachmentList{
+				{ContentBase64: "SGVsbG8sIFdvcmxkIQ==", Name: "hello.txt", Disposition: DispositionAttachment},
+			},
+		},
+		{
+			name:            "inline images keep their content id",
+			payloadFilePath: "testdata/handler_test/payloads/inline-images.json",
+			expectedAttachments: AttachmentList{
+				{Path: "/valid/logo.png", Name: "logo.png", Disposition: DispositionInline, ContentId: "logo@acme.com"},
+				{Path: "/valid/path.pdf", Name: "document.pdf", Disposition: DispositionAttachment},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile(tc.payloadFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(nil)
+			sut := NewCreateEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, http.StatusCreated, response.Code)
+			assert.Len(t, service.requests, 1)
+
+			var stored emailDataInput
+			assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+			assert.Equal(t, tc.expectedAttachments, stored.Attachments)
+		})
+	}
+}
+
+func TestCidReferences(t *testing.T) {
+	t.Parallel()
+
+	html := `<img src="cid:logo@acme.com"><img src='CID:part1.abc%40acme'><div style="background: url(cid:bg)"></div><a href="https://cid.example.com">x</a>`
+
+	assert.Equal(t, []string{"logo@acme.com", "part1.abc@acme", "bg"}, cidReferences(html))
+}
+
+type templateRendererMock struct {
+	rendered templates.Rendered
+	err      error
+}
+
+func (m *templateRendererMock) Render(_ context.Context, _ string, _ int, _ map[string]any) (templates.Rendered, error) {
+	return m.rendered, m.err
+}
+
+func TestCreateEmailHandler_ServeHTTP_Templates(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		payload            string
+		renderer           *templateRendererMock
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "template_id combined with subject",
+			payload:            "template-with-subject.json",
+			renderer:           &templateRendererMock{},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error rendering template of data[0]: invalid template request: template_id cannot be combined with subject, body_html or body_text"}`,
+		},
+		{
+			name:               "templates not enabled",
+			payload:            "template.json",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error rendering template of data[0]: invalid template request: templates are not enabled"}`,
+		},
+		{
+			name:               "template not found",
+			payload:            "template.json",
+			renderer:           &templateRendererMock{err: fmt.Errorf("%w: welcome version 0", templates.ErrTemplateNotFound)},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error rendering template of data[0]: invalid template request: template not found: welcome version 0"}`,
+		},
+		{
+			name:               "renderer error",
+			payload:            "template.json",
+			renderer:           &templateRendererMock{err: errors.New("mock error")},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error rendering template"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestBody, err := os.ReadFile("testdata/handler_test/payloads/" + tc.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(nil)
+			var opts []CreateEmailHandlerOption
+			if tc.renderer != nil {
+				opts = append(opts, WithTemplateRenderer(tc.renderer))
+			}
+			sut := NewCreateEmailHandler(service, opts...)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			assert.Empty(t, service.requests)
+		})
+	}
+}
+
+func TestCreateEmailHandler_ServeHTTP_StoresRenderedTemplate(t *testing.T) {
+	t.Parallel()
+
+	requestBody, err := os.ReadFile("testdata/handler_test/payloads/template.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(requestBody))
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	renderer := &templateRendererMock{rendered: templates.Rendered{
+		Version:  3,
+		Subject:  "Welcome Jane",
+		BodyHTML: "<p>Hi Jane</p>",
+		BodyText: "Hi Jane",
+	}}
+	sut := NewCreateEmailHandler(service, WithTemplateRenderer(renderer))
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusCreated, response.Code)
+	assert.Len(t, service.requests, 1)
+
+	var stored emailDataInput
+	assert.NoError(t, json.Unmarshal(service.requests[0].PayloadBytes, &stored))
+	assert.Equal(t, "welcome", stored.TemplateId)
+	assert.Equal(t, 3, stored.TemplateVersion, "the rendered version is pinned in the payload")
+	assert.Equal(t, "Welcome Jane", stored.Subject)
+	assert.Equal(t, "<p>Hi Jane</p>", stored.BodyHTML)
+	assert.Equal(t, "Hi Jane", stored.BodyText)
+}
@@ -0,0 +1,124 @@
+package email
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultDeniedHeaders are the structural headers set by the sender from the payload fields, which
+// custom headers must not override
+var DefaultDeniedHeaders = []string{
+	"Bcc",
+	"Cc",
+	"Content-Disposition",
+	"Content-ID",
+	"Content-Transfer-Encoding",
+	"Content-Type",
+	"Date",
+	"DKIM-Signature",
+	"From",
+	"Message-ID",
+	"MIME-Version",
+	"Received",
+	"Reply-To",
+	"Return-Path",
+	"Sender",
+	"Subject",
+	"To",
+}
+
+// HeaderPolicy decides which custom header names producers may set. Names are compared case
+// insensitively, as header names are.
+type HeaderPolicy struct {
+	deniedNames     map[string]bool
+	allowedPrefixes []string
+}
+
+// NewHeaderPolicy denies DefaultDeniedHeaders and the given names. When allowed prefixes are given,
+// only names starting with one of them are accepted.
+func NewHeaderPolicy(deniedNames []string, allowedPrefixes []string) *HeaderPolicy {
+	p := &HeaderPolicy{deniedNames: make(map[string]bool, len(DefaultDeniedHeaders)+len(deniedNames))}
+	for _, name := range DefaultDeniedHeaders {
+		p.deniedNames[strings.ToLower(name)] = true
+	}
+	for _, name := range deniedNames {
+		p.deniedNames[strings.ToLower(name)] = true
+	}
+	for _, prefix := range allowedPrefixes {
+		p.allowedPrefixes = append(p.allowedPrefixes, strings.ToLower(prefix))
+	}
+
+	return p
+}
+
+// Allows tells whether a custom header name is accepted. Denied names are refused even when they
+// match an allowed prefix.
+func (p *HeaderPolicy) Allows(name string) bool {
+	name = strings.ToLower(name)
+
+	if p.deniedNames[name] {
+		return false
+	}
+
+	if len(p.allowedPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range p.allowedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMessageField tells whether a pre-rendered message may carry a header field. The structural
+// headers listed by DefaultDeniedHeaders are the message's own, any other field is a custom header.
+func (p *HeaderPolicy) AllowsMessageField(name string) bool {
+	for _, structural := range DefaultDeniedHeaders {
+		if strings.EqualFold(name, structural) {
+			return true
+		}
+	}
+	return p.Allows(name)
+}
+
+// isHeaderName tells whether a name is a field name as defined by RFC 5322 section 3.6.8, that is
+// printable US-ASCII characters other than colon
+func isHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < 33 || name[i] > 126 || name[i] == ':' {
+			return false
+		}
+	}
+	return true
+}
+
+// isHeaderValue rejects line breaks, which would inject headers or end the header section, and
+// other control characters except horizontal tab
+func isHeaderValue(value string) bool {
+	for _, r := range value {
+		if (r < 32 && r != '\t') || r == 127 {
+			return false
+		}
+	}
+	return true
+}
+
+func validateHeaderName(fl validator.FieldLevel) bool {
+	return isHeaderName(fl.Field().String())
+}
+
+func validateHeaderValue(fl validator.FieldLevel) bool {
+	return isHeaderValue(fl.Field().String())
+}
+
+// validateHeaderAllowed returns the validation function of the header_allowed tag for a policy
+func validateHeaderAllowed(policy *HeaderPolicy) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		return policy.Allows(fl.Field().String())
+	}
+}
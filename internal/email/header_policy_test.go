@@ -0,0 +1,73 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderPolicy_Allows(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name            string
+		deniedNames     []string
+		allowedPrefixes []string
+		header          string
+		expected        bool
+	}
+
+	cases := []caseStruct{
+		{"default policy allows custom names", nil, nil, "X-Campaign", true},
+		{"default policy denies structural names", nil, nil, "Content-Type", false},
+		{"denied names are case insensitive", nil, nil, "fROM", false},
+		{"configured deny-list is denied", []string{"X-Internal"}, nil, "X-Internal", false},
+		{"configured deny-list keeps the default", []string{"X-Internal"}, nil, "Subject", false},
+		{"names out of the deny-lists are allowed", []string{"X-Internal"}, nil, "X-Campaign", true},
+		{"allowed prefix", nil, []string{"X-", "List-"}, "list-id", true},
+		{"name without allowed prefix", nil, []string{"X-"}, "Organization", false},
+		{"denied name with allowed prefix", []string{"X-Mailer"}, []string{"X-"}, "X-Mailer", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sut := NewHeaderPolicy(c.deniedNames, c.allowedPrefixes)
+
+			assert.Equal(t, c.expected, sut.Allows(c.header))
+		})
+	}
+}
+
+func TestHeaderPolicy_AllowsMessageField(t *testing.T) {
+	t.Parallel()
+
+	sut := NewHeaderPolicy([]string{"X-Internal"}, []string{"X-"})
+
+	assert.True(t, sut.AllowsMessageField("Content-Type"), "structural headers are the message's own")
+	assert.True(t, sut.AllowsMessageField("x-campaign"))
+	assert.False(t, sut.AllowsMessageField("X-Internal"))
+	assert.False(t, sut.AllowsMessageField("Organization"))
+}
+
+func TestIsHeaderName(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isHeaderName("X-Campaign"))
+	assert.True(t, isHeaderName("X_Weird!Name"))
+	assert.False(t, isHeaderName(""))
+	assert.False(t, isHeaderName("X Campaign"))
+	assert.False(t, isHeaderName("X-Campaign:"))
+	assert.False(t, isHeaderName("X-Campaign\r\nBcc"))
+	assert.False(t, isHeaderName("X-Città"))
+}
+
+func TestIsHeaderValue(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, isHeaderValue("spring sale"))
+	assert.True(t, isHeaderValue("tab\tseparated"))
+	assert.True(t, isHeaderValue("Città"))
+	assert.False(t, isHeaderValue("spring\r\nBcc: victim@example.com"))
+	assert.False(t, isHeaderValue("spring\nBcc: victim@example.com"))
+	assert.False(t, isHeaderValue("nul\x00"))
+}
@@ -25,7 +25,7 @@ func NewGetInvalidEmailsHandler(emailService invalidEmailsServiceInterface) *Get
 }
 
 func (h *GetInvalidEmailsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	invalidEmails, err := h.emailService.GetInvalidEmails(context.TODO())
+	invalidEmails, err := h.emailService.GetInvalidEmails(r.Context())
 	if err != nil {
 		slog.Error(fmt.Sprintf("error getting invalid emails: %v", err))
 		response.WriteError(http.StatusInternalServerError, w, "error getting invalid emails")
@@ -51,6 +51,7 @@ func TestGetInvalidEmailsHandler_ServeHTTP(t *testing.T) {
 				{
 					Id:           "test-id-1",
 					Status:       "INVALID",
+					Priority:     "normal",
 					CreatedAt:    fixedTime,
 					UpdatedAt:    fixedTime.Add(-1 * time.Hour),
 					ErrorMessage: "Invalid email address",
@@ -58,13 +59,14 @@ func TestGetInvalidEmailsHandler_ServeHTTP(t *testing.T) {
 				{
 					Id:           "test-id-2",
 					Status:       "INVALID",
+					Priority:     "normal",
 					CreatedAt:    fixedTime,
 					UpdatedAt:    fixedTime.Add(-2 * time.Hour),
 					ErrorMessage: "Validation failed",
 				},
 			},
 			expectedStatusCode: http.StatusOK,
-			expectedBody:       `[{"id":"test-id-1","status":"INVALID","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T11:00:00Z","error_message":"Invalid email address"},{"id":"test-id-2","status":"INVALID","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T10:00:00Z","error_message":"Validation failed"}]`,
+			expectedBody:       `[{"id":"test-id-1","status":"INVALID","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T11:00:00Z","error_message":"Invalid email address"},{"id":"test-id-2","status":"INVALID","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T10:00:00Z","error_message":"Validation failed"}]`,
 		},
 		{
 			name:             "success with emails without error message",
@@ -73,12 +75,13 @@ func TestGetInvalidEmailsHandler_ServeHTTP(t *testing.T) {
 				{
 					Id:        "test-id-3",
 					Status:    "INVALID",
+					Priority:  "normal",
 					CreatedAt: fixedTime,
 					UpdatedAt: fixedTime,
 				},
 			},
 			expectedStatusCode: http.StatusOK,
-			expectedBody:       `[{"id":"test-id-3","status":"INVALID","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T12:00:00Z"}]`,
+			expectedBody:       `[{"id":"test-id-3","status":"INVALID","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T12:00:00Z"}]`,
 		},
 		{
 			name:               "success with no emails",
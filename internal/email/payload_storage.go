@@ -1,12 +1,18 @@
 package email
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
 )
 
+var errOutsidePayloadStorage = errors.New("path is outside of the payload storage")
+
+var errInvalidTenant = errors.New("tenant is not a valid directory name")
+
 type PayloadStorage struct {
 	basePath string
 }
@@ -15,17 +21,76 @@ func NewPayloadStorage(basePath string) *PayloadStorage {
 	return &PayloadStorage{basePath}
 }
 
-func (s *PayloadStorage) Store(messageId string, payload []byte) (string, error) {
+// tenantDir returns the directory of the current month of a tenant. Emails of different tenants
+// may share an ID, so their files are kept in separate trees.
+func (s *PayloadStorage) tenantDir(tenant string) (string, error) {
+	if tenant == "." || !filepath.IsLocal(tenant) || filepath.Base(tenant) != tenant {
+		return "", fmt.Errorf("%w: %q", errInvalidTenant, tenant)
+	}
+
 	year, month, _ := time.Now().Date()
-	dirPath := filepath.Join(s.basePath, fmt.Sprintf("%v/%v", year, month))
+	return filepath.Join(s.basePath, tenant, fmt.Sprintf("%v/%v", year, month)), nil
+}
+
+func (s *PayloadStorage) Store(tenant string, messageId string, payload []byte) (string, error) {
+	dirPath, err := s.tenantDir(tenant)
+	if err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("%s.json", messageId)
+
+	return writeFile(dirPath, filename, payload)
+}
+
+// StoreEML writes a pre-rendered message as it is, next to the JSON payloads
+func (s *PayloadStorage) StoreEML(tenant string, messageId string, message []byte) (string, error) {
+	dirPath, err := s.tenantDir(tenant)
+	if err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("%s.eml", messageId)
+
+	return writeFile(dirPath, filename, message)
+}
+
+// StoreEnvelope writes the envelope of a message stored with StoreEML, next to it
+func (s *PayloadStorage) StoreEnvelope(tenant string, messageId string, envelope []byte) (string, error) {
+	dirPath, err := s.tenantDir(tenant)
+	if err != nil {
+		return "", err
+	}
+
+	filename := fmt.Sprintf("%s.envelope.json", messageId)
+
+	return writeFile(dirPath, filename, envelope)
+}
+
+// StoreAttachment writes the content of an uploaded attachment in a directory named after the
+// message, next to its JSON payload. The index keeps attachments with the same name apart.
+func (s *PayloadStorage) StoreAttachment(tenant string, messageId string, index int, name string, content []byte) (string, error) {
+	dirPath, err := s.tenantDir(tenant)
+	if err != nil {
+		return "", err
+	}
+	dirPath = filepath.Join(dirPath, messageId)
+
+	filename := fmt.Sprintf("%d-%s", index, filepath.Base(name))
+
+	return writeFile(dirPath, filename, content)
+}
+
+// writeFile creates a new file. Existing files belong to another email and are never overwritten,
+// the returned error wraps fs.ErrExist.
+func writeFile(dirPath string, filename string, payload []byte) (string, error) {
 	if err := os.MkdirAll(dirPath, os.ModePerm); err != nil {
 		return "", fmt.Errorf("failed to create directory %s: %w", dirPath, err)
 	}
 
-	filename := fmt.Sprintf("%s.json", messageId)
 	path := filepath.Join(dirPath, filename)
 
-	file, err := os.Create(path)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
 	if err != nil {
 		return "", fmt.Errorf("failed to create file: %w", err)
 	}
@@ -38,6 +103,23 @@ func (s *PayloadStorage) Store(messageId string, payload []byte) (string, error)
 	return path, nil
 }
 
+// Read returns the content of a payload or attachment file written by the storage. Paths outside
+// of the base path are rejected with errOutsidePayloadStorage.
+func (s *PayloadStorage) Read(path string) ([]byte, error) {
+	rel, err := filepath.Rel(s.basePath, path)
+	if err != nil || !filepath.IsLocal(rel) {
+		return nil, fmt.Errorf("%w: %s", errOutsidePayloadStorage, path)
+	}
+
+	file, err := os.OpenInRoot(s.basePath, rel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return io.ReadAll(file)
+}
+
 func (s *PayloadStorage) Delete(payloadPath string) error {
 	if err := os.Remove(payloadPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete payload file %s: %w", payloadPath, err)
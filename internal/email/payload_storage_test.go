@@ -1,6 +1,8 @@
 package email
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,7 +19,7 @@ func TestPayloadStorageStore(t *testing.T) {
 	payload := []byte("test payload data")
 
 	// Execute
-	path, err := storage.Store(messageId, payload)
+	path, err := storage.Store("tenant-a", messageId, payload)
 
 	// Verify
 	if err != nil {
@@ -74,8 +76,8 @@ func TestPayloadStorageUniqueFilenames(t *testing.T) {
 	payload1 := []byte("payload 1")
 	payload2 := []byte("payload 2")
 
-	path1, err1 := storage.Store(messageId1, payload1)
-	path2, err2 := storage.Store(messageId2, payload2)
+	path1, err1 := storage.Store("tenant-a", messageId1, payload1)
+	path2, err2 := storage.Store("tenant-a", messageId2, payload2)
 
 	// Verify
 	if err1 != nil || err2 != nil {
@@ -118,7 +120,7 @@ func TestPayloadStorageDirectoryCreation(t *testing.T) {
 	payload := []byte("test payload")
 
 	// Execute
-	path, err := storage.Store(messageId, payload)
+	path, err := storage.Store("tenant-a", messageId, payload)
 
 	// Verify
 	if err != nil {
@@ -141,7 +143,7 @@ func TestPayloadStorageDelete(t *testing.T) {
 	payload := []byte("test payload")
 
 	// Create a file first
-	path, err := storage.Store(messageId, payload)
+	path, err := storage.Store("tenant-a", messageId, payload)
 	if err != nil {
 		t.Fatalf("failed to store payload: %v", err)
 	}
@@ -181,3 +183,114 @@ func TestPayloadStorageDeleteNonExistentFile(t *testing.T) {
 	}
 }
 
+
+func TestPayloadStorageStoreAttachment(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewPayloadStorage(tmpDir)
+
+	messageId := "65ed6bfa-063c-5219-844d-e099c88a17f4"
+
+	payloadPath, err := storage.Store("tenant-a", messageId, []byte("{}"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	path, err := storage.StoreAttachment("tenant-a", messageId, 2, "../../invoice.pdf", []byte("content"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedPath := filepath.Join(filepath.Dir(payloadPath), messageId, "2-invoice.pdf")
+	if path != expectedPath {
+		t.Errorf("expected attachment at %s, got %s", expectedPath, path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "content" {
+		t.Errorf("expected content %q, got %q", "content", string(content))
+	}
+}
+
+func TestPayloadStorageRead(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewPayloadStorage(tmpDir)
+
+	path, err := storage.Store("tenant-a", "65ed6bfa-063c-5219-844d-e099c88a17f4", []byte("test payload data"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	content, err := storage.Read(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if string(content) != "test payload data" {
+		t.Errorf("expected content %q, got %q", "test payload data", string(content))
+	}
+
+	if _, err := storage.Read(filepath.Join(tmpDir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+
+	for _, outside := range []string{"/etc/passwd", filepath.Join(tmpDir, "..", "payload.json")} {
+		if _, err := storage.Read(outside); !errors.Is(err, errOutsidePayloadStorage) {
+			t.Errorf("expected %s to be rejected, got %v", outside, err)
+		}
+	}
+}
+
+func TestPayloadStorageStoreTenants(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewPayloadStorage(tmpDir)
+
+	messageId := "65ed6bfa-063c-5219-844d-e099c88a17f4"
+
+	pathA, err := storage.Store("tenant-a", messageId, []byte("payload a"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	pathB, err := storage.Store("tenant-b", messageId, []byte("payload b"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if pathA == pathB {
+		t.Errorf("expected tenants to get different paths, got %s", pathA)
+	}
+	if !strings.HasPrefix(pathA, filepath.Join(tmpDir, "tenant-a")+string(filepath.Separator)) {
+		t.Errorf("expected %s to be under the tenant directory", pathA)
+	}
+
+	for _, tenant := range []string{"", ".", "..", "../tenant-a", "tenant/a"} {
+		if _, err := storage.Store(tenant, messageId, []byte("payload")); !errors.Is(err, errInvalidTenant) {
+			t.Errorf("expected tenant %q to be rejected, got %v", tenant, err)
+		}
+	}
+}
+
+func TestPayloadStorageStoreDoesNotOverwrite(t *testing.T) {
+	tmpDir := t.TempDir()
+	storage := NewPayloadStorage(tmpDir)
+
+	messageId := "65ed6bfa-063c-5219-844d-e099c88a17f4"
+
+	path, err := storage.Store("tenant-a", messageId, []byte("first"))
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if _, err := storage.Store("tenant-a", messageId, []byte("second")); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected exist error, got %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "first" {
+		t.Errorf("expected content %q, got %q", "first", string(content))
+	}
+}
@@ -0,0 +1,16 @@
+package email
+
+import "multicarrier-email-api/internal/htmltext"
+
+// applyPlainText fills a missing body_text with the plain text of body_html, when generation is
+// enabled for the handler or requested by the email, so that the message has both alternatives
+func (h *CreateEmailHandler) applyPlainText(e *emailDataInput) {
+	generate := h.generatePlainText
+	if e.GenerateText != nil {
+		generate = *e.GenerateText
+	}
+
+	if generate && e.BodyText == "" && e.BodyHTML != "" {
+		e.BodyText = htmltext.Convert(e.BodyHTML)
+	}
+}
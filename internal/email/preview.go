@@ -0,0 +1,149 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"time"
+
+	"multicarrier-email-api/internal/eml"
+)
+
+// errAttachmentNotReadable is returned for attachments whose content cannot be read at intake, which
+// signed messages cannot include
+var errAttachmentNotReadable = errors.New("attachment cannot be read for the signed message")
+
+// readAttachment returns the content of an attachment for previews. Remote files, and local files
+// out of the payload storage and of the allowed attachment roots, are not read: their URL is
+// returned instead, so that previews cannot disclose arbitrary files of the server.
+func (s *Service) readAttachment(path string) ([]byte, string, error) {
+	localPath := path
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		if u.Scheme != "file" {
+			return nil, path, nil
+		}
+		localPath = u.Path
+	}
+
+	content, err := s.payloadStorage.Read(localPath)
+	if !errors.Is(err, errOutsidePayloadStorage) {
+		return content, "", err
+	}
+
+	if s.attachmentValidator != nil && s.attachmentValidator.Validate(localPath) == nil {
+		content, err := os.ReadFile(localPath)
+		return content, "", err
+	}
+
+	return nil, (&url.URL{Scheme: "file", Path: localPath}).String(), nil
+}
+
+// readDeliveryAttachment returns the content of an attachment of a message delivered as it is
+// stored. Attachments that previews would reference by URL are not readable.
+func (s *Service) readDeliveryAttachment(path string) ([]byte, string, error) {
+	content, reference, err := s.readAttachment(path)
+	if err == nil && reference != "" {
+		return nil, "", fmt.Errorf("%w: %s", errAttachmentNotReadable, reference)
+	}
+	return content, "", err
+}
+
+func formatAddresses(addresses RecipientList) []string {
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = address.String()
+	}
+	return formatted
+}
+
+// previewMessage returns the message of a stored payload, dated at the time it is scheduled for.
+// Attachments that cannot be read are referenced by URL.
+func (s *Service) previewMessage(e emailDataInput) (eml.Message, error) {
+	return s.message(e, s.readAttachment)
+}
+
+// deliveryMessage returns the message of a payload as it is delivered, with the content of every
+// attachment
+func (s *Service) deliveryMessage(e emailDataInput) (eml.Message, error) {
+	return s.message(e, s.readDeliveryAttachment)
+}
+
+// message returns the message of a payload, with the attachments read by readAttachment
+func (s *Service) message(e emailDataInput, readAttachment func(path string) ([]byte, string, error)) (eml.Message, error) {
+	date := time.Now()
+	if e.SendAt != nil && e.SendAt.After(date) {
+		date = *e.SendAt
+	}
+
+	message := eml.Message{
+		Id:       e.Id,
+		From:     e.From.String(),
+		ReplyTo:  e.ReplyTo.String(),
+		To:       formatAddresses(e.To),
+		Cc:       formatAddresses(e.Cc),
+		Subject:  e.Subject,
+		Date:     date,
+		TextBody: e.BodyText,
+		HTMLBody: e.BodyHTML,
+		Headers:  e.CustomHeaders,
+	}
+
+	for i, a := range e.Attachments {
+		content, reference, err := readAttachment(a.Path)
+		if err != nil {
+			return eml.Message{}, fmt.Errorf("failed to read attachments[%d]: %w", i, err)
+		}
+
+		message.Attachments = append(message.Attachments, eml.Attachment{
+			Name:      a.Name,
+			Content:   content,
+			URL:       reference,
+			Inline:    a.Disposition == DispositionInline,
+			ContentId: a.ContentId,
+		})
+	}
+
+	return message, nil
+}
+
+// readStored returns the content of a stored file of an email
+func (s *Service) readStored(id string, path string) ([]byte, error) {
+	content, err := s.payloadStorage.Read(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", ErrPayloadNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to read payload: %w", err)
+	}
+	return content, nil
+}
+
+// PreviewEmail renders the stored payload of an email as the RFC 5322 message that will be sent,
+// DKIM signed when the domain of the sender has a key. The messages stored at intake, of raw and
+// of signed emails, are returned as they are stored.
+func (s *Service) PreviewEmail(ctx context.Context, id string) ([]byte, error) {
+	paths, err := s.db.GetStoredFilePaths(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if paths.EMLFilePath != "" {
+		return s.readStored(id, paths.EMLFilePath)
+	}
+
+	payload, err := s.readStored(id, paths.PayloadFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rendered, err := s.renderPayload(payload, s.previewMessage)
+	if err != nil {
+		return nil, err
+	}
+
+	signed, _, err := s.signMessage(rendered)
+	return signed, err
+}
@@ -0,0 +1,54 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"multicarrier-email-api/internal/response"
+)
+
+type previewEmailServiceInterface interface {
+	PreviewEmail(ctx context.Context, id string) ([]byte, error)
+}
+
+// PreviewEmailHandler returns an email as the RFC 5322 message that will be sent
+type PreviewEmailHandler struct {
+	emailService previewEmailServiceInterface
+}
+
+func NewPreviewEmailHandler(emailService previewEmailServiceInterface) *PreviewEmailHandler {
+	return &PreviewEmailHandler{
+		emailService: emailService,
+	}
+}
+
+func (h *PreviewEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	message, err := h.emailService.PreviewEmail(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrEmailNotFound):
+			response.WriteError(http.StatusNotFound, w, "email not found")
+		case errors.Is(err, ErrPayloadNotFound):
+			response.WriteError(http.StatusNotFound, w, "email payload not found")
+		default:
+			slog.Error(fmt.Sprintf("error rendering email preview: %v", err))
+			response.WriteError(http.StatusInternalServerError, w, "error rendering email preview")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "message/rfc822")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": id + ".eml"}))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(message)
+}
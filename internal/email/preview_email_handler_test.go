@@ -0,0 +1,88 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type previewEmailServiceMock struct {
+	message   []byte
+	returnErr error
+	calledId  string
+}
+
+func (m *previewEmailServiceMock) PreviewEmail(_ context.Context, id string) ([]byte, error) {
+	m.calledId = id
+	return m.message, m.returnErr
+}
+
+func TestPreviewEmailHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		emailId            string
+		returnErr          error
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			emailId:            "test-id-123",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "Subject: Hello\r\n\r\nHello\r\n",
+		},
+		{
+			name:               "email not found",
+			emailId:            "test-id-456",
+			returnErr:          fmt.Errorf("%w: test-id-456", ErrEmailNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "email not found"}`,
+		},
+		{
+			name:               "payload not found",
+			emailId:            "test-id-789",
+			returnErr:          fmt.Errorf("%w: test-id-789", ErrPayloadNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "email payload not found"}`,
+		},
+		{
+			name:               "service error",
+			emailId:            "test-id-000",
+			returnErr:          errors.New("mock error"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error rendering email preview"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/emails/"+tc.emailId+"/preview.eml", nil)
+			request.SetPathValue("id", tc.emailId)
+			response := httptest.NewRecorder()
+
+			service := &previewEmailServiceMock{message: []byte("Subject: Hello\r\n\r\nHello\r\n"), returnErr: tc.returnErr}
+			sut := NewPreviewEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			if tc.returnErr == nil {
+				assert.Equal(t, "message/rfc822", response.Header().Get("Content-Type"))
+				assert.Equal(t, `inline; filename=`+tc.emailId+`.eml`, response.Header().Get("Content-Disposition"))
+				assert.Equal(t, tc.expectedBody, response.Body.String())
+			} else {
+				assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			}
+			assert.Equal(t, tc.emailId, service.calledId)
+		})
+	}
+}
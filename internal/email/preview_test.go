@@ -0,0 +1,250 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"multicarrier-email-api/internal/dkim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// previewPart is a leaf part of a rendered message
+type previewPart struct {
+	header  map[string][]string
+	content string
+}
+
+// readPreviewParts returns the decoded leaf parts of a message body, by media type
+func readPreviewParts(t *testing.T, contentType string, header map[string][]string, body io.Reader, parts map[string]previewPart) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		parts[mediaType] = previewPart{header: header, content: string(content)}
+		return
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		readPreviewParts(t, part.Header.Get("Content-Type"), part.Header, part, parts)
+	}
+}
+
+func parsePreview(t *testing.T, preview []byte) (*mail.Message, map[string]previewPart) {
+	message, err := mail.ReadMessage(bytes.NewReader(preview))
+	require.NoError(t, err)
+
+	parts := make(map[string]previewPart)
+	readPreviewParts(t, message.Header.Get("Content-Type"), message.Header, message.Body, parts)
+
+	return message, parts
+}
+
+func TestService_PreviewEmail(t *testing.T) {
+	t.Parallel()
+
+	id := "ff0fb587-e29b-4278-bbab-a525196b8917"
+	payloadStorage := &payloadStorageMock{files: map[string][]byte{
+		"/storage/" + id + ".json": []byte(`{
+			"id": "` + id + `",
+			"from": "sender@test.multidialogo.it",
+			"reply_to": "no-reply@test.multidialogo.it",
+			"to": "recipient2@test.multidialogo.it",
+			"subject": "Test Email with Reply-To",
+			"body_html": "<p>This is another test email in HTML format.</p>",
+			"body_text": "This is another test email in plain text format.",
+			"custom_headers": {"X-Custom-Header": "AnotherHeaderValue"}
+		}`),
+	}}
+	database := &databaseMock{payloadFilePaths: map[string]string{id: "/storage/" + id + ".json"}}
+
+	sut := NewService(payloadStorage, database)
+
+	preview, err := sut.PreviewEmail(context.TODO(), id)
+	require.NoError(t, err)
+
+	fixture, err := os.ReadFile("testdata/eml_storage_test/expectations/" + id + ".EML")
+	require.NoError(t, err)
+
+	expected, expectedParts := parsePreview(t, fixture)
+	actual, actualParts := parsePreview(t, preview)
+
+	for _, name := range []string{"From", "Reply-To", "To", "Subject", "X-Custom-Header"} {
+		assert.Equal(t, expected.Header.Get(name), actual.Header.Get(name), name)
+	}
+	for _, mediaType := range []string{"text/plain", "text/html"} {
+		assert.Equal(t, expectedParts[mediaType].content, actualParts[mediaType].content, mediaType)
+	}
+
+	assert.Equal(t, "<"+id+"@test.multidialogo.it>", actual.Header.Get("Message-ID"))
+	assert.True(t, strings.HasPrefix(actual.Header.Get("Content-Type"), "multipart/alternative;"))
+}
+
+func TestService_PreviewEmail_Attachments(t *testing.T) {
+	t.Parallel()
+
+	attachmentsDir := t.TempDir()
+	allowedPath := filepath.Join(attachmentsDir, "terms.pdf")
+	require.NoError(t, os.WriteFile(allowedPath, []byte("terms"), 0o600))
+
+	testCases := []struct {
+		name                string
+		path                string
+		validator           attachmentValidatorInterface
+		expectedContent     string
+		expectedContentType string
+	}{
+		{
+			name:                "uploaded attachment",
+			path:                "/storage/msg1/0-terms.pdf",
+			expectedContent:     "terms",
+			expectedContentType: "application/pdf; name=terms.pdf",
+		},
+		{
+			name:                "attachment in the allowed roots",
+			path:                allowedPath,
+			validator:           &attachmentValidatorMock{},
+			expectedContent:     "terms",
+			expectedContentType: "application/pdf; name=terms.pdf",
+		},
+		{
+			name:                "attachment out of the allowed roots",
+			path:                allowedPath,
+			validator:           &attachmentValidatorMock{invalidPaths: map[string]bool{allowedPath: true}},
+			expectedContentType: `message/external-body; access-type=URL; url="file://` + allowedPath + `"`,
+		},
+		{
+			name:                "local attachment without allowed roots",
+			path:                allowedPath,
+			expectedContentType: `message/external-body; access-type=URL; url="file://` + allowedPath + `"`,
+		},
+		{
+			name:                "remote attachment",
+			path:                "https://files.example.com/terms.pdf",
+			validator:           &attachmentValidatorMock{},
+			expectedContentType: `message/external-body; access-type=URL; url="https://files.example.com/terms.pdf"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{files: map[string][]byte{
+				"/storage/msg1.json": []byte(`{
+					"id": "msg1",
+					"from": "sender@example.com",
+					"reply_to": "sender@example.com",
+					"to": "example@example.com",
+					"subject": "Test Subject",
+					"body_text": "Hello, World!",
+					"attachments": [{"path": "` + tc.path + `", "name": "terms.pdf", "disposition": "attachment"}]
+				}`),
+				"/storage/msg1/0-terms.pdf": []byte("terms"),
+			}}
+			database := &databaseMock{payloadFilePaths: map[string]string{"msg1": "/storage/msg1.json"}}
+
+			opts := []ServiceOption{}
+			if tc.validator != nil {
+				opts = append(opts, WithAttachmentValidator(tc.validator))
+			}
+			sut := NewService(payloadStorage, database, opts...)
+
+			preview, err := sut.PreviewEmail(context.TODO(), "msg1")
+			require.NoError(t, err)
+
+			_, parts := parsePreview(t, preview)
+			mediaType, _, _ := mime.ParseMediaType(tc.expectedContentType)
+			part, ok := parts[mediaType]
+			require.True(t, ok, "missing %s part", mediaType)
+
+			assert.Equal(t, tc.expectedContentType, part.header["Content-Type"][0])
+			if tc.expectedContent != "" {
+				content, err := base64.StdEncoding.DecodeString(part.content)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedContent, string(content))
+			}
+		})
+	}
+}
+
+func TestService_PreviewEmail_DKIM(t *testing.T) {
+	t.Parallel()
+
+	payload := func(id string, from string) []byte {
+		return []byte(`{"id": "` + id + `", "from": "` + from + `", "reply_to": "` + from + `", "to": "user@example.com", "subject": "Signed", "body_text": "Hello"}`)
+	}
+	payloadStorage := &payloadStorageMock{files: map[string][]byte{
+		"/storage/signed.json":   payload("signed", "news@acme.com"),
+		"/storage/unsigned.json": payload("unsigned", "news@other.com"),
+	}}
+	database := &databaseMock{payloadFilePaths: map[string]string{
+		"signed":   "/storage/signed.json",
+		"unsigned": "/storage/unsigned.json",
+	}}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := dkim.NewSigner([]dkim.Key{{Domain: "acme.com", Selector: "mail", Signer: key}})
+	require.NoError(t, err)
+
+	sut := NewService(payloadStorage, database, WithDKIMSigner(signer))
+
+	signed, err := sut.PreviewEmail(context.TODO(), "signed")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(signed), "DKIM-Signature: v=1;"))
+	assert.NoError(t, dkim.Verify(signed, signer.PublicKey))
+
+	unsigned, err := sut.PreviewEmail(context.TODO(), "unsigned")
+	require.NoError(t, err)
+	assert.NotContains(t, string(unsigned), "DKIM-Signature")
+}
+
+func TestService_PreviewEmail_Raw(t *testing.T) {
+	t.Parallel()
+
+	message := []byte("From: news@acme.com\r\nTo: user@example.com\r\nSubject: Raw\r\n\r\nHello\r\n")
+	payloadStorage := &payloadStorageMock{files: map[string][]byte{"/storage/raw.eml": message}}
+	database := &databaseMock{emlFilePaths: map[string]string{"raw": "/storage/raw.eml"}}
+
+	sut := NewService(payloadStorage, database)
+
+	preview, err := sut.PreviewEmail(context.TODO(), "raw")
+	require.NoError(t, err)
+	assert.Equal(t, message, preview)
+}
+
+func TestService_PreviewEmail_Errors(t *testing.T) {
+	t.Parallel()
+
+	payloadStorage := &payloadStorageMock{}
+	database := &databaseMock{payloadFilePaths: map[string]string{"msg1": "/storage/msg1.json"}}
+
+	sut := NewService(payloadStorage, database)
+
+	_, err := sut.PreviewEmail(context.TODO(), "missing")
+	assert.ErrorIs(t, err, ErrEmailNotFound)
+
+	_, err = sut.PreviewEmail(context.TODO(), "msg1")
+	assert.ErrorIs(t, err, ErrPayloadNotFound)
+}
@@ -0,0 +1,216 @@
+package email
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"slices"
+	"strings"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/mailbox"
+	"multicarrier-email-api/internal/response"
+)
+
+const rfc822ContentType = "message/rfc822"
+
+// emailIdHeader is the request header carrying the ID of raw messages, which may also be given
+// with the id query parameter
+const emailIdHeader = "X-Email-Id"
+
+// maxRawMessageBytes is the maximum size of raw messages
+const maxRawMessageBytes = 25 << 20
+
+// rawEmailInput holds the values of a raw message checked at intake, taken from its headers
+type rawEmailInput struct {
+	Id         string        `validate:"required,uuid"`
+	From       Address       `validate:"required,verified_sender"`
+	Recipients RecipientList `validate:"required,max=50,dive"`
+	Subject    string        `validate:"required"`
+}
+
+// rawEmailId returns the ID of a raw message, from the header or the query parameter
+func rawEmailId(r *http.Request) (string, error) {
+	header := r.Header.Get(emailIdHeader)
+	query := r.URL.Query().Get("id")
+	if header != "" && query != "" && header != query {
+		return "", fmt.Errorf("%s header and id query parameter do not match", emailIdHeader)
+	}
+	if header != "" {
+		return header, nil
+	}
+	return query, nil
+}
+
+// messageAddresses returns the addresses of the given header fields, with internationalized
+// domains converted to punycode
+func messageAddresses(header mail.Header, names ...string) ([]Address, error) {
+	var addresses []Address
+	for _, name := range names {
+		list, err := header.AddressList(name)
+		if errors.Is(err, mail.ErrHeaderNotPresent) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header: %w", name, err)
+		}
+		for _, address := range list {
+			addresses = append(addresses, Address{Name: address.Name, Address: mailbox.ToASCII(address.Address)})
+		}
+	}
+	return addresses, nil
+}
+
+// checkMessageFields returns an error naming the first header field of a message the policy does not
+// allow
+func checkMessageFields(header mail.Header, policy *HeaderPolicy) error {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		if !policy.AllowsMessageField(name) {
+			return fmt.Errorf("header %s is not allowed", name)
+		}
+	}
+	return nil
+}
+
+// removeHeaderField returns the message without the given header field, with its folded lines. The
+// other fields and the body are kept byte for byte.
+func removeHeaderField(message []byte, name string) []byte {
+	result := make([]byte, 0, len(message))
+	removing := false
+
+	rest := message
+	for len(rest) > 0 {
+		line := rest
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			line = rest[:i+1]
+		}
+		rest = rest[len(line):]
+
+		// the header section ends with the first empty line
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			result = append(result, line...)
+			result = append(result, rest...)
+			break
+		}
+
+		if line[0] != ' ' && line[0] != '\t' {
+			fieldName, _, _ := strings.Cut(string(line), ":")
+			removing = strings.EqualFold(strings.TrimSpace(fieldName), name)
+		}
+		if !removing {
+			result = append(result, line...)
+		}
+	}
+
+	return result
+}
+
+// rawEmailInputFromMessage returns the values checked at intake of a raw message
+func rawEmailInputFromMessage(id string, message *mail.Message) (rawEmailInput, error) {
+	from, err := messageAddresses(message.Header, "From")
+	if err != nil {
+		return rawEmailInput{}, err
+	}
+	if len(from) != 1 {
+		return rawEmailInput{}, fmt.Errorf("message must have a single From address, found %d", len(from))
+	}
+
+	recipients, err := messageAddresses(message.Header, "To", "Cc", "Bcc")
+	if err != nil {
+		return rawEmailInput{}, err
+	}
+
+	return rawEmailInput{
+		Id:         id,
+		From:       from[0],
+		Recipients: recipients,
+		Subject:    message.Header.Get("Subject"),
+	}, nil
+}
+
+// ServeRaw queues a pre-rendered message sent as message/rfc822. The message is stored as it is,
+// after checking the sender, the recipients, the subject and the custom headers in its header
+// section, except for the Bcc field, which is removed so that recipients do not see it. The To, Cc
+// and Bcc recipients are stored in the envelope of the message, see Service.store.
+func (h *CreateEmailHandler) ServeRaw(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != rfc822ContentType {
+		response.WriteError(http.StatusUnsupportedMediaType, w, fmt.Sprintf("content type must be %s", rfc822ContentType))
+		return
+	}
+
+	dryRun, err := isDryRun(r)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, err.Error())
+		return
+	}
+	if dryRun {
+		w.Header().Set("Preference-Applied", preferDryRun)
+	}
+
+	id, err := rawEmailId(r)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, err.Error())
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRawMessageBytes))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.WriteError(http.StatusRequestEntityTooLarge, w, fmt.Sprintf("message is larger than %d bytes", maxRawMessageBytes))
+			return
+		}
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error reading request body: %v", err))
+		return
+	}
+
+	message, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error parsing message: %v", err))
+		return
+	}
+
+	input, err := rawEmailInputFromMessage(id, message)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error parsing message: %v", err))
+		return
+	}
+
+	if err := h.newValidator(auth.TenantOf(r.Context())).Struct(input); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating message: %v", err))
+		return
+	}
+
+	if err := checkMessageFields(message.Header, h.headerPolicy); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating message: %v", err))
+		return
+	}
+
+	recipients := make([]string, len(input.Recipients))
+	for i, recipient := range input.Recipients {
+		recipients[i] = recipient.Address
+	}
+
+	saveResults := h.saver(dryRun)(r.Context(), []EmailRequest{{
+		MessageId:    input.Id,
+		PayloadBytes: removeHeaderField(body, "Bcc"),
+		Recipients:   recipients,
+		Raw:          true,
+	}})
+
+	var batchResponse BatchEmailResponse
+	batchResponse.add(newCreateEmailResult(saveResults[0]))
+
+	writeBatchResponse(w, batchResponse, dryRun)
+}
@@ -0,0 +1,286 @@
+package email
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRawEmailId = "9d3f1c2b-6a4e-4b8d-a1c7-3e5f7a9b0d24"
+
+func TestCreateEmailHandler_ServeRaw(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		messageFilePath    string
+		contentType        string
+		target             string
+		idHeader           string
+		serviceResults     []SaveResult
+		expectedStatusCode int
+		expectedBody       string
+		expectedSaved      bool
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "id header - 201",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{}`,
+			expectedSaved:      true,
+		},
+		{
+			name:               "id query parameter - 201",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			target:             "/emails/raw?id=" + testRawEmailId,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{}`,
+			expectedSaved:      true,
+		},
+		{
+			name:               "already accepted - 200",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			idHeader:           testRawEmailId,
+			serviceResults:     []SaveResult{{MessageId: testRawEmailId, Success: true, AlreadyAccepted: true}},
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"summary": {"total": 1, "successful": 1, "failed": 0}, "results": [{"id": "` + testRawEmailId + `", "status": "success", "already_accepted": true}]}`,
+			expectedSaved:      true,
+		},
+		{
+			name:               "suppressed recipient - 422",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			idHeader:           testRawEmailId,
+			serviceResults:     []SaveResult{{MessageId: testRawEmailId, ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: hidden@example.com"}},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"summary": {"total": 1, "successful": 0, "failed": 1}, "results": [{"id": "` + testRawEmailId + `", "status": "error", "error": {"code": "RECIPIENT_SUPPRESSED", "message": "Recipients are suppressed: hidden@example.com"}}]}`,
+			expectedSaved:      true,
+		},
+		{
+			name:               "wrong content type - 415",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			contentType:        "text/plain",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+			expectedBody:       `{"error": "content type must be message/rfc822"}`,
+		},
+		{
+			name:               "missing id - 400",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating message: Key: 'rawEmailInput.Id' Error:Field validation for 'Id' failed on the 'required' tag"}`,
+		},
+		{
+			name:               "mismatching ids - 400",
+			messageFilePath:    "testdata/handler_test/messages/raw.eml",
+			target:             "/emails/raw?id=3b2d6c5e-8f4a-4c1e-9d7b-2a6e5f4c3b21",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "X-Email-Id header and id query parameter do not match"}`,
+		},
+		{
+			name:               "multiple from addresses - 400",
+			messageFilePath:    "testdata/handler_test/messages/raw-invalid-from.eml",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error parsing message: message must have a single From address, found 2"}`,
+		},
+		{
+			name:               "no recipients - 400",
+			messageFilePath:    "testdata/handler_test/messages/raw-without-recipients.eml",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating message: Key: 'rawEmailInput.Recipients' Error:Field validation for 'Recipients' failed on the 'required' tag"}`,
+		},
+		{
+			name:               "no subject - 400",
+			messageFilePath:    "testdata/handler_test/messages/raw-without-subject.eml",
+			idHeader:           testRawEmailId,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating message: Key: 'rawEmailInput.Subject' Error:Field validation for 'Subject' failed on the 'required' tag"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message, err := os.ReadFile(tc.messageFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := tc.target
+			if target == "" {
+				target = "/emails/raw"
+			}
+			contentType := tc.contentType
+			if contentType == "" {
+				contentType = "message/rfc822"
+			}
+
+			request := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(message))
+			request.Header.Set("Content-Type", contentType)
+			if tc.idHeader != "" {
+				request.Header.Set("X-Email-Id", tc.idHeader)
+			}
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(tc.serviceResults)
+			sut := NewCreateEmailHandler(service)
+
+			sut.ServeRaw(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+
+			if !tc.expectedSaved {
+				assert.Empty(t, service.requests)
+				return
+			}
+
+			assert.Len(t, service.requests, 1)
+			assert.Equal(t, EmailRequest{
+				MessageId:    testRawEmailId,
+				PayloadBytes: bytes.Replace(message, []byte("Bcc: hidden@example.com\r\n"), nil, 1),
+				Recipients:   []string{"jane@example.com", "user@xn--mller-kva.de", "copy@example.com", "hidden@example.com"},
+				Raw:          true,
+			}, service.requests[0])
+		})
+	}
+}
+
+func TestCreateEmailHandler_ServeRaw_SenderIdentities(t *testing.T) {
+	t.Parallel()
+
+	message, err := os.ReadFile("testdata/handler_test/messages/raw.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/emails/raw", bytes.NewReader(message))
+	request.Header.Set("Content-Type", "message/rfc822")
+	request.Header.Set("X-Email-Id", testRawEmailId)
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service, WithSenderRegistry(NewSenderRegistry([]SenderIdentity{{Domain: "example.com"}})))
+
+	sut.ServeRaw(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.JSONEq(t, `{"error": "error validating message: Key: 'rawEmailInput.From' Error:Field validation for 'From' failed on the 'verified_sender' tag"}`, response.Body.String())
+	assert.Empty(t, service.requests)
+}
+
+func TestCreateEmailHandler_ServeRaw_HeaderPolicy(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		policy             *HeaderPolicy
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "default policy - 201",
+			policy:             NewHeaderPolicy(nil, nil),
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{}`,
+		},
+		{
+			name:               "custom header without allowed prefix - 400",
+			policy:             NewHeaderPolicy(nil, []string{"X-"}),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating message: header Organization is not allowed"}`,
+		},
+		{
+			name:               "denied custom header - 400",
+			policy:             NewHeaderPolicy([]string{"X-Campaign"}, nil),
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating message: header X-Campaign is not allowed"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			message, err := os.ReadFile("testdata/handler_test/messages/raw-custom-headers.eml")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := httptest.NewRequest(http.MethodPost, "/emails/raw", bytes.NewReader(message))
+			request.Header.Set("Content-Type", "message/rfc822")
+			request.Header.Set("X-Email-Id", testRawEmailId)
+			response := httptest.NewRecorder()
+
+			service := newEmailServiceMock(nil)
+			sut := NewCreateEmailHandler(service, WithHeaderPolicy(tc.policy))
+
+			sut.ServeRaw(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+		})
+	}
+}
+
+func TestRemoveHeaderField(t *testing.T) {
+	t.Parallel()
+
+	message := "From: news@acme.com\r\nBcc: one@example.com,\r\n two@example.com\r\nSubject: Hi\r\nbcc: three@example.com\r\n\r\nBcc: kept in the body\r\n"
+
+	assert.Equal(t,
+		"From: news@acme.com\r\nSubject: Hi\r\n\r\nBcc: kept in the body\r\n",
+		string(removeHeaderField([]byte(message), "Bcc")),
+	)
+	assert.Equal(t, "Subject: Hi\n\nBody", string(removeHeaderField([]byte("Subject: Hi\n\nBody"), "Bcc")))
+}
+
+func TestCreateEmailHandler_ServeRaw_DryRun(t *testing.T) {
+	t.Parallel()
+
+	message, err := os.ReadFile("testdata/handler_test/messages/raw.eml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/emails/raw?dry_run=true&id="+testRawEmailId, bytes.NewReader(message))
+	request.Header.Set("Content-Type", "message/rfc822")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeRaw(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "dry-run", response.Header().Get("Preference-Applied"))
+	assert.Empty(t, service.requests)
+	assert.Len(t, service.validated, 1)
+}
+
+func TestCreateEmailHandler_ServeRaw_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	message := "From: news@acme.com\r\nTo: user@example.com\r\nSubject: Large\r\n\r\n" + strings.Repeat("a", maxRawMessageBytes)
+
+	request := httptest.NewRequest(http.MethodPost, "/emails/raw?id="+testRawEmailId, strings.NewReader(message))
+	request.Header.Set("Content-Type", "message/rfc822")
+	response := httptest.NewRecorder()
+
+	service := newEmailServiceMock(nil)
+	sut := NewCreateEmailHandler(service)
+
+	sut.ServeRaw(response, request)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, response.Code)
+	assert.Empty(t, service.requests)
+}
@@ -30,7 +30,7 @@ func (h *RequeueEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := h.emailService.RequeueEmail(context.TODO(), id); err != nil {
+	if err := h.emailService.RequeueEmail(r.Context(), id); err != nil {
 		slog.Error(fmt.Sprintf("error requeuing email: %v", err))
 		response.WriteError(http.StatusInternalServerError, w, "error requeuing email")
 		return
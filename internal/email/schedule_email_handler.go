@@ -0,0 +1,100 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"multicarrier-email-api/internal/response"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type scheduleEmailServiceInterface interface {
+	RescheduleEmail(ctx context.Context, id string, sendAt time.Time) error
+	CancelScheduledEmail(ctx context.Context, id string) error
+}
+
+type rescheduleEmailRequestBody struct {
+	SendAt *time.Time `json:"send_at" validate:"required"`
+}
+
+// writeScheduleError maps the errors of scheduled email operations to HTTP statuses
+func writeScheduleError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, ErrEmailNotFound):
+		response.WriteError(http.StatusNotFound, w, "email not found")
+	case errors.Is(err, ErrEmailNotScheduled):
+		response.WriteError(http.StatusConflict, w, "email is not scheduled")
+	default:
+		slog.Error(fmt.Sprintf("error %s scheduled email: %v", action, err))
+		response.WriteError(http.StatusInternalServerError, w, fmt.Sprintf("error %s scheduled email", action))
+	}
+}
+
+type RescheduleEmailHandler struct {
+	emailService scheduleEmailServiceInterface
+}
+
+func NewRescheduleEmailHandler(emailService scheduleEmailServiceInterface) *RescheduleEmailHandler {
+	return &RescheduleEmailHandler{
+		emailService: emailService,
+	}
+}
+
+func (h *RescheduleEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	var requestBody rescheduleEmailRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error unmarshalling request body: %v", err))
+		return
+	}
+
+	validate := validator.New(validator.WithRequiredStructEnabled())
+
+	if err := validate.Struct(requestBody); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating request body: %v", err))
+		return
+	}
+
+	if err := h.emailService.RescheduleEmail(r.Context(), id, *requestBody.SendAt); err != nil {
+		writeScheduleError(w, err, "rescheduling")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type CancelScheduledEmailHandler struct {
+	emailService scheduleEmailServiceInterface
+}
+
+func NewCancelScheduledEmailHandler(emailService scheduleEmailServiceInterface) *CancelScheduledEmailHandler {
+	return &CancelScheduledEmailHandler{
+		emailService: emailService,
+	}
+}
+
+func (h *CancelScheduledEmailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	if err := h.emailService.CancelScheduledEmail(r.Context(), id); err != nil {
+		writeScheduleError(w, err, "cancelling")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
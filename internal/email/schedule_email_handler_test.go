@@ -0,0 +1,186 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scheduleEmailServiceMock struct {
+	returnErr    error
+	calledId     string
+	calledSendAt time.Time
+}
+
+func (m *scheduleEmailServiceMock) RescheduleEmail(_ context.Context, id string, sendAt time.Time) error {
+	m.calledId = id
+	m.calledSendAt = sendAt
+	return m.returnErr
+}
+
+func (m *scheduleEmailServiceMock) CancelScheduledEmail(_ context.Context, id string) error {
+	m.calledId = id
+	return m.returnErr
+}
+
+func TestRescheduleEmailHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		emailId            string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			emailId:            "test-id-123",
+			body:               `{"send_at": "2030-01-01T09:00:00Z"}`,
+			expectedStatusCode: http.StatusNoContent,
+			expectedBody:       "",
+		},
+		{
+			name:               "missing send_at",
+			emailId:            "test-id-123",
+			body:               `{}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'rescheduleEmailRequestBody.SendAt' Error:Field validation for 'SendAt' failed on the 'required' tag"}`,
+		},
+		{
+			name:               "not found",
+			serviceErr:         fmt.Errorf("%w: test-id-123", ErrEmailNotFound),
+			emailId:            "test-id-123",
+			body:               `{"send_at": "2030-01-01T09:00:00Z"}`,
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "email not found"}`,
+		},
+		{
+			name:               "not scheduled",
+			serviceErr:         fmt.Errorf("%w: test-id-123 has status SENT", ErrEmailNotScheduled),
+			emailId:            "test-id-123",
+			body:               `{"send_at": "2030-01-01T09:00:00Z"}`,
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error": "email is not scheduled"}`,
+		},
+		{
+			name:               "service error",
+			serviceErr:         errors.New("mock error"),
+			emailId:            "test-id-123",
+			body:               `{"send_at": "2030-01-01T09:00:00Z"}`,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error rescheduling scheduled email"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/emails/"+tc.emailId+"/schedule", strings.NewReader(tc.body))
+			request.SetPathValue("id", tc.emailId)
+			response := httptest.NewRecorder()
+
+			service := &scheduleEmailServiceMock{returnErr: tc.serviceErr}
+			sut := NewRescheduleEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			if tc.expectedBody == "" {
+				assert.Empty(t, response.Body.String())
+				assert.Equal(t, tc.emailId, service.calledId)
+				assert.Equal(t, time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC), service.calledSendAt.UTC())
+			} else {
+				assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			}
+		})
+	}
+}
+
+func TestRescheduleEmailHandler_ServeHTTP_InvalidSendAt(t *testing.T) {
+	t.Parallel()
+
+	request := httptest.NewRequest(http.MethodPut, "/emails/test-id-123/schedule", strings.NewReader(`{"send_at": "tomorrow"}`))
+	request.SetPathValue("id", "test-id-123")
+	response := httptest.NewRecorder()
+
+	service := &scheduleEmailServiceMock{}
+	sut := NewRescheduleEmailHandler(service)
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+	assert.Contains(t, response.Body.String(), "error unmarshalling request body")
+	assert.Empty(t, service.calledId)
+}
+
+func TestCancelScheduledEmailHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		emailId            string
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			emailId:            "test-id-123",
+			expectedStatusCode: http.StatusNoContent,
+			expectedBody:       "",
+		},
+		{
+			name:               "missing id",
+			emailId:            "",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "id parameter is required"}`,
+		},
+		{
+			name:               "not scheduled",
+			serviceErr:         fmt.Errorf("%w: test-id-123 has status ACCEPTED", ErrEmailNotScheduled),
+			emailId:            "test-id-123",
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error": "email is not scheduled"}`,
+		},
+		{
+			name:               "service error",
+			serviceErr:         errors.New("mock error"),
+			emailId:            "test-id-123",
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error cancelling scheduled email"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, "/emails/"+tc.emailId+"/schedule", nil)
+			request.SetPathValue("id", tc.emailId)
+			response := httptest.NewRecorder()
+
+			service := &scheduleEmailServiceMock{returnErr: tc.serviceErr}
+			sut := NewCancelScheduledEmailHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			if tc.expectedBody == "" {
+				assert.Empty(t, response.Body.String())
+				assert.Equal(t, tc.emailId, service.calledId)
+			} else {
+				assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			}
+		})
+	}
+}
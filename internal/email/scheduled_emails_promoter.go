@@ -0,0 +1,74 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"multicarrier-email-api/internal/auth"
+)
+
+// scheduledEmailsPromoterBatchSize is the maximum number of emails released per query
+const scheduledEmailsPromoterBatchSize = 500
+
+type scheduledEmailsDatabaseInterface interface {
+	PromoteScheduledEmails(ctx context.Context, limit int) (int, error)
+}
+
+// ScheduledEmailsPromoter periodically moves due SCHEDULED emails to ACCEPTED,
+// so that they are picked up by the sending pipeline
+type ScheduledEmailsPromoter struct {
+	db       scheduledEmailsDatabaseInterface
+	interval time.Duration
+}
+
+func NewScheduledEmailsPromoter(db scheduledEmailsDatabaseInterface, interval time.Duration) *ScheduledEmailsPromoter {
+	return &ScheduledEmailsPromoter{
+		db:       db,
+		interval: interval,
+	}
+}
+
+// Run promotes due emails every interval until the context is done
+func (p *ScheduledEmailsPromoter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.PromoteDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PromoteDue releases due emails in batches until none is left, returning how many were released.
+// Due emails of every tenant are released.
+func (p *ScheduledEmailsPromoter) PromoteDue(ctx context.Context) int {
+	ctx = auth.NewSystemContext(ctx)
+	total := 0
+
+	for ctx.Err() == nil {
+		promoted, err := p.db.PromoteScheduledEmails(ctx, scheduledEmailsPromoterBatchSize)
+		if err != nil {
+			slog.Error(fmt.Sprintf("error promoting scheduled emails: %v", err))
+			break
+		}
+
+		total += promoted
+
+		if promoted < scheduledEmailsPromoterBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		slog.Info(fmt.Sprintf("promoted %d scheduled emails", total))
+	}
+
+	return total
+}
@@ -0,0 +1,102 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scheduledEmailsDatabaseMock struct {
+	promotedPerCall []int
+	returnErr       error
+	callCount       int
+}
+
+func (m *scheduledEmailsDatabaseMock) PromoteScheduledEmails(_ context.Context, _ int) (int, error) {
+	m.callCount++
+
+	if m.returnErr != nil {
+		return 0, m.returnErr
+	}
+
+	if m.callCount > len(m.promotedPerCall) {
+		return 0, nil
+	}
+	return m.promotedPerCall[m.callCount-1], nil
+}
+
+func TestScheduledEmailsPromoter_PromoteDue(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name              string
+		promotedPerCall   []int
+		returnErr         error
+		expectedTotal     int
+		expectedCallCount int
+	}{
+		{
+			name:              "nothing due",
+			promotedPerCall:   []int{0},
+			expectedTotal:     0,
+			expectedCallCount: 1,
+		},
+		{
+			name:              "less than a batch",
+			promotedPerCall:   []int{12},
+			expectedTotal:     12,
+			expectedCallCount: 1,
+		},
+		{
+			name:              "full batches are followed by another query",
+			promotedPerCall:   []int{scheduledEmailsPromoterBatchSize, scheduledEmailsPromoterBatchSize, 3},
+			expectedTotal:     2*scheduledEmailsPromoterBatchSize + 3,
+			expectedCallCount: 3,
+		},
+		{
+			name:              "database error",
+			returnErr:         errors.New("mock error"),
+			expectedTotal:     0,
+			expectedCallCount: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			database := &scheduledEmailsDatabaseMock{promotedPerCall: tc.promotedPerCall, returnErr: tc.returnErr}
+			sut := NewScheduledEmailsPromoter(database, time.Minute)
+
+			total := sut.PromoteDue(context.TODO())
+
+			assert.Equal(t, tc.expectedTotal, total)
+			assert.Equal(t, tc.expectedCallCount, database.callCount)
+		})
+	}
+}
+
+func TestScheduledEmailsPromoter_RunStopsWithContext(t *testing.T) {
+	t.Parallel()
+
+	database := &scheduledEmailsDatabaseMock{}
+	sut := NewScheduledEmailsPromoter(database, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		sut.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("promoter did not stop after context cancellation")
+	}
+}
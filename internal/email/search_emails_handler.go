@@ -0,0 +1,96 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"multicarrier-email-api/internal/response"
+)
+
+const (
+	defaultSearchLimit  = 100
+	maxSearchLimit      = 1000
+	metadataParamPrefix = "meta."
+)
+
+type searchEmailsServiceInterface interface {
+	SearchEmails(ctx context.Context, params SearchParams) ([]Email, error)
+}
+
+// SearchEmailsHandler lists the emails matching every tag and meta.<key> query parameter,
+// most recent first
+type SearchEmailsHandler struct {
+	emailService searchEmailsServiceInterface
+}
+
+func NewSearchEmailsHandler(emailService searchEmailsServiceInterface) *SearchEmailsHandler {
+	return &SearchEmailsHandler{
+		emailService: emailService,
+	}
+}
+
+func parseSearchParams(r *http.Request) (SearchParams, error) {
+	params := SearchParams{
+		Metadata: make(map[string]string),
+		Limit:    defaultSearchLimit,
+	}
+
+	for name, values := range r.URL.Query() {
+		switch {
+		case name == "tag":
+			params.Tags = append(params.Tags, values...)
+		case strings.HasPrefix(name, metadataParamPrefix) && len(name) > len(metadataParamPrefix):
+			if len(values) > 1 {
+				return params, fmt.Errorf("%s can be given only once", name)
+			}
+			params.Metadata[strings.TrimPrefix(name, metadataParamPrefix)] = values[0]
+		case name == "limit":
+			limit, err := strconv.Atoi(values[0])
+			if err != nil || limit < 1 || limit > maxSearchLimit {
+				return params, fmt.Errorf("limit must be an integer between 1 and %d", maxSearchLimit)
+			}
+			params.Limit = limit
+		default:
+			return params, fmt.Errorf("unknown query parameter %s", name)
+		}
+	}
+
+	if len(params.Tags) == 0 && len(params.Metadata) == 0 {
+		return params, fmt.Errorf("at least one tag or meta.<key> parameter is required")
+	}
+
+	return params, nil
+}
+
+func (h *SearchEmailsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	params, err := parseSearchParams(r)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, err.Error())
+		return
+	}
+
+	emails, err := h.emailService.SearchEmails(r.Context(), params)
+	if err != nil {
+		slog.Error(fmt.Sprintf("error searching emails: %v", err))
+		response.WriteError(http.StatusInternalServerError, w, "error searching emails")
+		return
+	}
+
+	if emails == nil {
+		emails = []Email{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(w).Encode(emails); err != nil {
+		slog.Error(fmt.Sprintf("error encoding response: %v", err))
+		response.WriteError(http.StatusInternalServerError, w, "error encoding response")
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type searchEmailsServiceMock struct {
+	returnErr      error
+	emails         []Email
+	receivedParams SearchParams
+}
+
+func (m *searchEmailsServiceMock) SearchEmails(_ context.Context, params SearchParams) ([]Email, error) {
+	m.receivedParams = params
+	if m.returnErr != nil {
+		return nil, m.returnErr
+	}
+	return m.emails, nil
+}
+
+func TestSearchEmailsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	fixedTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	type caseStruct struct {
+		name               string
+		query              string
+		serviceErr         error
+		emails             []Email
+		expectedStatusCode int
+		expectedParams     SearchParams
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:  "tags and metadata",
+			query: "?tag=invoice&tag=2024&meta.invoice_id=INV-42&limit=10",
+			emails: []Email{
+				{Id: "test-id-1", Status: StatusSent, Priority: PriorityNormal, CreatedAt: fixedTime, UpdatedAt: fixedTime},
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedParams: SearchParams{
+				Tags:     []string{"invoice", "2024"},
+				Metadata: map[string]string{"invoice_id": "INV-42"},
+				Limit:    10,
+			},
+			expectedBody: `[{"id":"test-id-1","status":"SENT","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T12:00:00Z"}]`,
+		},
+		{
+			name:               "no match",
+			query:              "?meta.campaign=spring",
+			expectedStatusCode: http.StatusOK,
+			expectedParams: SearchParams{
+				Metadata: map[string]string{"campaign": "spring"},
+				Limit:    defaultSearchLimit,
+			},
+			expectedBody: `[]`,
+		},
+		{
+			name:               "no filter",
+			query:              "",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "at least one tag or meta.<key> parameter is required"}`,
+		},
+		{
+			name:               "unknown parameter",
+			query:              "?status=SENT",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "unknown query parameter status"}`,
+		},
+		{
+			name:               "repeated metadata key",
+			query:              "?meta.invoice_id=1&meta.invoice_id=2",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "meta.invoice_id can be given only once"}`,
+		},
+		{
+			name:               "invalid limit",
+			query:              "?tag=invoice&limit=5000",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "limit must be an integer between 1 and 1000"}`,
+		},
+		{
+			name:               "service error",
+			query:              "?tag=invoice",
+			serviceErr:         errors.New("mock error"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedParams: SearchParams{
+				Tags:     []string{"invoice"},
+				Metadata: map[string]string{},
+				Limit:    defaultSearchLimit,
+			},
+			expectedBody: `{"error": "error searching emails"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/emails"+tc.query, nil)
+			response := httptest.NewRecorder()
+
+			service := &searchEmailsServiceMock{returnErr: tc.serviceErr, emails: tc.emails}
+			sut := NewSearchEmailsHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.Equal(t, tc.expectedParams, service.receivedParams)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+		})
+	}
+}
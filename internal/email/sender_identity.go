@@ -0,0 +1,83 @@
+package email
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// SenderIdentity is a sender verified for SPF and DKIM, either a single address or a whole domain
+type SenderIdentity struct {
+	Address string
+	Domain  string
+	// ReplyTo is used for emails of the identity without reply_to
+	ReplyTo string
+	// Tenant restricts the identity to the emails of a tenant, empty means every tenant
+	Tenant string
+}
+
+// SenderRegistry lists the identities emails may be sent from
+type SenderRegistry struct {
+	identities []SenderIdentity
+}
+
+func NewSenderRegistry(identities []SenderIdentity) *SenderRegistry {
+	return &SenderRegistry{identities: identities}
+}
+
+// Resolve returns the identity of a from address for a tenant. Address identities are preferred
+// over domain ones.
+func (r *SenderRegistry) Resolve(tenant string, from string) (SenderIdentity, bool) {
+	from = strings.ToLower(from)
+	_, domain, ok := strings.Cut(from, "@")
+	if !ok {
+		return SenderIdentity{}, false
+	}
+
+	var domainIdentity *SenderIdentity
+	for i, identity := range r.identities {
+		if identity.Tenant != "" && identity.Tenant != tenant {
+			continue
+		}
+		if identity.Address != "" && strings.ToLower(identity.Address) == from {
+			return identity, true
+		}
+		if domainIdentity == nil && identity.Domain != "" && strings.ToLower(identity.Domain) == domain {
+			domainIdentity = &r.identities[i]
+		}
+	}
+
+	if domainIdentity == nil {
+		return SenderIdentity{}, false
+	}
+	return *domainIdentity, true
+}
+
+// applySenderDefaults fills a missing reply_to with the one of the sender identity
+func (h *CreateEmailHandler) applySenderDefaults(tenant string, e *emailDataInput) {
+	if h.senderRegistry == nil || e.ReplyTo.Address != "" {
+		return
+	}
+
+	if identity, ok := h.senderRegistry.Resolve(tenant, e.From.Address); ok && identity.ReplyTo != "" {
+		e.ReplyTo = parseAddress(identity.ReplyTo)
+	}
+}
+
+// validateVerifiedSender returns the validation function of the verified_sender tag, which accepts
+// any from address when there is no registry
+func validateVerifiedSender(registry *SenderRegistry, tenant string) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		if registry == nil {
+			return true
+		}
+
+		from, ok := fl.Field().Interface().(Address)
+		if !ok {
+			return false
+		}
+
+		_, verified := registry.Resolve(tenant, from.Address)
+		return verified
+	}
+}
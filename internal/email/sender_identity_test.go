@@ -0,0 +1,45 @@
+package email
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderRegistry_Resolve(t *testing.T) {
+	t.Parallel()
+
+	sut := NewSenderRegistry([]SenderIdentity{
+		{Domain: "acme.com", ReplyTo: "support@acme.com"},
+		{Address: "billing@acme.com", ReplyTo: "accounts@acme.com"},
+		{Domain: "tenant-a.com", Tenant: "tenant-a"},
+	})
+
+	type caseStruct struct {
+		name     string
+		tenant   string
+		from     string
+		expected SenderIdentity
+		verified bool
+	}
+
+	cases := []caseStruct{
+		{"domain identity", "default", "news@acme.com", SenderIdentity{Domain: "acme.com", ReplyTo: "support@acme.com"}, true},
+		{"address identity is preferred", "default", "billing@acme.com", SenderIdentity{Address: "billing@acme.com", ReplyTo: "accounts@acme.com"}, true},
+		{"case insensitive", "default", "News@ACME.com", SenderIdentity{Domain: "acme.com", ReplyTo: "support@acme.com"}, true},
+		{"subdomains are not verified", "default", "news@mail.acme.com", SenderIdentity{}, false},
+		{"identity of the tenant", "tenant-a", "news@tenant-a.com", SenderIdentity{Domain: "tenant-a.com", Tenant: "tenant-a"}, true},
+		{"identity of another tenant", "tenant-b", "news@tenant-a.com", SenderIdentity{}, false},
+		{"unknown domain", "default", "news@example.com", SenderIdentity{}, false},
+		{"invalid address", "default", "acme.com", SenderIdentity{}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			identity, verified := sut.Resolve(c.tenant, c.from)
+
+			assert.Equal(t, c.verified, verified)
+			assert.Equal(t, c.expected, identity)
+		})
+	}
+}
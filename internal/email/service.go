@@ -2,7 +2,20 @@ package email
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/eml"
 )
 
 const (
@@ -10,101 +23,560 @@ const (
 	ErrorCodeStorageError   = "STORAGE_ERROR"
 	ErrorCodeDatabaseError  = "DATABASE_ERROR"
 	ErrorCodeTransientError = "TRANSIENT_ERROR"
+	// ErrorCodeAttachmentTooLarge is returned when uploaded attachment content exceeds the size limits
+	ErrorCodeAttachmentTooLarge = "ATTACHMENT_TOO_LARGE"
+	// ErrorCodeAttachmentError is returned when an attachment path is missing, unreadable or not allowed
+	ErrorCodeAttachmentError = "ATTACHMENT_ERROR"
+	// ErrorCodeRecipientSuppressed is returned when a recipient is in the suppression list of the tenant
+	ErrorCodeRecipientSuppressed = "RECIPIENT_SUPPRESSED"
+	// ErrorCodeRequestCanceled is returned for the emails of a batch not processed before the request was canceled
+	ErrorCodeRequestCanceled = "REQUEST_CANCELED"
 )
 
 const (
-	ErrorMessageDuplicatedID   = "Email with this ID already exists"
-	ErrorMessageStorageError   = "Failed to store email payload"
-	ErrorMessageDatabaseError  = "Failed to save email to database"
-	ErrorMessageTransientError = "Temporary database error, retry possible"
+	ErrorMessageDuplicatedID    = "Email with this ID already exists"
+	ErrorMessagePayloadChanged  = "Email with this ID already exists with a different payload"
+	ErrorMessageStorageError    = "Failed to store email payload"
+	ErrorMessageDatabaseError   = "Failed to save email to database"
+	ErrorMessageTransientError  = "Temporary database error, retry possible"
+	ErrorMessageInvalidContent  = "Invalid attachment content"
+	ErrorMessageRequestCanceled = "Request canceled before the email was processed"
 )
 
+// defaultSaveConcurrency is the number of emails of a batch processed at the same time
+const defaultSaveConcurrency = 8
+
 type EmailRequest struct {
 	MessageId    string
 	PayloadBytes []byte
+	SendAt       *time.Time
+	// Priority is high, normal or bulk, empty means normal
+	Priority string
+	Tags     []string
+	Metadata map[string]string
+	// Recipients are the to, cc and bcc addresses, checked against the suppression list
+	Recipients []string
+	// Raw requests hold a pre-rendered RFC 5322 message in PayloadBytes, which is stored as the EML
+	// of the email. Its attachments are part of the message, so attachment checks do not apply.
+	Raw bool
 }
 
 type SaveResult struct {
-	MessageId    string
-	Success      bool
-	ErrorCode    string
-	ErrorMessage string
+	MessageId string
+	Success   bool
+	// AlreadyAccepted is set when the same payload had already been accepted under this ID
+	AlreadyAccepted bool
+	ErrorCode       string
+	ErrorMessage    string
 }
 
 type payloadStorageInterface interface {
-	Store(messageId string, payload []byte) (string, error)
+	Store(tenant string, messageId string, payload []byte) (string, error)
+	StoreEML(tenant string, messageId string, message []byte) (string, error)
+	StoreAttachment(tenant string, messageId string, index int, name string, content []byte) (string, error)
+	StoreEnvelope(tenant string, messageId string, envelope []byte) (string, error)
+	Read(path string) ([]byte, error)
 	Delete(payloadPath string) error
 }
 
+type attachmentValidatorInterface interface {
+	Validate(path string) error
+}
+
+type suppressionListInterface interface {
+	FindSuppressed(ctx context.Context, addresses []string) ([]string, error)
+}
+
+type messageSignerInterface interface {
+	Sign(message []byte) ([]byte, error)
+	HasKey(domain string) bool
+}
+
 type databaseInterface interface {
-	Insert(ctx context.Context, id string, payloadPath string) error
+	Insert(ctx context.Context, params InsertParams) error
+	InsertBatch(ctx context.Context, params []InsertParams) error
+	GetPayloadHash(ctx context.Context, id string) (string, error)
+	GetStoredFilePaths(ctx context.Context, id string) (StoredFilePaths, error)
 	GetStaleEmails(ctx context.Context) ([]Email, error)
 	GetInvalidEmails(ctx context.Context) ([]Email, error)
+	SearchEmails(ctx context.Context, params SearchParams) ([]Email, error)
 	RequeueEmail(ctx context.Context, id string) error
+	RescheduleEmail(ctx context.Context, id string, sendAt time.Time) error
+	CancelScheduledEmail(ctx context.Context, id string) error
 }
 
 type Service struct {
-	payloadStorage payloadStorageInterface
-	db             databaseInterface
+	payloadStorage          payloadStorageInterface
+	db                      databaseInterface
+	maxAttachmentSize       int64
+	maxTotalAttachmentsSize int64
+	attachmentValidator     attachmentValidatorInterface
+	suppressionList         suppressionListInterface
+	emlRenderer             *eml.Renderer
+	dkimSigner              messageSignerInterface
+	saveConcurrency         int
+}
+
+type ServiceOption func(*Service)
+
+// WithAttachmentSizeLimits caps the decoded size of each content_base64 attachment and of all the
+// uploaded attachments of an email. A limit of zero means unlimited.
+func WithAttachmentSizeLimits(maxAttachmentSize int64, maxTotalAttachmentsSize int64) ServiceOption {
+	return func(s *Service) {
+		s.maxAttachmentSize = maxAttachmentSize
+		s.maxTotalAttachmentsSize = maxTotalAttachmentsSize
+	}
+}
+
+// WithAttachmentValidator checks attachment paths while saving, so that invalid ones are reported
+// as ATTACHMENT_ERROR results
+func WithAttachmentValidator(attachmentValidator attachmentValidatorInterface) ServiceOption {
+	return func(s *Service) {
+		s.attachmentValidator = attachmentValidator
+	}
+}
+
+// WithSuppressionList rejects emails to suppressed recipients as RECIPIENT_SUPPRESSED results
+func WithSuppressionList(suppressionList suppressionListInterface) ServiceOption {
+	return func(s *Service) {
+		s.suppressionList = suppressionList
+	}
 }
 
-func NewService(payloadStorage payloadStorageInterface, db databaseInterface) *Service {
-	return &Service{
-		payloadStorage: payloadStorage,
-		db:             db,
+// WithDKIMSigner signs the messages of the saved emails, which are stored for delivery with their
+// envelope, and of the previews. Messages from domains without a DKIM key are left unsigned, for the
+// carrier to sign.
+func WithDKIMSigner(signer messageSignerInterface) ServiceOption {
+	return func(s *Service) {
+		s.dkimSigner = signer
 	}
 }
 
+// WithSaveConcurrency sets the number of emails of a batch processed at the same time, each using a
+// database connection while it is saved. Values below one keep the default.
+func WithSaveConcurrency(concurrency int) ServiceOption {
+	return func(s *Service) {
+		if concurrency > 0 {
+			s.saveConcurrency = concurrency
+		}
+	}
+}
+
+func NewService(payloadStorage payloadStorageInterface, db databaseInterface, opts ...ServiceOption) *Service {
+	s := &Service{
+		payloadStorage:  payloadStorage,
+		db:              db,
+		emlRenderer:     eml.NewRenderer(),
+		saveConcurrency: defaultSaveConcurrency,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
 func (s *Service) tryDelete(payloadPath string) {
 	if deleteErr := s.payloadStorage.Delete(payloadPath); deleteErr != nil {
 		log.Printf("failed to delete payload file '%s': %v", payloadPath, deleteErr)
 	}
 }
 
-func (s *Service) Save(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
-	results := make([]SaveResult, len(emailRequests))
+// tryDeleteAttachments removes stored attachment files and the message directory holding them
+func (s *Service) tryDeleteAttachments(attachmentPaths []string) {
+	for _, attachmentPath := range attachmentPaths {
+		s.tryDelete(attachmentPath)
+	}
+	if len(attachmentPaths) > 0 {
+		s.tryDelete(filepath.Dir(attachmentPaths[0]))
+	}
+}
 
-	for i, req := range emailRequests {
-		result := SaveResult{
-			MessageId: req.MessageId,
-			Success:   true,
-		}
+// payloadHash returns the hex encoded SHA-256 of the canonical payload
+func payloadHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
 
-		payloadPath, err := s.payloadStorage.Store(req.MessageId, req.PayloadBytes)
+// resolveExistingId tells whether an already stored email with the same ID is a retry of the
+// same payload, which is reported as success, or a conflicting payload
+func resolveExistingId(messageId string, hash string, existingHash string, lookupErr error) SaveResult {
+	result := SaveResult{MessageId: messageId}
+
+	switch {
+	case lookupErr == nil && existingHash == hash:
+		result.Success = true
+		result.AlreadyAccepted = true
+	case lookupErr == nil && existingHash != "":
+		result.ErrorCode = ErrorCodeDuplicatedID
+		result.ErrorMessage = ErrorMessagePayloadChanged
+	case lookupErr == nil || errors.Is(lookupErr, ErrEmailNotFound) || errors.Is(lookupErr, ErrEmailIdTaken):
+		result.ErrorCode = ErrorCodeDuplicatedID
+		result.ErrorMessage = ErrorMessageDuplicatedID
+	default:
+		log.Printf("failed to get payload hash for '%s': %v", messageId, lookupErr)
+		result.ErrorCode = ErrorCodeDatabaseError
+		result.ErrorMessage = ErrorMessageDatabaseError
+	}
+
+	return result
+}
+
+// checkOne runs the checks of an email that come before storing it. It returns false, with the
+// result of the email, when the email must not be stored because a check failed or because it was
+// already accepted.
+func (s *Service) checkOne(ctx context.Context, req EmailRequest, hash string) (SaveResult, bool) {
+	result := SaveResult{
+		MessageId: req.MessageId,
+		Success:   true,
+	}
+
+	// a resubmitted ID is resolved before storing, so that the accepted payload file is not overwritten
+	existingHash, err := s.db.GetPayloadHash(ctx, req.MessageId)
+	if !errors.Is(err, ErrEmailNotFound) {
+		return resolveExistingId(req.MessageId, hash, existingHash, err), false
+	}
+
+	if s.suppressionList != nil && len(req.Recipients) > 0 {
+		suppressed, err := s.suppressionList.FindSuppressed(ctx, req.Recipients)
 		if err != nil {
-			log.Printf("failed to create payload file for '%s': %v", req.MessageId, err)
+			log.Printf("failed to check suppressed recipients for '%s': %v", req.MessageId, err)
 			result.Success = false
-			result.ErrorCode = ErrorCodeStorageError
-			result.ErrorMessage = ErrorMessageStorageError
-			results[i] = result
-			continue
+			result.ErrorCode = ErrorCodeDatabaseError
+			result.ErrorMessage = ErrorMessageDatabaseError
+			return result, false
 		}
+		if len(suppressed) > 0 {
+			result.Success = false
+			result.ErrorCode = ErrorCodeRecipientSuppressed
+			result.ErrorMessage = fmt.Sprintf("Recipients are suppressed: %s", strings.Join(suppressed, ", "))
+			return result, false
+		}
+	}
 
-		if err := s.db.Insert(ctx, req.MessageId, payloadPath); err != nil {
-			log.Printf("failed to insert record in database for '%s': %v", req.MessageId, err)
+	if req.Raw {
+		return result, true
+	}
 
-			s.tryDelete(payloadPath)
+	if err := s.validateAttachmentPaths(req.PayloadBytes); err != nil {
+		result.Success = false
+		result.ErrorCode = ErrorCodeAttachmentError
+		result.ErrorMessage = err.Error()
+		return result, false
+	}
 
-			result.Success = false
+	if err := s.checkSignedAttachments(req.PayloadBytes); err != nil {
+		result.Success = false
+		result.ErrorCode = ErrorCodeAttachmentError
+		result.ErrorMessage = err.Error()
+		return result, false
+	}
 
-			if IsDuplicateEntryError(err) {
-				result.ErrorCode = ErrorCodeDuplicatedID
-				result.ErrorMessage = ErrorMessageDuplicatedID
-			} else {
-				result.ErrorCode = ErrorCodeDatabaseError
-				result.ErrorMessage = ErrorMessageDatabaseError
-			}
+	return result, true
+}
+
+// storageFailure returns the result of an email whose uploaded attachments could not be decoded
+// or stored, or whose payload could not be stored
+func storageFailure(messageId string, err error) SaveResult {
+	result := SaveResult{MessageId: messageId}
+
+	switch {
+	case errors.Is(err, errAttachmentTooLarge):
+		result.ErrorCode = ErrorCodeAttachmentTooLarge
+		result.ErrorMessage = err.Error()
+	case errors.Is(err, errInvalidAttachmentContent):
+		result.ErrorCode = ErrorCodeInvalidPayload
+		result.ErrorMessage = ErrorMessageInvalidContent
+	case errors.Is(err, errAttachmentNotReadable):
+		result.ErrorCode = ErrorCodeAttachmentError
+		result.ErrorMessage = err.Error()
+	case errors.Is(err, fs.ErrExist):
+		// the files of another submission of the ID, which are left as they are
+		result.ErrorCode = ErrorCodeDuplicatedID
+		result.ErrorMessage = ErrorMessageDuplicatedID
+	default:
+		result.ErrorCode = ErrorCodeStorageError
+		result.ErrorMessage = ErrorMessageStorageError
+	}
+
+	return result
+}
+
+// storedFiles are the files written for an email, removed when its row is not inserted
+type storedFiles struct {
+	payloadPath     string
+	emlPath         string
+	envelopePath    string
+	attachmentPaths []string
+}
+
+func (s *Service) deleteStored(files storedFiles) {
+	if files.payloadPath != "" {
+		s.tryDelete(files.payloadPath)
+	}
+	if files.emlPath != "" {
+		s.tryDelete(files.emlPath)
+	}
+	if files.envelopePath != "" {
+		s.tryDelete(files.envelopePath)
+	}
+	s.tryDeleteAttachments(files.attachmentPaths)
+}
+
+// storePayload writes the JSON payload of an email, or the message of raw emails
+func (s *Service) storePayload(tenant string, req EmailRequest, payload []byte) (string, error) {
+	if req.Raw {
+		return s.payloadStorage.StoreEML(tenant, req.MessageId, payload)
+	}
+	return s.payloadStorage.Store(tenant, req.MessageId, payload)
+}
+
+// storeEnvelope writes the envelope of a message delivered as it is stored, with the to, cc and
+// bcc recipients of the email
+func (s *Service) storeEnvelope(tenant string, req EmailRequest) (string, error) {
+	data, err := json.Marshal(envelope{Recipients: req.Recipients})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode envelope: %w", err)
+	}
+	return s.payloadStorage.StoreEnvelope(tenant, req.MessageId, data)
+}
+
+// store writes the uploaded attachments and the payload of an email under the tenant of the
+// caller, and returns the values of its row. Nothing written by the call is left on storage when
+// it fails, and existing files are never overwritten.
+func (s *Service) store(ctx context.Context, req EmailRequest, hash string) (InsertParams, storedFiles, error) {
+	var files storedFiles
+	tenant := auth.TenantOf(ctx)
 
-			results[i] = result
-			continue
+	payload := req.PayloadBytes
+	if !req.Raw {
+		var err error
+		payload, files.attachmentPaths, err = s.storeAttachmentContents(tenant, req.MessageId, req.PayloadBytes)
+		if err != nil {
+			log.Printf("failed to store attachments for '%s': %v", req.MessageId, err)
+			s.tryDeleteAttachments(files.attachmentPaths)
+			return InsertParams{}, storedFiles{}, err
+		}
+	}
+
+	signed, err := s.signedMessage(req, payload)
+	if err != nil {
+		log.Printf("failed to sign message for '%s': %v", req.MessageId, err)
+		s.tryDeleteAttachments(files.attachmentPaths)
+		return InsertParams{}, storedFiles{}, err
+	}
+	if req.Raw && signed != nil {
+		payload = signed
+	}
+
+	payloadPath, err := s.storePayload(tenant, req, payload)
+	if err != nil {
+		log.Printf("failed to create payload file for '%s': %v", req.MessageId, err)
+		s.tryDeleteAttachments(files.attachmentPaths)
+		return InsertParams{}, storedFiles{}, err
+	}
+	files.payloadPath = payloadPath
+
+	// the signed message of JSON payloads is delivered as it is, the payload is kept for the callbacks
+	if !req.Raw && signed != nil {
+		files.emlPath, err = s.payloadStorage.StoreEML(tenant, req.MessageId, signed)
+		if err != nil {
+			log.Printf("failed to create message file for '%s': %v", req.MessageId, err)
+			s.deleteStored(files)
+			return InsertParams{}, storedFiles{}, err
+		}
+	}
+
+	// messages delivered as they are stored keep their recipients in an envelope, as the Bcc field
+	// is not part of them
+	if req.Raw || files.emlPath != "" {
+		files.envelopePath, err = s.storeEnvelope(tenant, req)
+		if err != nil {
+			log.Printf("failed to create envelope file for '%s': %v", req.MessageId, err)
+			s.deleteStored(files)
+			return InsertParams{}, storedFiles{}, err
+		}
+	}
+
+	insertParams := InsertParams{
+		Id:          req.MessageId,
+		PayloadHash: hash,
+		SendAt:      req.SendAt,
+		Priority:    req.Priority,
+		Tags:        req.Tags,
+		Metadata:    req.Metadata,
+	}
+	if req.Raw {
+		insertParams.EMLFilePath = payloadPath
+	} else {
+		insertParams.PayloadFilePath = payloadPath
+		insertParams.EMLFilePath = files.emlPath
+	}
+	insertParams.EnvelopeFilePath = files.envelopePath
+
+	return insertParams, files, nil
+}
+
+// insertFailure returns the result of an email whose row could not be inserted. Its files are
+// removed, unless a concurrent submission of the same payload was inserted first.
+func (s *Service) insertFailure(ctx context.Context, messageId string, hash string, files storedFiles, err error) SaveResult {
+	if IsDuplicateEntryError(err) {
+		// a concurrent submission of the same ID won the race
+		existingHash, lookupErr := s.db.GetPayloadHash(ctx, messageId)
+		result := resolveExistingId(messageId, hash, existingHash, lookupErr)
+		if !result.AlreadyAccepted {
+			s.deleteStored(files)
+		}
+		return result
+	}
+
+	s.deleteStored(files)
+
+	return SaveResult{
+		MessageId:    messageId,
+		ErrorCode:    ErrorCodeDatabaseError,
+		ErrorMessage: ErrorMessageDatabaseError,
+	}
+}
+
+func (s *Service) saveOne(ctx context.Context, req EmailRequest) SaveResult {
+	hash := payloadHash(req.PayloadBytes)
+
+	result, ok := s.checkOne(ctx, req, hash)
+	if !ok {
+		return result
+	}
+
+	insertParams, files, err := s.store(ctx, req, hash)
+	if errors.Is(err, fs.ErrExist) {
+		// a concurrent submission of the ID stored its files first
+		if existingHash, lookupErr := s.db.GetPayloadHash(ctx, req.MessageId); !errors.Is(lookupErr, ErrEmailNotFound) {
+			return resolveExistingId(req.MessageId, hash, existingHash, lookupErr)
+		}
+	}
+	if err != nil {
+		return storageFailure(req.MessageId, err)
+	}
+
+	if err := s.db.Insert(ctx, insertParams); err != nil {
+		log.Printf("failed to insert record in database for '%s': %v", req.MessageId, err)
+		return s.insertFailure(ctx, req.MessageId, hash, files, err)
+	}
+
+	return result
+}
+
+// processBatch runs process on the emails of a batch, with up to saveConcurrency emails at the same
+// time, and returns the results in the order of the emails. Emails sharing an ID are processed one
+// after the other, so that the later ones are resolved as resubmissions. Emails not started when
+// the context is done get REQUEST_CANCELED results.
+func (s *Service) processBatch(ctx context.Context, emailRequests []EmailRequest, process func(ctx context.Context, req EmailRequest) SaveResult) []SaveResult {
+	results := make([]SaveResult, len(emailRequests))
+
+	var groups [][]int
+	groupIndexes := make(map[string]int, len(emailRequests))
+	for i, req := range emailRequests {
+		g, ok := groupIndexes[req.MessageId]
+		if !ok {
+			g = len(groups)
+			groupIndexes[req.MessageId] = g
+			groups = append(groups, nil)
 		}
+		groups[g] = append(groups[g], i)
+	}
+
+	jobs := make(chan []int)
+	var wg sync.WaitGroup
+	for range min(max(s.saveConcurrency, 1), len(groups)) {
+		wg.Go(func() {
+			for group := range jobs {
+				for _, i := range group {
+					if ctx.Err() != nil {
+						results[i] = SaveResult{
+							MessageId:    emailRequests[i].MessageId,
+							ErrorCode:    ErrorCodeRequestCanceled,
+							ErrorMessage: ErrorMessageRequestCanceled,
+						}
+						continue
+					}
+					results[i] = process(ctx, emailRequests[i])
+				}
+			}
+		})
+	}
 
-		results[i] = result
+	for _, group := range groups {
+		jobs <- group
 	}
+	close(jobs)
+	wg.Wait()
 
 	return results
 }
 
+func (s *Service) Save(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+	return s.processBatch(ctx, emailRequests, s.saveOne)
+}
+
+// validateOne runs every check of saveOne on an email without storing anything
+func (s *Service) validateOne(ctx context.Context, req EmailRequest) SaveResult {
+	result, ok := s.checkOne(ctx, req, payloadHash(req.PayloadBytes))
+	if !ok || req.Raw {
+		return result
+	}
+
+	if err := s.checkAttachmentContents(req.PayloadBytes); err != nil {
+		return storageFailure(req.MessageId, err)
+	}
+
+	return result
+}
+
+// Validate returns the results Save would return for the emails, without writing payload files or
+// database records
+func (s *Service) Validate(ctx context.Context, emailRequests []EmailRequest) []SaveResult {
+	validated := newValidatedIds()
+	return s.processBatch(ctx, emailRequests, func(ctx context.Context, req EmailRequest) SaveResult {
+		return validated.validate(req, func(req EmailRequest) SaveResult {
+			return s.validateOne(ctx, req)
+		})
+	})
+}
+
+// validatedIds keeps the payload hashes of the emails a dry run found valid. Save stores the first
+// valid copy of an ID, so the later copies are resolved against it as against an already accepted
+// email.
+type validatedIds struct {
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+func newValidatedIds() *validatedIds {
+	return &validatedIds{hashes: make(map[string]string)}
+}
+
+// validate resolves an email against the valid copy of its ID, or validates it with validateOne
+// when it is the first one
+func (v *validatedIds) validate(req EmailRequest, validateOne func(EmailRequest) SaveResult) SaveResult {
+	hash := payloadHash(req.PayloadBytes)
+
+	v.mu.Lock()
+	validHash, seen := v.hashes[req.MessageId]
+	v.mu.Unlock()
+	if seen {
+		return resolveExistingId(req.MessageId, hash, validHash, nil)
+	}
+
+	result := validateOne(req)
+	if result.Success && !result.AlreadyAccepted {
+		v.mu.Lock()
+		v.hashes[req.MessageId] = hash
+		v.mu.Unlock()
+	}
+	return result
+}
+
 func (s *Service) GetStaleEmails(ctx context.Context) ([]Email, error) {
 	return s.db.GetStaleEmails(ctx)
 }
@@ -113,6 +585,18 @@ func (s *Service) GetInvalidEmails(ctx context.Context) ([]Email, error) {
 	return s.db.GetInvalidEmails(ctx)
 }
 
+func (s *Service) SearchEmails(ctx context.Context, params SearchParams) ([]Email, error) {
+	return s.db.SearchEmails(ctx, params)
+}
+
 func (s *Service) RequeueEmail(ctx context.Context, id string) error {
 	return s.db.RequeueEmail(ctx, id)
 }
+
+func (s *Service) RescheduleEmail(ctx context.Context, id string, sendAt time.Time) error {
+	return s.db.RescheduleEmail(ctx, id, sendAt)
+}
+
+func (s *Service) CancelScheduledEmail(ctx context.Context, id string) error {
+	return s.db.CancelScheduledEmail(ctx, id)
+}
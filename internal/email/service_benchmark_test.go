@@ -0,0 +1,56 @@
+package email
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"multicarrier-email-api/internal/auth"
+)
+
+// BenchmarkService_Save saves batches against MySQL, with payload files in a temporary directory,
+// reporting the saved emails per second for each save concurrency:
+//
+//	MYSQL_HOST=... go test ./internal/email -run '^$' -bench BenchmarkService_Save
+func BenchmarkService_Save(b *testing.B) {
+	const batchSize = 100
+
+	db := getTestDB(b)
+	defer db.Close()
+
+	ctx := tenantContext(auth.DefaultTenant)
+
+	for _, concurrency := range []int{1, 4, 8, 16, 32} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			sut := NewService(NewPayloadStorage(b.TempDir()), NewDatabase(db, 30), WithSaveConcurrency(concurrency))
+
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				emailRequests := make([]EmailRequest, batchSize)
+				for j := range emailRequests {
+					id := uuid.NewString()
+					emailRequests[j] = EmailRequest{
+						MessageId:    id,
+						PayloadBytes: []byte(`{"id":"` + id + `","subject":"Benchmark","body_text":"Hello"}`),
+						Tags:         []string{"benchmark"},
+					}
+				}
+				b.StartTimer()
+
+				results := sut.Save(ctx, emailRequests)
+
+				b.StopTimer()
+				for _, result := range results {
+					if !result.Success {
+						b.Fatalf("failed to save %s: %s", result.MessageId, result.ErrorMessage)
+					}
+					cleanupEmail(b, db, result.MessageId)
+				}
+				b.StartTimer()
+			}
+
+			b.ReportMetric(float64(b.N*batchSize)/b.Elapsed().Seconds(), "emails/s")
+		})
+	}
+}
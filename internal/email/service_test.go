@@ -3,7 +3,15 @@ package email
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"multicarrier-email-api/internal/auth"
 
 	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
@@ -12,19 +20,68 @@ import (
 type payloadStorageMock struct {
 	callCount           int
 	errorAfterCallCount int
+	storeError          error
+	storedTenants       []string
+	storedPayloads      [][]byte
+	storedAttachments   []string
+	storedEnvelopes     []string
+	attachmentError     error
+	deletedPaths        []string
+	// files are the contents of the storage, which holds the paths under /storage/
+	files map[string][]byte
 }
 
-func (m *payloadStorageMock) Store(_ string, _ []byte) (string, error) {
+func (m *payloadStorageMock) Store(tenant string, _ string, payload []byte) (string, error) {
 	m.callCount++
+	m.storedTenants = append(m.storedTenants, tenant)
+	m.storedPayloads = append(m.storedPayloads, payload)
 
 	if m.callCount > m.errorAfterCallCount {
+		if m.storeError != nil {
+			return "", m.storeError
+		}
 		return "", errors.New("mock error")
 	}
 
 	return "payload_file", nil
 }
 
-func (m *payloadStorageMock) Delete(_ string) error {
+func (m *payloadStorageMock) StoreEML(tenant string, messageId string, message []byte) (string, error) {
+	if _, err := m.Store(tenant, messageId, message); err != nil {
+		return "", err
+	}
+	return "eml_file", nil
+}
+
+func (m *payloadStorageMock) StoreAttachment(_ string, _ string, index int, name string, content []byte) (string, error) {
+	m.storedAttachments = append(m.storedAttachments, string(content))
+
+	if m.attachmentError != nil {
+		return "", m.attachmentError
+	}
+
+	return fmt.Sprintf("attachments/%d-%s", index, name), nil
+}
+
+func (m *payloadStorageMock) StoreEnvelope(_ string, _ string, envelope []byte) (string, error) {
+	m.storedEnvelopes = append(m.storedEnvelopes, string(envelope))
+	return "envelope_file", nil
+}
+
+func (m *payloadStorageMock) Read(path string) ([]byte, error) {
+	if !strings.HasPrefix(path, "/storage/") {
+		return nil, errOutsidePayloadStorage
+	}
+
+	content, ok := m.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return content, nil
+}
+
+func (m *payloadStorageMock) Delete(payloadPath string) error {
+	m.deletedPaths = append(m.deletedPaths, payloadPath)
 	return nil
 }
 
@@ -32,10 +89,16 @@ type databaseMock struct {
 	insertCallCount           int
 	errorAfterInsertCallCount int
 	insertError               error
+	insertedParams            []InsertParams
+	existingHashes            map[string]string
+	getPayloadHashError       error
+	payloadFilePaths          map[string]string
+	emlFilePaths              map[string]string
 }
 
-func (m *databaseMock) Insert(_ context.Context, _ string, _ string) error {
+func (m *databaseMock) Insert(_ context.Context, params InsertParams) error {
 	m.insertCallCount++
+	m.insertedParams = append(m.insertedParams, params)
 
 	if m.insertCallCount > m.errorAfterInsertCallCount {
 		if m.insertError != nil {
@@ -47,6 +110,39 @@ func (m *databaseMock) Insert(_ context.Context, _ string, _ string) error {
 	return nil
 }
 
+// InsertBatch inserts the rows with Insert, and rolls them back when one fails
+func (m *databaseMock) InsertBatch(ctx context.Context, params []InsertParams) error {
+	inserted := len(m.insertedParams)
+	for i, p := range params {
+		if err := m.Insert(ctx, p); err != nil {
+			m.insertedParams = m.insertedParams[:inserted]
+			return &BatchInsertError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
+func (m *databaseMock) GetPayloadHash(_ context.Context, id string) (string, error) {
+	if m.getPayloadHashError != nil {
+		return "", m.getPayloadHashError
+	}
+
+	hash, ok := m.existingHashes[id]
+	if !ok {
+		return "", ErrEmailNotFound
+	}
+	return hash, nil
+}
+
+func (m *databaseMock) GetStoredFilePaths(_ context.Context, id string) (StoredFilePaths, error) {
+	payloadPath, hasPayload := m.payloadFilePaths[id]
+	emlPath, hasEML := m.emlFilePaths[id]
+	if !hasPayload && !hasEML {
+		return StoredFilePaths{}, ErrEmailNotFound
+	}
+	return StoredFilePaths{PayloadFilePath: payloadPath, EMLFilePath: emlPath}, nil
+}
+
 func (m *databaseMock) GetStaleEmails(_ context.Context) ([]Email, error) {
 	return nil, nil
 }
@@ -55,10 +151,22 @@ func (m *databaseMock) GetInvalidEmails(_ context.Context) ([]Email, error) {
 	return nil, nil
 }
 
+func (m *databaseMock) SearchEmails(_ context.Context, _ SearchParams) ([]Email, error) {
+	return nil, nil
+}
+
 func (m *databaseMock) RequeueEmail(_ context.Context, _ string) error {
 	return nil
 }
 
+func (m *databaseMock) RescheduleEmail(_ context.Context, _ string, _ time.Time) error {
+	return nil
+}
+
+func (m *databaseMock) CancelScheduledEmail(_ context.Context, _ string) error {
+	return nil
+}
+
 func TestService_Save(t *testing.T) {
 	t.Parallel()
 
@@ -212,3 +320,668 @@ func TestService_Save_ErrorTypes(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Save_ExistingFiles(t *testing.T) {
+	t.Parallel()
+
+	emailRequests := []EmailRequest{{MessageId: "msg1", PayloadBytes: []byte("test payload 1")}}
+
+	testCases := []struct {
+		name                    string
+		existingHashes          map[string]string
+		expectedSuccess         bool
+		expectedAlreadyAccepted bool
+		expectedErrorCode       string
+	}{
+		{
+			name:              "files without a row",
+			expectedErrorCode: ErrorCodeDuplicatedID,
+		},
+		{
+			name:                    "concurrent submission of the same payload",
+			existingHashes:          map[string]string{"msg1": payloadHash([]byte("test payload 1"))},
+			expectedSuccess:         true,
+			expectedAlreadyAccepted: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{storeError: fmt.Errorf("failed to create file: %w", fs.ErrExist)}
+			// the row shows up once the files are found, as when a concurrent submission inserts it
+			database := &databaseMock{}
+			sut := &Service{payloadStorage: &existingFilesStorageMock{payloadStorageMock: payloadStorage, database: database, existingHashes: tc.existingHashes}, db: database}
+
+			results := sut.Save(auth.NewContext(context.TODO(), auth.Principal{Tenant: "tenant-a"}), emailRequests)
+
+			assert.Len(t, results, 1)
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedAlreadyAccepted, results[0].AlreadyAccepted)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Equal(t, []string{"tenant-a"}, payloadStorage.storedTenants)
+			assert.Empty(t, payloadStorage.deletedPaths, "files of another submission must not be deleted")
+			assert.Zero(t, database.insertCallCount)
+		})
+	}
+}
+
+// existingFilesStorageMock fails to store like a storage already holding the files of the email,
+// and sets the rows found in the database at that point
+type existingFilesStorageMock struct {
+	*payloadStorageMock
+	database       *databaseMock
+	existingHashes map[string]string
+}
+
+func (m *existingFilesStorageMock) Store(tenant string, messageId string, payload []byte) (string, error) {
+	m.database.existingHashes = m.existingHashes
+	return m.payloadStorageMock.Store(tenant, messageId, payload)
+}
+
+func TestService_Save_PassesSendAt(t *testing.T) {
+	t.Parallel()
+
+	sendAt := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	emailRequests := []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1"), SendAt: &sendAt},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2"), Priority: PriorityBulk},
+	}
+
+	payloadStorage := &payloadStorageMock{errorAfterCallCount: 2}
+	database := &databaseMock{errorAfterInsertCallCount: 2}
+
+	sut := &Service{payloadStorage: payloadStorage, db: database}
+
+	results := sut.Save(context.TODO(), emailRequests)
+
+	assert.True(t, results[0].Success)
+	assert.True(t, results[1].Success)
+	assert.Equal(t, []InsertParams{
+		{Id: "msg1", PayloadFilePath: "payload_file", PayloadHash: payloadHash([]byte("test payload 1")), SendAt: &sendAt},
+		{Id: "msg2", PayloadFilePath: "payload_file", PayloadHash: payloadHash([]byte("test payload 2")), Priority: PriorityBulk},
+	}, database.insertedParams)
+}
+
+func TestService_Save_Resubmission(t *testing.T) {
+	t.Parallel()
+
+	emailRequests := []EmailRequest{
+		{
+			MessageId:    "msg1",
+			PayloadBytes: []byte("test payload 1"),
+		},
+	}
+
+	testCases := []struct {
+		name                    string
+		existingHashes          map[string]string
+		getPayloadHashError     error
+		expectedSuccess         bool
+		expectedAlreadyAccepted bool
+		expectedErrorCode       string
+		expectedErrorMessage    string
+		expectedStoreCallCount  int
+	}{
+		{
+			name:                    "identical payload is already accepted",
+			existingHashes:          map[string]string{"msg1": payloadHash([]byte("test payload 1"))},
+			expectedSuccess:         true,
+			expectedAlreadyAccepted: true,
+		},
+		{
+			name:                 "different payload is a conflict",
+			existingHashes:       map[string]string{"msg1": payloadHash([]byte("another payload"))},
+			expectedErrorCode:    ErrorCodeDuplicatedID,
+			expectedErrorMessage: ErrorMessagePayloadChanged,
+		},
+		{
+			name:                 "legacy row without hash is a conflict",
+			existingHashes:       map[string]string{"msg1": ""},
+			expectedErrorCode:    ErrorCodeDuplicatedID,
+			expectedErrorMessage: ErrorMessageDuplicatedID,
+		},
+		{
+			name:                 "ID used by another tenant is a conflict",
+			getPayloadHashError:  fmt.Errorf("%w: msg1", ErrEmailIdTaken),
+			expectedErrorCode:    ErrorCodeDuplicatedID,
+			expectedErrorMessage: ErrorMessageDuplicatedID,
+		},
+		{
+			name:                 "lookup error",
+			getPayloadHashError:  errors.New("mock error"),
+			expectedErrorCode:    ErrorCodeDatabaseError,
+			expectedErrorMessage: ErrorMessageDatabaseError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: 1}
+			database := &databaseMock{
+				errorAfterInsertCallCount: 1,
+				existingHashes:            tc.existingHashes,
+				getPayloadHashError:       tc.getPayloadHashError,
+			}
+
+			sut := &Service{payloadStorage: payloadStorage, db: database}
+
+			results := sut.Save(context.TODO(), emailRequests)
+
+			assert.Len(t, results, 1)
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedAlreadyAccepted, results[0].AlreadyAccepted)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Equal(t, tc.expectedErrorMessage, results[0].ErrorMessage)
+			assert.Equal(t, 0, payloadStorage.callCount, "payload must not be stored again")
+			assert.Equal(t, 0, database.insertCallCount)
+		})
+	}
+}
+
+func TestService_Save_AttachmentContent(t *testing.T) {
+	t.Parallel()
+
+	payloadWithContent := []byte(`{"id":"msg1","attachments":[` +
+		`{"path":"/efs/attachments/terms.pdf","name":"terms.pdf","disposition":"attachment"},` +
+		`{"content_base64":"aGVsbG8=","name":"hello.txt","disposition":"attachment"},` +
+		`{"content_base64":"d29ybGQh","name":"world.txt","disposition":"attachment"}]}`)
+
+	testCases := []struct {
+		name                      string
+		payload                   []byte
+		opts                      []ServiceOption
+		attachmentError           error
+		insertError               error
+		expectedSuccess           bool
+		expectedErrorCode         string
+		expectedStoredAttachments []string
+		expectedStoredPayload     string
+		expectedDeletedPaths      []string
+	}{
+		{
+			name:                      "content is stored and the payload points to it",
+			payload:                   payloadWithContent,
+			opts:                      []ServiceOption{WithAttachmentSizeLimits(6, 11)},
+			expectedSuccess:           true,
+			expectedStoredAttachments: []string{"hello", "world!"},
+			expectedStoredPayload: `{"id":"msg1","attachments":[` +
+				`{"path":"/efs/attachments/terms.pdf","name":"terms.pdf","disposition":"attachment"},` +
+				`{"path":"attachments/1-hello.txt","name":"hello.txt","disposition":"attachment"},` +
+				`{"path":"attachments/2-world.txt","name":"world.txt","disposition":"attachment"}]}`,
+		},
+		{
+			name:                  "payload without content is stored unchanged",
+			payload:               []byte(`{"id":"msg1","attachments":["/efs/attachments/terms.pdf"]}`),
+			opts:                  []ServiceOption{WithAttachmentSizeLimits(1, 1)},
+			expectedSuccess:       true,
+			expectedStoredPayload: `{"id":"msg1","attachments":["/efs/attachments/terms.pdf"]}`,
+		},
+		{
+			name:              "attachment over the size limit",
+			payload:           payloadWithContent,
+			opts:              []ServiceOption{WithAttachmentSizeLimits(4, 0)},
+			expectedErrorCode: ErrorCodeAttachmentTooLarge,
+		},
+		{
+			name:              "attachments over the total size limit",
+			payload:           payloadWithContent,
+			opts:              []ServiceOption{WithAttachmentSizeLimits(0, 10)},
+			expectedErrorCode: ErrorCodeAttachmentTooLarge,
+		},
+		{
+			name:              "invalid base64",
+			payload:           []byte(`{"id":"msg1","attachments":[{"content_base64":"not base64!","name":"a.txt"}]}`),
+			expectedErrorCode: ErrorCodeInvalidPayload,
+		},
+		{
+			name:                      "storage error",
+			payload:                   payloadWithContent,
+			attachmentError:           errors.New("mock error"),
+			expectedErrorCode:         ErrorCodeStorageError,
+			expectedStoredAttachments: []string{"hello"},
+		},
+		{
+			name:                      "stored content is removed when the insert fails",
+			payload:                   payloadWithContent,
+			insertError:               errors.New("mock error"),
+			expectedErrorCode:         ErrorCodeDatabaseError,
+			expectedStoredAttachments: []string{"hello", "world!"},
+			expectedDeletedPaths:      []string{"payload_file", "attachments/1-hello.txt", "attachments/2-world.txt", "attachments"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: 1, attachmentError: tc.attachmentError}
+			database := &databaseMock{errorAfterInsertCallCount: 1}
+			if tc.insertError != nil {
+				database.errorAfterInsertCallCount = 0
+				database.insertError = tc.insertError
+			}
+
+			sut := NewService(payloadStorage, database, tc.opts...)
+
+			results := sut.Save(context.TODO(), []EmailRequest{{MessageId: "msg1", PayloadBytes: tc.payload}})
+
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Equal(t, tc.expectedStoredAttachments, payloadStorage.storedAttachments)
+			assert.Equal(t, tc.expectedDeletedPaths, payloadStorage.deletedPaths)
+			if tc.expectedStoredPayload != "" {
+				assert.JSONEq(t, tc.expectedStoredPayload, string(payloadStorage.storedPayloads[0]))
+				assert.Equal(t, payloadHash(tc.payload), database.insertedParams[0].PayloadHash, "the hash is computed on the submitted payload")
+			}
+		})
+	}
+}
+
+type attachmentValidatorMock struct {
+	invalidPaths map[string]bool
+	checkedPaths []string
+}
+
+func (m *attachmentValidatorMock) Validate(path string) error {
+	m.checkedPaths = append(m.checkedPaths, path)
+	if m.invalidPaths[path] {
+		return fmt.Errorf("attachment %s does not exist", path)
+	}
+	return nil
+}
+
+func TestService_Save_AttachmentValidation(t *testing.T) {
+	t.Parallel()
+
+	payload := []byte(`{"id":"msg1","attachments":[` +
+		`{"path":"/efs/attachments/terms.pdf","name":"terms.pdf"},` +
+		`{"content_base64":"aGVsbG8=","name":"hello.txt"},` +
+		`{"path":"/efs/attachments/missing.pdf","name":"missing.pdf"}]}`)
+
+	testCases := []struct {
+		name                 string
+		invalidPaths         map[string]bool
+		expectedSuccess      bool
+		expectedErrorCode    string
+		expectedErrorMessage string
+		expectedStoreCount   int
+	}{
+		{
+			name:               "valid paths",
+			expectedSuccess:    true,
+			expectedStoreCount: 1,
+		},
+		{
+			name:                 "invalid path",
+			invalidPaths:         map[string]bool{"/efs/attachments/missing.pdf": true},
+			expectedErrorCode:    ErrorCodeAttachmentError,
+			expectedErrorMessage: "attachments[2]: attachment /efs/attachments/missing.pdf does not exist",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: 1}
+			database := &databaseMock{errorAfterInsertCallCount: 1}
+			validator := &attachmentValidatorMock{invalidPaths: tc.invalidPaths}
+
+			sut := NewService(payloadStorage, database, WithAttachmentValidator(validator))
+
+			results := sut.Save(context.TODO(), []EmailRequest{{MessageId: "msg1", PayloadBytes: payload}})
+
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Equal(t, tc.expectedErrorMessage, results[0].ErrorMessage)
+			assert.Equal(t, tc.expectedStoreCount, payloadStorage.callCount)
+			assert.Equal(t, []string{"/efs/attachments/terms.pdf", "/efs/attachments/missing.pdf"}, validator.checkedPaths, "uploaded content is not checked")
+		})
+	}
+}
+
+func TestService_Save_Raw(t *testing.T) {
+	t.Parallel()
+
+	message := []byte("From: news@acme.com\r\nTo: user@example.com\r\nSubject: Raw\r\n\r\nHello\r\n")
+
+	payloadStorage := &payloadStorageMock{errorAfterCallCount: 1}
+	database := &databaseMock{errorAfterInsertCallCount: 1}
+	validator := &attachmentValidatorMock{}
+	suppressionList := &suppressionListMock{suppressed: map[string]bool{"suppressed@example.com": true}}
+
+	sut := NewService(payloadStorage, database, WithAttachmentValidator(validator), WithSuppressionList(suppressionList))
+
+	results := sut.Save(context.TODO(), []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: message, Recipients: []string{"user@example.com", "hidden@example.com"}, Raw: true},
+		{MessageId: "msg2", PayloadBytes: message, Recipients: []string{"suppressed@example.com"}, Raw: true},
+	})
+
+	assert.True(t, results[0].Success)
+	assert.Equal(t, ErrorCodeRecipientSuppressed, results[1].ErrorCode)
+
+	assert.Equal(t, [][]byte{message}, payloadStorage.storedPayloads, "the message is stored as it is")
+	assert.Len(t, database.insertedParams, 1)
+	assert.Equal(t, "eml_file", database.insertedParams[0].EMLFilePath)
+	assert.Empty(t, database.insertedParams[0].PayloadFilePath)
+	assert.Equal(t, payloadHash(message), database.insertedParams[0].PayloadHash)
+	assert.Empty(t, validator.checkedPaths)
+
+	// the Bcc field is removed from the message, its recipients are kept in the envelope
+	assert.Equal(t, []string{`{"recipients":["user@example.com","hidden@example.com"]}`}, payloadStorage.storedEnvelopes)
+	assert.Equal(t, "envelope_file", database.insertedParams[0].EnvelopeFilePath)
+}
+
+type suppressionListMock struct {
+	suppressed map[string]bool
+	err        error
+}
+
+func (m *suppressionListMock) FindSuppressed(_ context.Context, addresses []string) ([]string, error) {
+	var found []string
+	for _, address := range addresses {
+		if m.suppressed[address] {
+			found = append(found, address)
+		}
+	}
+	return found, m.err
+}
+
+func TestService_Save_SuppressedRecipients(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name                 string
+		suppressed           map[string]bool
+		err                  error
+		expectedSuccess      bool
+		expectedErrorCode    string
+		expectedErrorMessage string
+		expectedStoreCount   int
+	}{
+		{
+			name:               "no suppressed recipient",
+			expectedSuccess:    true,
+			expectedStoreCount: 1,
+		},
+		{
+			name:                 "suppressed recipients",
+			suppressed:           map[string]bool{"cc@example.com": true, "bcc@example.com": true},
+			expectedErrorCode:    ErrorCodeRecipientSuppressed,
+			expectedErrorMessage: "Recipients are suppressed: cc@example.com, bcc@example.com",
+		},
+		{
+			name:                 "lookup error",
+			err:                  errors.New("mock error"),
+			expectedErrorCode:    ErrorCodeDatabaseError,
+			expectedErrorMessage: ErrorMessageDatabaseError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: 1}
+			database := &databaseMock{errorAfterInsertCallCount: 1}
+			suppressionList := &suppressionListMock{suppressed: tc.suppressed, err: tc.err}
+
+			sut := NewService(payloadStorage, database, WithSuppressionList(suppressionList))
+
+			results := sut.Save(context.TODO(), []EmailRequest{{
+				MessageId:    "msg1",
+				PayloadBytes: []byte("payload"),
+				Recipients:   []string{"to@example.com", "cc@example.com", "bcc@example.com"},
+			}})
+
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Equal(t, tc.expectedErrorMessage, results[0].ErrorMessage)
+			assert.Equal(t, tc.expectedStoreCount, payloadStorage.callCount)
+		})
+	}
+}
+
+// syncPayloadStorageMock makes a payloadStorageMock safe for concurrent saves
+type syncPayloadStorageMock struct {
+	*payloadStorageMock
+	mu sync.Mutex
+}
+
+func (m *syncPayloadStorageMock) Store(tenant string, messageId string, payload []byte) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.payloadStorageMock.Store(tenant, messageId, payload)
+}
+
+func (m *syncPayloadStorageMock) Delete(payloadPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.payloadStorageMock.Delete(payloadPath)
+}
+
+// syncDatabaseMock makes a databaseMock safe for concurrent saves. Inserted emails are found by
+// GetPayloadHash, and inserts take a while, so that concurrent ones overlap.
+type syncDatabaseMock struct {
+	*databaseMock
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func newSyncDatabaseMock() *syncDatabaseMock {
+	return &syncDatabaseMock{databaseMock: &databaseMock{errorAfterInsertCallCount: math.MaxInt, existingHashes: map[string]string{}}}
+}
+
+func (m *syncDatabaseMock) Insert(ctx context.Context, params InsertParams) error {
+	m.mu.Lock()
+	m.inFlight++
+	m.maxInFlight = max(m.maxInFlight, m.inFlight)
+	m.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+
+	if err := m.databaseMock.Insert(ctx, params); err != nil {
+		return err
+	}
+	m.existingHashes[params.Id] = params.PayloadHash
+	return nil
+}
+
+func (m *syncDatabaseMock) GetPayloadHash(ctx context.Context, id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.databaseMock.GetPayloadHash(ctx, id)
+}
+
+func TestService_Save_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	emailRequests := make([]EmailRequest, 20)
+	for i := range emailRequests {
+		emailRequests[i] = EmailRequest{MessageId: fmt.Sprintf("msg%d", i), PayloadBytes: []byte(fmt.Sprintf("test payload %d", i))}
+	}
+
+	payloadStorage := &syncPayloadStorageMock{payloadStorageMock: &payloadStorageMock{errorAfterCallCount: math.MaxInt}}
+	database := newSyncDatabaseMock()
+
+	sut := NewService(payloadStorage, database, WithSaveConcurrency(4))
+
+	results := sut.Save(context.TODO(), emailRequests)
+
+	assert.Len(t, results, len(emailRequests))
+	for i, result := range results {
+		assert.Equal(t, SaveResult{MessageId: emailRequests[i].MessageId, Success: true}, result, "results are in the order of the emails")
+	}
+	assert.Len(t, database.insertedParams, len(emailRequests))
+	assert.LessOrEqual(t, database.maxInFlight, 4)
+	assert.Greater(t, database.maxInFlight, 1)
+}
+
+func TestService_Save_SameIdInBatch(t *testing.T) {
+	t.Parallel()
+
+	payloadStorage := &syncPayloadStorageMock{payloadStorageMock: &payloadStorageMock{errorAfterCallCount: math.MaxInt}}
+	database := newSyncDatabaseMock()
+
+	sut := NewService(payloadStorage, database)
+
+	results := sut.Save(context.TODO(), []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2")},
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg1", PayloadBytes: []byte("another payload")},
+	})
+
+	assert.Equal(t, []SaveResult{
+		{MessageId: "msg1", Success: true},
+		{MessageId: "msg2", Success: true},
+		{MessageId: "msg1", Success: true, AlreadyAccepted: true},
+		{MessageId: "msg1", ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessagePayloadChanged},
+	}, results)
+	assert.Equal(t, 2, payloadStorage.callCount, "resubmissions are not stored")
+	assert.Len(t, database.insertedParams, 2)
+}
+
+func TestService_Save_Canceled(t *testing.T) {
+	t.Parallel()
+
+	payloadStorage := &syncPayloadStorageMock{payloadStorageMock: &payloadStorageMock{errorAfterCallCount: math.MaxInt}}
+	database := newSyncDatabaseMock()
+
+	sut := NewService(payloadStorage, database)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	results := sut.Save(ctx, []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2")},
+	})
+
+	assert.Equal(t, []SaveResult{
+		{MessageId: "msg1", ErrorCode: ErrorCodeRequestCanceled, ErrorMessage: ErrorMessageRequestCanceled},
+		{MessageId: "msg2", ErrorCode: ErrorCodeRequestCanceled, ErrorMessage: ErrorMessageRequestCanceled},
+	}, results)
+	assert.Zero(t, payloadStorage.callCount)
+	assert.Empty(t, database.insertedParams)
+}
+
+func TestService_Validate_SameIdInBatch(t *testing.T) {
+	t.Parallel()
+
+	payloadStorage := &syncPayloadStorageMock{payloadStorageMock: &payloadStorageMock{errorAfterCallCount: math.MaxInt}}
+	database := newSyncDatabaseMock()
+
+	sut := NewService(payloadStorage, database, WithSuppressionList(&suppressionListMock{suppressed: map[string]bool{"suppressed@example.com": true}}))
+
+	results := sut.Validate(context.TODO(), []EmailRequest{
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 2"), Recipients: []string{"suppressed@example.com"}},
+		{MessageId: "msg1", PayloadBytes: []byte("test payload 1")},
+		{MessageId: "msg1", PayloadBytes: []byte("another payload")},
+		{MessageId: "msg2", PayloadBytes: []byte("test payload 3")},
+	})
+
+	assert.Equal(t, []SaveResult{
+		{MessageId: "msg1", Success: true},
+		{MessageId: "msg2", ErrorCode: ErrorCodeRecipientSuppressed, ErrorMessage: "Recipients are suppressed: suppressed@example.com"},
+		{MessageId: "msg1", Success: true, AlreadyAccepted: true},
+		{MessageId: "msg1", ErrorCode: ErrorCodeDuplicatedID, ErrorMessage: ErrorMessagePayloadChanged},
+		{MessageId: "msg2", Success: true},
+	}, results)
+	assert.Zero(t, payloadStorage.callCount)
+	assert.Empty(t, database.insertedParams)
+}
+
+func TestService_Validate(t *testing.T) {
+	t.Parallel()
+
+	payloadWithContent := []byte(`{"id":"msg1","attachments":[` +
+		`{"path":"/efs/attachments/terms.pdf","name":"terms.pdf"},` +
+		`{"content_base64":"aGVsbG8=","name":"hello.txt"}]}`)
+
+	testCases := []struct {
+		name                    string
+		payload                 []byte
+		opts                    []ServiceOption
+		existingHashes          map[string]string
+		expectedSuccess         bool
+		expectedAlreadyAccepted bool
+		expectedErrorCode       string
+	}{
+		{
+			name:            "valid email",
+			payload:         payloadWithContent,
+			expectedSuccess: true,
+		},
+		{
+			name:              "attachment over the size limit",
+			payload:           payloadWithContent,
+			opts:              []ServiceOption{WithAttachmentSizeLimits(4, 0)},
+			expectedErrorCode: ErrorCodeAttachmentTooLarge,
+		},
+		{
+			name:              "invalid base64",
+			payload:           []byte(`{"id":"msg1","attachments":[{"content_base64":"not base64!","name":"a.txt"}]}`),
+			expectedErrorCode: ErrorCodeInvalidPayload,
+		},
+		{
+			name:              "invalid attachment path",
+			payload:           payloadWithContent,
+			opts:              []ServiceOption{WithAttachmentValidator(&attachmentValidatorMock{invalidPaths: map[string]bool{"/efs/attachments/terms.pdf": true}})},
+			expectedErrorCode: ErrorCodeAttachmentError,
+		},
+		{
+			name:              "suppressed recipient",
+			payload:           payloadWithContent,
+			opts:              []ServiceOption{WithSuppressionList(&suppressionListMock{suppressed: map[string]bool{"to@example.com": true}})},
+			expectedErrorCode: ErrorCodeRecipientSuppressed,
+		},
+		{
+			name:                    "already accepted",
+			payload:                 payloadWithContent,
+			existingHashes:          map[string]string{"msg1": payloadHash(payloadWithContent)},
+			expectedSuccess:         true,
+			expectedAlreadyAccepted: true,
+		},
+		{
+			name:              "ID of another payload",
+			payload:           payloadWithContent,
+			existingHashes:    map[string]string{"msg1": "other"},
+			expectedErrorCode: ErrorCodeDuplicatedID,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{errorAfterCallCount: 1}
+			database := &databaseMock{errorAfterInsertCallCount: 1, existingHashes: tc.existingHashes}
+
+			sut := NewService(payloadStorage, database, tc.opts...)
+
+			results := sut.Validate(context.TODO(), []EmailRequest{{
+				MessageId:    "msg1",
+				PayloadBytes: tc.payload,
+				Recipients:   []string{"to@example.com"},
+			}})
+
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedAlreadyAccepted, results[0].AlreadyAccepted)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Zero(t, payloadStorage.callCount, "no payload is stored")
+			assert.Empty(t, payloadStorage.storedAttachments, "no attachment is stored")
+			assert.Zero(t, database.insertCallCount, "no email is inserted")
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package email
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"multicarrier-email-api/internal/dkim"
+	"multicarrier-email-api/internal/eml"
+	"multicarrier-email-api/internal/mailbox"
+)
+
+// envelope holds the SMTP recipients of a message stored at intake. It is stored next to the
+// message, since the Bcc recipients are not in its header.
+type envelope struct {
+	Recipients []string `json:"recipients"`
+}
+
+// renderPayload renders a JSON payload as an RFC 5322 message, with the attachments of the message
+// built by buildMessage
+func (s *Service) renderPayload(payload []byte, buildMessage func(e emailDataInput) (eml.Message, error)) ([]byte, error) {
+	var e emailDataInput
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	message, err := buildMessage(e)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.emlRenderer.Render(message)
+}
+
+// signsPayload tells whether the message of a JSON payload is signed at intake, that is whether
+// the domain of its sender has a DKIM key
+func (s *Service) signsPayload(payload []byte) bool {
+	if s.dkimSigner == nil {
+		return false
+	}
+
+	var e struct {
+		From Address `json:"from"`
+	}
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return false
+	}
+
+	address := mailbox.ToASCII(e.From.Address)
+	at := strings.LastIndex(address, "@")
+	return at >= 0 && s.dkimSigner.HasKey(address[at+1:])
+}
+
+// checkSignedAttachments checks that the attachments of a payload signed at intake can be read,
+// as the signed message is delivered with their content
+func (s *Service) checkSignedAttachments(payload []byte) error {
+	if !s.signsPayload(payload) {
+		return nil
+	}
+
+	_, attachments, ok := payloadAttachments(payload)
+	if !ok {
+		return nil
+	}
+
+	for i, attachment := range attachments {
+		var path string
+		if err := json.Unmarshal(attachment["path"], &path); err != nil || path == "" {
+			continue
+		}
+		if _, _, err := s.readDeliveryAttachment(path); err != nil {
+			return fmt.Errorf("attachments[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// signMessage signs a message with the DKIM key of the domain of its sender. It tells whether the
+// message was signed: without a signer, or a key for the domain, the message is returned as it is.
+func (s *Service) signMessage(message []byte) ([]byte, bool, error) {
+	if s.dkimSigner == nil {
+		return message, false, nil
+	}
+
+	signed, err := s.dkimSigner.Sign(message)
+	if errors.Is(err, dkim.ErrNoKey) {
+		return message, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to sign message: %w", err)
+	}
+	return signed, true, nil
+}
+
+// signedMessage returns the message delivered for an email, signed with the DKIM key of the domain
+// of its sender. Raw messages are signed as they are, JSON payloads are rendered first with the
+// content of their attachments, failing with errAttachmentNotReadable otherwise. It returns
+// nil when the email is not signed, and its message is then left as it is: raw messages are stored
+// unsigned, and the message of JSON payloads is rendered at delivery.
+func (s *Service) signedMessage(req EmailRequest, payload []byte) ([]byte, error) {
+	if s.dkimSigner == nil {
+		return nil, nil
+	}
+
+	message := payload
+	if !req.Raw {
+		if !s.signsPayload(payload) {
+			return nil, nil
+		}
+
+		var err error
+		message, err = s.renderPayload(payload, s.deliveryMessage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render message: %w", err)
+		}
+	}
+
+	signed, ok, err := s.signMessage(message)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,155 @@
+package email
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"multicarrier-email-api/internal/dkim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_Save_DKIM(t *testing.T) {
+	t.Parallel()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := dkim.NewSigner([]dkim.Key{{Domain: "acme.com", Selector: "mail", Signer: key}})
+	require.NoError(t, err)
+
+	payload := func(from string) []byte {
+		return []byte(`{"id": "msg1", "from": "` + from + `", "reply_to": "` + from + `", "to": "user@example.com", "subject": "Signed", "body_text": "Hello"}`)
+	}
+	withAttachment := func(from string, path string) []byte {
+		return []byte(`{"id": "msg1", "from": "` + from + `", "reply_to": "` + from + `", "to": "user@example.com", "subject": "Signed", "body_text": "Hello", "attachments": [{"name": "invoice.pdf", "path": "` + path + `"}]}`)
+	}
+	rawMessage := []byte("From: news@acme.com\r\nTo: user@example.com\r\nSubject: Raw\r\n\r\nHello\r\n")
+
+	type caseStruct struct {
+		name                     string
+		request                  EmailRequest
+		payloadStorageErrorAfter int
+		expectedSuccess          bool
+		expectedErrorCode        string
+		expectedStoredCount      int
+		expectedSignedIndex      int
+		expectedPayloadFilePath  string
+		expectedEMLFilePath      string
+		expectedEnvelopes        []string
+		expectedDeletedPaths     []string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:                     "payload of a domain with a key",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: payload("news@acme.com"), Recipients: []string{"user@example.com", "hidden@example.com"}},
+			payloadStorageErrorAfter: 2,
+			expectedSuccess:          true,
+			expectedStoredCount:      2,
+			expectedSignedIndex:      1,
+			expectedPayloadFilePath:  "payload_file",
+			expectedEMLFilePath:      "eml_file",
+			expectedEnvelopes:        []string{`{"recipients":["user@example.com","hidden@example.com"]}`},
+		},
+		{
+			name:                     "payload with a stored attachment",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: withAttachment("news@acme.com", "/storage/invoice.pdf")},
+			payloadStorageErrorAfter: 2,
+			expectedSuccess:          true,
+			expectedStoredCount:      2,
+			expectedSignedIndex:      1,
+			expectedPayloadFilePath:  "payload_file",
+			expectedEMLFilePath:      "eml_file",
+			expectedEnvelopes:        []string{`{"recipients":null}`},
+		},
+		{
+			name:                     "payload with a remote attachment",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: withAttachment("news@acme.com", "https://files.example.com/invoice.pdf")},
+			payloadStorageErrorAfter: 2,
+			expectedErrorCode:        ErrorCodeAttachmentError,
+			expectedSignedIndex:      -1,
+		},
+		{
+			name:                     "payload with a remote attachment of a domain without key",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: withAttachment("news@other.com", "https://files.example.com/invoice.pdf")},
+			payloadStorageErrorAfter: 2,
+			expectedSuccess:          true,
+			expectedStoredCount:      1,
+			expectedSignedIndex:      -1,
+			expectedPayloadFilePath:  "payload_file",
+		},
+		{
+			name:                     "payload of a domain without key",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: payload("news@other.com")},
+			payloadStorageErrorAfter: 2,
+			expectedSuccess:          true,
+			expectedStoredCount:      1,
+			expectedSignedIndex:      -1,
+			expectedPayloadFilePath:  "payload_file",
+		},
+		{
+			name:                     "raw message",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: rawMessage, Recipients: []string{"user@example.com"}, Raw: true},
+			payloadStorageErrorAfter: 2,
+			expectedSuccess:          true,
+			expectedStoredCount:      1,
+			expectedSignedIndex:      0,
+			expectedEMLFilePath:      "eml_file",
+			expectedEnvelopes:        []string{`{"recipients":["user@example.com"]}`},
+		},
+		{
+			name:                     "message storage failure",
+			request:                  EmailRequest{MessageId: "msg1", PayloadBytes: payload("news@acme.com")},
+			payloadStorageErrorAfter: 1,
+			expectedErrorCode:        ErrorCodeStorageError,
+			expectedStoredCount:      2,
+			expectedSignedIndex:      -1,
+			expectedDeletedPaths:     []string{"payload_file"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			payloadStorage := &payloadStorageMock{
+				errorAfterCallCount: tc.payloadStorageErrorAfter,
+				files:               map[string][]byte{"/storage/invoice.pdf": []byte("%PDF-1.7")},
+			}
+			database := &databaseMock{errorAfterInsertCallCount: 1}
+
+			sut := NewService(payloadStorage, database, WithDKIMSigner(signer))
+
+			results := sut.Save(context.TODO(), []EmailRequest{tc.request})
+
+			assert.Equal(t, tc.expectedSuccess, results[0].Success)
+			assert.Equal(t, tc.expectedErrorCode, results[0].ErrorCode)
+			assert.Len(t, payloadStorage.storedPayloads, tc.expectedStoredCount)
+			assert.Equal(t, tc.expectedEnvelopes, payloadStorage.storedEnvelopes)
+			assert.Equal(t, tc.expectedDeletedPaths, payloadStorage.deletedPaths)
+
+			if tc.expectedSignedIndex >= 0 {
+				signed := payloadStorage.storedPayloads[tc.expectedSignedIndex]
+				assert.True(t, strings.HasPrefix(string(signed), "DKIM-Signature: v=1;"))
+				assert.NoError(t, dkim.Verify(signed, signer.PublicKey))
+				assert.NotContains(t, string(signed), "message/external-body", "attachments are delivered with their content")
+			}
+
+			if !tc.expectedSuccess {
+				assert.Empty(t, database.insertedParams)
+				return
+			}
+			require.Len(t, database.insertedParams, 1)
+			assert.Equal(t, tc.expectedPayloadFilePath, database.insertedParams[0].PayloadFilePath)
+			assert.Equal(t, tc.expectedEMLFilePath, database.insertedParams[0].EMLFilePath)
+			if tc.expectedEnvelopes != nil {
+				assert.Equal(t, "envelope_file", database.insertedParams[0].EnvelopeFilePath)
+			}
+			assert.Equal(t, payloadHash(tc.request.PayloadBytes), database.insertedParams[0].PayloadHash)
+		})
+	}
+}
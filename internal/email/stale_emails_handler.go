@@ -25,7 +25,7 @@ func NewGetStaleEmailsHandler(emailService staleEmailsServiceInterface) *GetStal
 }
 
 func (h *GetStaleEmailsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	staleEmails, err := h.emailService.GetStaleEmails(context.TODO())
+	staleEmails, err := h.emailService.GetStaleEmails(r.Context())
 	if err != nil {
 		slog.Error(fmt.Sprintf("error getting stale emails: %v", err))
 		response.WriteError(http.StatusInternalServerError, w, "error getting stale emails")
@@ -51,18 +51,20 @@ func TestGetStaleEmailsHandler_ServeHTTP(t *testing.T) {
 				{
 					Id:        "test-id-1",
 					Status:    "INTAKING",
+					Priority:  "normal",
 					CreatedAt: fixedTime,
 					UpdatedAt: fixedTime.Add(-1 * time.Hour),
 				},
 				{
 					Id:        "test-id-2",
 					Status:    "PROCESSING",
+					Priority:  "normal",
 					CreatedAt: fixedTime,
 					UpdatedAt: fixedTime.Add(-2 * time.Hour),
 				},
 			},
 			expectedStatusCode: http.StatusOK,
-			expectedBody:       `[{"id":"test-id-1","status":"INTAKING","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T11:00:00Z"},{"id":"test-id-2","status":"PROCESSING","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T10:00:00Z"}]`,
+			expectedBody:       `[{"id":"test-id-1","status":"INTAKING","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T11:00:00Z"},{"id":"test-id-2","status":"PROCESSING","priority":"normal","created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T10:00:00Z"}]`,
 		},
 		{
 			name:               "success with no emails",
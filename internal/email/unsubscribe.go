@@ -0,0 +1,89 @@
+package email
+
+import (
+	"context"
+	"strings"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/mailbox"
+	"multicarrier-email-api/internal/unsubscribe"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	headerListUnsubscribe     = "List-Unsubscribe"
+	headerListUnsubscribePost = "List-Unsubscribe-Post"
+	// listUnsubscribePostValue marks the https link of List-Unsubscribe as one-click (RFC 8058)
+	listUnsubscribePostValue = "List-Unsubscribe=One-Click"
+)
+
+// Unsubscribe is turned into the List-Unsubscribe headers of the email (RFC 2369 and RFC 8058)
+type Unsubscribe struct {
+	// Mailto is the address receiving unsubscribe requests by email
+	Mailto string `json:"mailto,omitempty" validate:"omitempty,email"`
+	// URL is the unsubscribe link of the producer. One-click emails without URL get a link served
+	// by POST /unsubscribe/{token}.
+	URL      string `json:"url,omitempty" validate:"omitempty,http_url,startswith=https://"`
+	OneClick bool   `json:"one_click,omitempty"`
+}
+
+type unsubscribeLinkerInterface interface {
+	OneClickURL(claims unsubscribe.Claims) (string, error)
+}
+
+// validateUnsubscribe returns the struct validation of Unsubscribe, which accepts one-click emails
+// without URL only when the server serves unsubscribe links
+func validateUnsubscribe(oneClickLinks bool) validator.StructLevelFunc {
+	return func(sl validator.StructLevel) {
+		u := sl.Current().Interface().(Unsubscribe)
+
+		switch {
+		case u.Mailto == "" && u.URL == "" && !u.OneClick:
+			sl.ReportError(u.URL, "URL", "url", "required_without", "Mailto")
+		case u.OneClick && u.URL == "" && !oneClickLinks:
+			sl.ReportError(u.URL, "URL", "url", "required_with", "OneClick")
+		}
+	}
+}
+
+// hasListUnsubscribeHeader tells whether custom headers already set the headers generated from Unsubscribe
+func hasListUnsubscribeHeader(customHeaders map[string]string) bool {
+	for name := range customHeaders {
+		if strings.EqualFold(name, headerListUnsubscribe) || strings.EqualFold(name, headerListUnsubscribePost) {
+			return true
+		}
+	}
+	return false
+}
+
+// unsubscribeHeaders returns the List-Unsubscribe headers of an email, with the https link first
+// as mailbox providers prefer it. Served one-click links are only issued to emails with a single
+// recipient, see validateEmailDataInput, so that a link unsubscribes nobody but its recipient.
+func (h *CreateEmailHandler) unsubscribeHeaders(ctx context.Context, e emailDataInput) (map[string]string, error) {
+	u := e.Unsubscribe
+
+	link := u.URL
+	if link == "" && u.OneClick {
+		var err error
+		link, err = h.unsubscribeLinker.OneClickURL(unsubscribe.Claims{Tenant: auth.TenantOf(ctx), EmailId: e.Id, Recipients: e.recipientAddresses()})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var targets []string
+	if link != "" {
+		targets = append(targets, "<"+link+">")
+	}
+	if u.Mailto != "" {
+		targets = append(targets, "<mailto:"+mailbox.ToASCII(u.Mailto)+">")
+	}
+
+	headers := map[string]string{headerListUnsubscribe: strings.Join(targets, ", ")}
+	if u.OneClick {
+		headers[headerListUnsubscribePost] = listUnsubscribePostValue
+	}
+
+	return headers, nil
+}
@@ -0,0 +1,35 @@
+package eml
+
+import (
+	"time"
+)
+
+// Message is an email to be rendered as an RFC 5322 message. Addresses are already formatted,
+// either as bare addresses or as name-addr with encoded display names, and may have UTF-8 local
+// parts (RFC 6532). Bcc recipients are not part of the message.
+type Message struct {
+	// Id is used for the Message-ID header, and by deterministic boundaries
+	Id       string
+	From     string
+	ReplyTo  string
+	To       []string
+	Cc       []string
+	Subject  string
+	Date     time.Time
+	TextBody string
+	HTMLBody string
+	// Headers are the custom headers of the producer
+	Headers     map[string]string
+	Attachments []Attachment
+}
+
+// Attachment is a file of a message. Attachments without content are rendered as a reference to
+// their URL (RFC 2017), such as remote files fetched by the sender.
+type Attachment struct {
+	Name    string
+	Content []byte
+	URL     string
+	// Inline attachments are referenced from the HTML body as cid:<content_id>
+	Inline    bool
+	ContentId string
+}
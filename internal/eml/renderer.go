@@ -0,0 +1,378 @@
+package eml
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const crlf = "\r\n"
+
+// maxLineLength is the line length recommended by RFC 5322, address lists are folded past it
+const maxLineLength = 78
+
+// base64LineLength is the maximum length of base64 lines (RFC 2045)
+const base64LineLength = 76
+
+// BoundaryFunc returns the boundary of the n-th multipart entity of a message, counting from 0
+type BoundaryFunc func(messageId string, n int) string
+
+// RandomBoundary returns random boundaries, as mail clients do
+func RandomBoundary(_ string, _ int) string {
+	var buf [24]byte
+	_, _ = rand.Read(buf[:])
+	return "=_" + hex.EncodeToString(buf[:])
+}
+
+// DeterministicBoundary derives the boundaries from the message ID, so that a message is always
+// rendered to the same bytes. The =_ prefix cannot appear in quoted-printable or base64 content.
+func DeterministicBoundary(messageId string, n int) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s/%d", messageId, n))
+	return "=_" + hex.EncodeToString(sum[:16])
+}
+
+// Renderer turns messages into RFC 5322 messages. Text and HTML bodies are quoted-printable in a
+// multipart/alternative, inline attachments are related to the HTML body, and the other attachments
+// are base64 parts of a multipart/mixed.
+type Renderer struct {
+	boundary BoundaryFunc
+}
+
+type RendererOption func(*Renderer)
+
+// WithBoundary sets the boundaries of multipart entities, random by default
+func WithBoundary(boundary BoundaryFunc) RendererOption {
+	return func(r *Renderer) {
+		r.boundary = boundary
+	}
+}
+
+func NewRenderer(opts ...RendererOption) *Renderer {
+	r := &Renderer{
+		boundary: RandomBoundary,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// entity is a MIME entity, either a leaf with an encoded body or a multipart with parts
+type entity struct {
+	header   textproto.MIMEHeader
+	body     []byte
+	subtype  string
+	parts    []*entity
+	boundary string
+}
+
+func newMultipart(subtype string, parts ...*entity) *entity {
+	return &entity{header: textproto.MIMEHeader{}, subtype: subtype, parts: parts}
+}
+
+func newTextEntity(mediaType string, text string) (*entity, error) {
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := io.WriteString(w, text); err != nil {
+		return nil, fmt.Errorf("failed to encode %s body: %w", mediaType, err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode %s body: %w", mediaType, err)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(mediaType, map[string]string{"charset": "utf-8"}))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	return &entity{header: header, body: buf.Bytes()}, nil
+}
+
+// encodeBase64 returns the base64 encoding of content, in lines of base64LineLength
+func encodeBase64(content []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(content)
+
+	var buf bytes.Buffer
+	for len(encoded) > base64LineLength {
+		buf.WriteString(encoded[:base64LineLength])
+		buf.WriteString(crlf)
+		encoded = encoded[base64LineLength:]
+	}
+	buf.WriteString(encoded)
+
+	return buf.Bytes()
+}
+
+func newAttachmentEntity(a Attachment) (*entity, error) {
+	contentType := mime.TypeByExtension(filepath.Ext(a.Name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type of attachment %s: %w", a.Name, err)
+	}
+	params["name"] = a.Name
+	contentType = mime.FormatMediaType(mediaType, params)
+
+	disposition := "attachment"
+	if a.Inline {
+		disposition = "inline"
+	}
+	contentDisposition := mime.FormatMediaType(disposition, map[string]string{"filename": a.Name})
+
+	if contentType == "" || contentDisposition == "" {
+		return nil, fmt.Errorf("invalid attachment name %q", a.Name)
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", contentDisposition)
+	if a.Inline && a.ContentId != "" {
+		header["Content-ID"] = []string{"<" + a.ContentId + ">"}
+	}
+
+	if a.Content == nil && a.URL != "" {
+		// the body of a message/external-body holds the header of the referenced content
+		externalBody := mime.FormatMediaType("message/external-body", map[string]string{"access-type": "URL", "url": a.URL})
+		if externalBody == "" {
+			return nil, fmt.Errorf("invalid URL of attachment %s", a.Name)
+		}
+		header.Set("Content-Type", externalBody)
+		return &entity{header: header, body: []byte("Content-Type: " + contentType + crlf + crlf)}, nil
+	}
+
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+
+	return &entity{header: header, body: encodeBase64(a.Content)}, nil
+}
+
+// newMessageEntity returns the entity tree of a message body
+func newMessageEntity(m Message) (*entity, error) {
+	var inline, attached []Attachment
+	for _, a := range m.Attachments {
+		if a.Inline && m.HTMLBody != "" {
+			inline = append(inline, a)
+		} else {
+			attached = append(attached, a)
+		}
+	}
+
+	var alternatives []*entity
+
+	if m.TextBody != "" || m.HTMLBody == "" {
+		text, err := newTextEntity("text/plain", m.TextBody)
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, text)
+	}
+
+	if m.HTMLBody != "" {
+		html, err := newTextEntity("text/html", m.HTMLBody)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(inline) > 0 {
+			related := newMultipart("related", html)
+			for _, a := range inline {
+				part, err := newAttachmentEntity(a)
+				if err != nil {
+					return nil, err
+				}
+				related.parts = append(related.parts, part)
+			}
+			html = related
+		}
+
+		alternatives = append(alternatives, html)
+	}
+
+	body := alternatives[0]
+	if len(alternatives) > 1 {
+		body = newMultipart("alternative", alternatives...)
+	}
+
+	if len(attached) == 0 {
+		return body, nil
+	}
+
+	mixed := newMultipart("mixed", body)
+	for _, a := range attached {
+		part, err := newAttachmentEntity(a)
+		if err != nil {
+			return nil, err
+		}
+		mixed.parts = append(mixed.parts, part)
+	}
+
+	return mixed, nil
+}
+
+// assignBoundaries sets the boundaries of the multipart entities of a tree, depth first
+func (r *Renderer) assignBoundaries(messageId string, e *entity, n *int) {
+	if e.subtype == "" {
+		return
+	}
+
+	e.boundary = r.boundary(messageId, *n)
+	*n++
+	e.header.Set("Content-Type", mime.FormatMediaType("multipart/"+e.subtype, map[string]string{"boundary": e.boundary}))
+
+	for _, part := range e.parts {
+		r.assignBoundaries(messageId, part, n)
+	}
+}
+
+func writeBody(w io.Writer, e *entity) error {
+	if e.subtype == "" {
+		_, err := w.Write(e.body)
+		return err
+	}
+
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(e.boundary); err != nil {
+		return fmt.Errorf("invalid boundary %q: %w", e.boundary, err)
+	}
+
+	for _, part := range e.parts {
+		pw, err := mw.CreatePart(part.header)
+		if err != nil {
+			return err
+		}
+		if err := writeBody(pw, part); err != nil {
+			return err
+		}
+	}
+
+	return mw.Close()
+}
+
+// sanitizeHeaderValue replaces line breaks, which would start a new header
+func sanitizeHeaderValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+func writeField(buf *bytes.Buffer, name string, value string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(sanitizeHeaderValue(value))
+	buf.WriteString(crlf)
+}
+
+// writeTextField writes an unstructured header, such as the subject. Non-ASCII values are written as
+// RFC 2047 encoded-words, folded between words to keep lines short.
+func writeTextField(buf *bytes.Buffer, name string, value string) {
+	encoded := mime.QEncoding.Encode("utf-8", sanitizeHeaderValue(value))
+
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	buf.WriteString(strings.ReplaceAll(encoded, "?= =?", "?="+crlf+" =?"))
+	buf.WriteString(crlf)
+}
+
+// writeAddressField writes a list of addresses, folded between addresses past maxLineLength
+func writeAddressField(buf *bytes.Buffer, name string, addresses []string) {
+	buf.WriteString(name)
+	buf.WriteString(": ")
+	lineLength := len(name) + 2
+
+	for i, address := range addresses {
+		address = sanitizeHeaderValue(address)
+		if i > 0 {
+			if lineLength+2+len(address) > maxLineLength {
+				buf.WriteString("," + crlf + " ")
+				lineLength = 1
+			} else {
+				buf.WriteString(", ")
+				lineLength += 2
+			}
+		}
+		buf.WriteString(address)
+		lineLength += len(address)
+	}
+
+	buf.WriteString(crlf)
+}
+
+// messageIdHeader returns the Message-ID of a message, in the domain of the sender
+func messageIdHeader(id string, from string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = strings.TrimSuffix(from[at+1:], ">")
+	}
+	return "<" + id + "@" + domain + ">"
+}
+
+func writeMessageHeader(buf *bytes.Buffer, m Message) {
+	writeField(buf, "From", m.From)
+	if m.ReplyTo != "" {
+		writeField(buf, "Reply-To", m.ReplyTo)
+	}
+	writeAddressField(buf, "To", m.To)
+	if len(m.Cc) > 0 {
+		writeAddressField(buf, "Cc", m.Cc)
+	}
+	writeTextField(buf, "Subject", m.Subject)
+	if !m.Date.IsZero() {
+		writeField(buf, "Date", m.Date.Format(time.RFC1123Z))
+	}
+	if m.Id != "" {
+		writeField(buf, "Message-ID", messageIdHeader(m.Id, m.From))
+	}
+	writeField(buf, "MIME-Version", "1.0")
+
+	names := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		writeTextField(buf, name, m.Headers[name])
+	}
+}
+
+// Render returns the message in RFC 5322 format, with CRLF line endings
+func (r *Renderer) Render(m Message) ([]byte, error) {
+	root, err := newMessageEntity(m)
+	if err != nil {
+		return nil, err
+	}
+
+	n := 0
+	r.assignBoundaries(m.Id, root, &n)
+
+	var buf bytes.Buffer
+	writeMessageHeader(&buf, m)
+
+	names := make([]string, 0, len(root.header))
+	for name := range root.header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		writeField(&buf, name, root.header[name][0])
+	}
+	buf.WriteString(crlf)
+
+	if err := writeBody(&buf, root); err != nil {
+		return nil, fmt.Errorf("failed to write message body: %w", err)
+	}
+	if root.subtype == "" {
+		buf.WriteString(crlf)
+	}
+
+	return buf.Bytes(), nil
+}
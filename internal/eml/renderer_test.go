@@ -0,0 +1,219 @@
+package eml
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const messageId = "ff0fb587-e29b-4278-bbab-a525196b8917"
+
+func newTestMessage() Message {
+	return Message{
+		Id:       messageId,
+		From:     `"Acme News" <news@acme.com>`,
+		ReplyTo:  "support@acme.com",
+		To:       []string{"first@example.com", `"Jane Doe" <jane@example.com>`},
+		Cc:       []string{"copy@example.com"},
+		Subject:  "Spring offers",
+		Date:     time.Unix(0, 0).UTC(),
+		TextBody: "Hello, World!\nPrices start at 5€, see the attached terms.",
+		HTMLBody: `<p>Hello, World!</p><img src="cid:logo">`,
+		Headers:  map[string]string{"X-Campaign": "spring", "List-Id": "<news.acme.com>"},
+	}
+}
+
+func TestRenderer_Render(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name         string
+		message      func() Message
+		expectedFile string
+	}
+
+	cases := []caseStruct{
+		{
+			name: "text only",
+			message: func() Message {
+				m := newTestMessage()
+				m.HTMLBody = ""
+				m.Cc = nil
+				m.Headers = nil
+				return m
+			},
+			expectedFile: "testdata/text.eml",
+		},
+		{
+			name: "text and html",
+			message: func() Message {
+				m := newTestMessage()
+				m.HTMLBody = "<p>Hello, World!</p>"
+				return m
+			},
+			expectedFile: "testdata/alternative.eml",
+		},
+		{
+			name: "attachments",
+			message: func() Message {
+				m := newTestMessage()
+				m.Attachments = []Attachment{
+					{Name: "logo.png", Content: []byte("logo"), Inline: true, ContentId: "logo"},
+					{Name: "terms.pdf", Content: bytes.Repeat([]byte("terms and conditions "), 5)},
+					{Name: "catalog.pdf", URL: "https://files.acme.com/catalog.pdf"},
+				}
+				return m
+			},
+			expectedFile: "testdata/attachments.eml",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+
+			expected, err := os.ReadFile(c.expectedFile)
+			require.NoError(t, err)
+
+			sut := NewRenderer(WithBoundary(DeterministicBoundary))
+
+			actual, err := sut.Render(c.message())
+
+			assert.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+// readParts returns the decoded leaf parts of a multipart body, by content type
+func readParts(t *testing.T, contentType string, body io.Reader, parts map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	require.NoError(t, err)
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		require.NoError(t, err)
+		parts[mediaType] = string(content)
+		return
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		readParts(t, part.Header.Get("Content-Type"), part, parts)
+	}
+}
+
+func TestRenderer_Render_Parses(t *testing.T) {
+	t.Parallel()
+
+	m := newTestMessage()
+	m.Attachments = []Attachment{
+		{Name: "logo.png", Content: []byte("logo"), Inline: true, ContentId: "logo"},
+		{Name: "terms.pdf", Content: bytes.Repeat([]byte{0, 1, 2, 255}, 100)},
+	}
+
+	sut := NewRenderer()
+
+	rendered, err := sut.Render(m)
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(rendered))
+	require.NoError(t, err)
+
+	assert.Equal(t, m.From, parsed.Header.Get("From"))
+	assert.Equal(t, "first@example.com, \"Jane Doe\" <jane@example.com>", parsed.Header.Get("To"))
+	assert.Equal(t, "<ff0fb587-e29b-4278-bbab-a525196b8917@acme.com>", parsed.Header.Get("Message-ID"))
+	assert.Equal(t, "spring", parsed.Header.Get("X-Campaign"))
+
+	parts := make(map[string]string)
+	readParts(t, parsed.Header.Get("Content-Type"), parsed.Body, parts)
+
+	// multipart readers decode quoted-printable parts, base64 parts are decoded here
+	assert.Equal(t, "Hello, World!\r\nPrices start at 5€, see the attached terms.", parts["text/plain"])
+	assert.Equal(t, m.HTMLBody, parts["text/html"])
+	assert.Contains(t, parts, "image/png")
+	assert.Contains(t, parts, "application/pdf")
+}
+
+func TestRenderer_Render_RandomBoundaries(t *testing.T) {
+	t.Parallel()
+
+	sut := NewRenderer()
+
+	first, err := sut.Render(newTestMessage())
+	require.NoError(t, err)
+	second, err := sut.Render(newTestMessage())
+	require.NoError(t, err)
+
+	assert.NotEqual(t, string(first), string(second))
+}
+
+func TestRenderer_Render_SanitizesHeaders(t *testing.T) {
+	t.Parallel()
+
+	m := newTestMessage()
+	m.Subject = "Hello\r\nBcc: attacker@example.com"
+
+	rendered, err := NewRenderer().Render(m)
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(rendered))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Hello Bcc: attacker@example.com", parsed.Header.Get("Subject"))
+	assert.Empty(t, parsed.Header.Get("Bcc"))
+}
+
+func TestRenderer_Render_FoldsAddresses(t *testing.T) {
+	t.Parallel()
+
+	m := newTestMessage()
+	m.To = []string{"first.recipient@example.com", "second.recipient@example.com", "third.recipient@example.com"}
+
+	rendered, err := NewRenderer().Render(m)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(rendered), "To: first.recipient@example.com, second.recipient@example.com,\r\n third.recipient@example.com\r\n")
+}
+
+func TestRenderer_Render_EncodesHeaders(t *testing.T) {
+	t.Parallel()
+
+	m := newTestMessage()
+	m.Subject = "Però la città è già più bella, und die Grüße aus München sind auch dabei"
+	m.Headers = map[string]string{"X-Campaign": "Frühling"}
+
+	rendered, err := NewRenderer().Render(m)
+	require.NoError(t, err)
+
+	// long subjects are folded between encoded-words
+	assert.Contains(t, string(rendered), "?=\r\n =?utf-8?q?")
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(rendered))
+	require.NoError(t, err)
+
+	decoder := new(mime.WordDecoder)
+
+	assert.True(t, strings.HasPrefix(parsed.Header.Get("Subject"), "=?utf-8?q?"))
+	subject, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, m.Subject, subject)
+
+	campaign, err := decoder.DecodeHeader(parsed.Header.Get("X-Campaign"))
+	require.NoError(t, err)
+	assert.Equal(t, "Frühling", campaign)
+}
@@ -0,0 +1,340 @@
+// Package htmltext converts HTML bodies to the plain-text alternative of an email.
+package htmltext
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// horizontalRule is the line written for hr elements
+const horizontalRule = "--------------------"
+
+// cellSeparator separates the non-empty cells of a table row
+const cellSeparator = " | "
+
+// skipped are the elements whose content is not displayed
+var skipped = map[atom.Atom]bool{
+	atom.Head:     true,
+	atom.Noscript: true,
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Template: true,
+	atom.Title:    true,
+}
+
+// paragraphs are the block elements surrounded by blank lines
+var paragraphs = map[atom.Atom]bool{
+	atom.P:     true,
+	atom.H1:    true,
+	atom.H2:    true,
+	atom.H3:    true,
+	atom.H4:    true,
+	atom.H5:    true,
+	atom.H6:    true,
+	atom.Table: true,
+	atom.Dl:    true,
+}
+
+// lines are the block elements starting and ending a line
+var lines = map[atom.Atom]bool{
+	atom.Div:        true,
+	atom.Section:    true,
+	atom.Article:    true,
+	atom.Header:     true,
+	atom.Footer:     true,
+	atom.Nav:        true,
+	atom.Main:       true,
+	atom.Aside:      true,
+	atom.Address:    true,
+	atom.Center:     true,
+	atom.Form:       true,
+	atom.Fieldset:   true,
+	atom.Figure:     true,
+	atom.Figcaption: true,
+	atom.Caption:    true,
+	atom.Dt:         true,
+	atom.Dd:         true,
+}
+
+type list struct {
+	ordered bool
+	count   int
+}
+
+type converter struct {
+	out strings.Builder
+	// newlines is the number of line breaks to write before the next word
+	newlines int
+	// space tells whether whitespace was collapsed since the last word
+	space bool
+	// separator replaces the space before the next word, when it is on the same line
+	separator string
+	// bullet is written before the next word, starting a list item
+	bullet     string
+	lineStart  bool
+	quoteDepth int
+	// writtenQuoteDepth is the quote depth of the last word
+	writtenQuoteDepth int
+	preDepth          int
+	lists             []list
+	// rowStarts are the output lengths at the start of the open table rows
+	rowStarts []int
+}
+
+// Convert returns the plain text of an HTML body. Whitespace is collapsed, block elements and br
+// break lines, links are followed by their URL, list items are bulleted or numbered and table rows
+// are written on a line with their cells separated by " | ".
+func Convert(body string) string {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	c := &converter{lineStart: true}
+	c.node(doc)
+	return c.String()
+}
+
+func (c *converter) String() string {
+	textLines := strings.Split(c.out.String(), "\n")
+	for i, line := range textLines {
+		textLines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.TrimSpace(strings.Join(textLines, "\n"))
+}
+
+// breakLines asks for at least n line breaks before the next word
+func (c *converter) breakLines(n int) {
+	c.newlines = max(c.newlines, n)
+	c.space = false
+	c.separator = ""
+}
+
+// prefix returns the start of the lines within blockquotes and lists
+func (c *converter) prefix() string {
+	prefix := strings.Repeat("> ", c.quoteDepth) + strings.Repeat("  ", max(len(c.lists)-1, 0))
+	if c.bullet != "" {
+		return prefix + c.bullet
+	}
+	if len(c.lists) > 0 {
+		return prefix + "  "
+	}
+	return prefix
+}
+
+// write writes a word, or a preformatted line, with the whitespace and line breaks before it
+func (c *converter) write(word string) {
+	if c.out.Len() > 0 && c.newlines > 0 {
+		// blank lines within blockquotes keep their quote marks
+		blankLine := "\n" + strings.Repeat("> ", min(c.quoteDepth, c.writtenQuoteDepth))
+		c.out.WriteString(strings.Repeat(blankLine, c.newlines-1))
+		c.out.WriteString("\n")
+		c.lineStart = true
+	}
+	c.newlines = 0
+
+	switch {
+	case c.lineStart:
+		c.out.WriteString(c.prefix())
+		c.bullet = ""
+	case c.separator != "":
+		c.out.WriteString(c.separator)
+	case c.space:
+		c.out.WriteString(" ")
+	}
+
+	c.out.WriteString(word)
+	c.writtenQuoteDepth = c.quoteDepth
+	c.lineStart = false
+	c.space = false
+	c.separator = ""
+}
+
+func (c *converter) text(data string) {
+	if c.preDepth > 0 {
+		for i, line := range strings.Split(data, "\n") {
+			if i > 0 {
+				c.newlines++
+			}
+			if line != "" {
+				c.write(line)
+			}
+		}
+		return
+	}
+
+	words := strings.Fields(data)
+	if len(words) == 0 {
+		if data != "" {
+			c.space = true
+		}
+		return
+	}
+
+	if strings.TrimLeftFunc(data, unicode.IsSpace) != data {
+		c.space = true
+	}
+	for i, word := range words {
+		if i > 0 {
+			c.space = true
+		}
+		c.write(word)
+	}
+	if strings.TrimRightFunc(data, unicode.IsSpace) != data {
+		c.space = true
+	}
+}
+
+func attribute(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// linkTarget returns the URL written after the text of a link, if any
+func linkTarget(n *html.Node) string {
+	href := strings.TrimSpace(attribute(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	scheme, _, _ := strings.Cut(strings.ToLower(href), ":")
+	if scheme == "javascript" || scheme == "cid" {
+		return ""
+	}
+	return href
+}
+
+func (c *converter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+func (c *converter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.text(n.Data)
+		return
+	case html.DocumentNode:
+		c.children(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if skipped[n.DataAtom] {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		c.newlines++
+		c.space = false
+		c.separator = ""
+	case atom.Hr:
+		c.breakLines(1)
+		c.write(horizontalRule)
+		c.breakLines(1)
+	case atom.Img:
+		if alt := strings.Join(strings.Fields(attribute(n, "alt")), " "); alt != "" {
+			c.write(alt)
+		}
+	case atom.A:
+		c.link(n)
+	case atom.Ul, atom.Ol:
+		c.breakLines(blockLines(len(c.lists) == 0))
+		c.lists = append(c.lists, list{ordered: n.DataAtom == atom.Ol})
+		c.children(n)
+		c.lists = c.lists[:len(c.lists)-1]
+		c.breakLines(blockLines(len(c.lists) == 0))
+	case atom.Li:
+		c.listItem(n)
+	case atom.Td, atom.Th:
+		if len(c.rowStarts) > 0 && c.out.Len() > c.rowStarts[len(c.rowStarts)-1] && c.newlines == 0 {
+			c.separator = cellSeparator
+		}
+		c.children(n)
+	case atom.Tr:
+		c.breakLines(1)
+		c.rowStarts = append(c.rowStarts, c.out.Len())
+		c.children(n)
+		c.rowStarts = c.rowStarts[:len(c.rowStarts)-1]
+		c.breakLines(1)
+	case atom.Blockquote:
+		c.breakLines(2)
+		c.quoteDepth++
+		c.children(n)
+		c.breakLines(2)
+		c.quoteDepth--
+	case atom.Pre:
+		c.breakLines(2)
+		c.preDepth++
+		c.children(n)
+		c.preDepth--
+		c.breakLines(2)
+	default:
+		switch {
+		case paragraphs[n.DataAtom]:
+			c.breakLines(2)
+			c.children(n)
+			c.breakLines(2)
+		case lines[n.DataAtom]:
+			c.breakLines(1)
+			c.children(n)
+			c.breakLines(1)
+		default:
+			c.children(n)
+		}
+	}
+}
+
+// blockLines returns the line breaks around a list, with blank lines only around outermost lists
+func blockLines(outermost bool) int {
+	if outermost {
+		return 2
+	}
+	return 1
+}
+
+func (c *converter) listItem(n *html.Node) {
+	c.breakLines(1)
+	c.bullet = "- "
+	if len(c.lists) > 0 && c.lists[len(c.lists)-1].ordered {
+		current := &c.lists[len(c.lists)-1]
+		current.count++
+		c.bullet = strconv.Itoa(current.count) + ". "
+	}
+
+	c.children(n)
+	c.bullet = ""
+	c.breakLines(1)
+}
+
+// link writes the text of a link followed by its URL in parentheses, unless the text is the URL
+func (c *converter) link(n *html.Node) {
+	target := linkTarget(n)
+	start := c.out.Len()
+	c.children(n)
+	if target == "" {
+		return
+	}
+
+	text := strings.TrimSpace(c.out.String()[start:])
+	if text == target || text == strings.TrimPrefix(target, "mailto:") {
+		return
+	}
+	if text == "" {
+		c.write(target)
+		return
+	}
+	c.space = true
+	c.write("(" + target + ")")
+}
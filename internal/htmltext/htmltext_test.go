@@ -0,0 +1,131 @@
+package htmltext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvert(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name     string
+		html     string
+		expected string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:     "plain text",
+			html:     "Hello, World!",
+			expected: "Hello, World!",
+		},
+		{
+			name:     "whitespace is collapsed",
+			html:     "<p>  Hello,\n\t<b>dear</b>   <i>user</i>&nbsp;! </p>",
+			expected: "Hello, dear user !",
+		},
+		{
+			name:     "inline elements do not add spaces",
+			html:     "<p>Hel<b>lo</b></p>",
+			expected: "Hello",
+		},
+		{
+			name:     "paragraphs are separated by blank lines",
+			html:     "<h1>Welcome</h1><p>First</p><p>Second</p><div>Third</div><div>Fourth</div>",
+			expected: "Welcome\n\nFirst\n\nSecond\n\nThird\nFourth",
+		},
+		{
+			name:     "line breaks",
+			html:     "<p>Line 1<br>Line 2<br/><br/>Line 4</p>",
+			expected: "Line 1\nLine 2\n\nLine 4",
+		},
+		{
+			name:     "entities are decoded",
+			html:     "<p>Fish &amp; chips &lt;3 &eacute;t&eacute;</p>",
+			expected: "Fish & chips <3 été",
+		},
+		{
+			name:     "head, scripts and styles are skipped",
+			html:     "<html><head><title>Title</title><style>p { color: red; }</style></head><body><script>alert(1)</script><noscript><p>enable js</p></noscript><p>Body</p></body></html>",
+			expected: "Body",
+		},
+		{
+			name:     "links are followed by their URL",
+			html:     `<p>Read <a href="https://example.com/news">the news</a> today.</p>`,
+			expected: "Read the news (https://example.com/news) today.",
+		},
+		{
+			name:     "links whose text is the URL",
+			html:     `<p><a href="https://example.com">https://example.com</a> or <a href="mailto:help@example.com">help@example.com</a></p>`,
+			expected: "https://example.com or help@example.com",
+		},
+		{
+			name:     "links without text",
+			html:     `<p><a href="https://example.com/logo"><img src="logo.png"></a></p>`,
+			expected: "https://example.com/logo",
+		},
+		{
+			name:     "image links use the alt text",
+			html:     `<p><a href="https://example.com"><img src="logo.png" alt="Acme"></a></p>`,
+			expected: "Acme (https://example.com)",
+		},
+		{
+			name:     "anchors, scripts and inline images are not URLs",
+			html:     `<p><a href="#top">Top</a> <a href="javascript:void(0)">Click</a> <a href="cid:logo">Logo</a></p>`,
+			expected: "Top Click Logo",
+		},
+		{
+			name:     "unordered list",
+			html:     "<p>Items:</p><ul><li>One</li><li>Two <b>bold</b></li></ul><p>After</p>",
+			expected: "Items:\n\n- One\n- Two bold\n\nAfter",
+		},
+		{
+			name:     "ordered list",
+			html:     "<ol><li>First</li><li>Second</li><li>Third</li></ol>",
+			expected: "1. First\n2. Second\n3. Third",
+		},
+		{
+			name:     "nested lists",
+			html:     "<ul><li>Fruits<ol><li>Apple</li><li>Pear</li></ol></li><li>Vegetables<br>fresh</li></ul>",
+			expected: "- Fruits\n  1. Apple\n  2. Pear\n- Vegetables\n  fresh",
+		},
+		{
+			name:     "table rows are lines of cells",
+			html:     "<table><tr><th>Item</th><th>Price</th></tr><tr><td>Book</td><td>10 €</td></tr><tr><td>Pen</td><td></td><td>2 €</td></tr></table>",
+			expected: "Item | Price\nBook | 10 €\nPen | 2 €",
+		},
+		{
+			name:     "layout tables",
+			html:     `<table><tr><td><table><tr><td><img src="logo.png" alt=""></td></tr><tr><td><p>Hello</p><p>Welcome</p></td></tr></table></td></tr></table>`,
+			expected: "Hello\n\nWelcome",
+		},
+		{
+			name:     "blockquote",
+			html:     "<p>They wrote:</p><blockquote><p>First</p><p>Second</p></blockquote>",
+			expected: "They wrote:\n\n> First\n>\n> Second",
+		},
+		{
+			name:     "preformatted text keeps whitespace",
+			html:     "<p>Code:</p><pre>a  =  1\n\n  b = 2</pre><p>End</p>",
+			expected: "Code:\n\na  =  1\n\n  b = 2\n\nEnd",
+		},
+		{
+			name:     "horizontal rule",
+			html:     "<p>Above</p><hr><p>Below</p>",
+			expected: "Above\n\n--------------------\n\nBelow",
+		},
+		{
+			name:     "empty",
+			html:     "<html><body>  </body></html>",
+			expected: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Convert(tc.html))
+		})
+	}
+}
@@ -0,0 +1,86 @@
+// Package mailbox validates and normalizes email addresses with internationalized local parts
+// (RFC 6531) and domains (IDNA).
+package mailbox
+
+import (
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/net/idna"
+)
+
+// maxLocalPartLength is the maximum length in octets of the local part of an address (RFC 5321)
+const maxLocalPartLength = 64
+
+// domainProfile converts domains to their ASCII form, checking the DNS length limits and the
+// letters, digits and hyphens rule of host names
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.VerifyDNSLength(true),
+)
+
+func split(address string) (string, string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "", "", false
+	}
+	return address[:at], address[at+1:], true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// ToASCII converts the internationalized domain of an address to punycode. Addresses with an ASCII
+// domain, and addresses whose domain cannot be converted, are returned unchanged.
+func ToASCII(address string) string {
+	local, domain, ok := split(address)
+	if !ok || isASCII(domain) {
+		return address
+	}
+
+	asciiDomain, err := domainProfile.ToASCII(domain)
+	if err != nil {
+		return address
+	}
+	return local + "@" + asciiDomain
+}
+
+// Valid tells whether address is a bare address with a local part of ASCII or UTF-8 characters and
+// a valid host name, either ASCII or internationalized
+func Valid(address string) bool {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Name != "" || parsed.Address != address {
+		return false
+	}
+
+	local, domain, ok := split(address)
+	if !ok || local == "" || len(local) > maxLocalPartLength {
+		return false
+	}
+
+	asciiDomain, err := domainProfile.ToASCII(domain)
+	return err == nil && strings.Contains(strings.TrimSuffix(asciiDomain, "."), ".")
+}
+
+// NeedsSMTPUTF8 tells whether the local part of an address is not ASCII, so that emails to or from
+// it can only be relayed by servers supporting SMTPUTF8 (RFC 6531). Internationalized domains do not
+// need it, since they are converted to punycode.
+func NeedsSMTPUTF8(address string) bool {
+	local, _, _ := split(address)
+	return !isASCII(local)
+}
+
+// Validate is a validator.Func checking string fields with Valid, a Unicode-aware replacement of
+// the email tag
+func Validate(fl validator.FieldLevel) bool {
+	return Valid(fl.Field().String())
+}
@@ -0,0 +1,165 @@
+package mailbox
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToASCII(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name     string
+		address  string
+		expected string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:     "ascii domain",
+			address:  "user@example.com",
+			expected: "user@example.com",
+		},
+		{
+			name:     "internationalized domain",
+			address:  "user@müller.de",
+			expected: "user@xn--mller-kva.de",
+		},
+		{
+			name:     "uppercase internationalized domain",
+			address:  "user@MÜLLER.de",
+			expected: "user@xn--mller-kva.de",
+		},
+		{
+			name:     "utf-8 local part is kept",
+			address:  "jörg@bücher.example",
+			expected: "jörg@xn--bcher-kva.example",
+		},
+		{
+			name:     "invalid domain is unchanged",
+			address:  "user@-müller.de",
+			expected: "user@-müller.de",
+		},
+		{
+			name:     "no domain",
+			address:  "user",
+			expected: "user",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ToASCII(tc.address))
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name     string
+		address  string
+		expected bool
+	}
+
+	testCases := []caseStruct{
+		{
+			name:     "ascii address",
+			address:  "user@example.com",
+			expected: true,
+		},
+		{
+			name:     "internationalized domain",
+			address:  "user@müller.de",
+			expected: true,
+		},
+		{
+			name:     "punycode domain",
+			address:  "user@xn--mller-kva.de",
+			expected: true,
+		},
+		{
+			name:     "utf-8 local part",
+			address:  "jörg@example.com",
+			expected: true,
+		},
+		{
+			name:     "local part too long",
+			address:  strings.Repeat("a", 65) + "@example.com",
+			expected: false,
+		},
+		{
+			name:     "utf-8 local part too long in octets",
+			address:  strings.Repeat("ö", 33) + "@example.com",
+			expected: false,
+		},
+		{
+			name:     "domain without dot",
+			address:  "user@localhost",
+			expected: false,
+		},
+		{
+			name:     "invalid domain label",
+			address:  "user@-müller.de",
+			expected: false,
+		},
+		{
+			name:     "display name",
+			address:  "User <user@example.com>",
+			expected: false,
+		},
+		{
+			name:     "no domain",
+			address:  "user",
+			expected: false,
+		},
+		{
+			name:     "empty",
+			address:  "",
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Valid(tc.address))
+		})
+	}
+}
+
+func TestNeedsSMTPUTF8(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name     string
+		address  string
+		expected bool
+	}
+
+	testCases := []caseStruct{
+		{
+			name:     "ascii address",
+			address:  "user@example.com",
+			expected: false,
+		},
+		{
+			name:     "internationalized domain",
+			address:  "user@müller.de",
+			expected: false,
+		},
+		{
+			name:     "utf-8 local part",
+			address:  "jörg@example.com",
+			expected: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, NeedsSMTPUTF8(tc.address))
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package suppressions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"multicarrier-email-api/internal/auth"
+	"multicarrier-email-api/internal/mailbox"
+)
+
+var (
+	ErrSuppressionNotFound = errors.New("suppression not found")
+	ErrNoTenant            = errors.New("no tenant in context")
+	ErrGlobalScope         = errors.New("global suppressions can only be managed by super admins")
+)
+
+// globalTenant is the tenant_id of global suppressions
+const globalTenant = ""
+
+// insertBatchSize is the maximum number of suppressions inserted per query
+const insertBatchSize = 500
+
+type Database struct {
+	db *sql.DB
+}
+
+func NewDatabase(db *sql.DB) *Database {
+	return &Database{
+		db: db,
+	}
+}
+
+// normalizeAddress lowercases an address and converts its domain to punycode, as intake does with
+// recipients, so that internationalized domains match either form
+func normalizeAddress(address string) string {
+	return strings.ToLower(mailbox.ToASCII(strings.TrimSpace(address)))
+}
+
+// readCondition restricts a query to the suppressions of the tenant of the caller and to the
+// global ones. Super admins are not restricted.
+func readCondition(ctx context.Context) (string, []any, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", nil, ErrNoTenant
+	}
+
+	if principal.SuperAdmin {
+		return "TRUE", nil, nil
+	}
+
+	return "(tenant_id = ? OR tenant_id = ?)", []any{principal.Tenant, globalTenant}, nil
+}
+
+// writeTenant returns the tenant_id of the suppressions managed by the caller
+func writeTenant(ctx context.Context, global bool) (string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+
+	if global {
+		if !principal.SuperAdmin {
+			return "", ErrGlobalScope
+		}
+		return globalTenant, nil
+	}
+
+	if principal.Tenant == "" {
+		return "", ErrNoTenant
+	}
+	return principal.Tenant, nil
+}
+
+// Insert stores the suppressions in the scope of the caller, in a single transaction. Already
+// suppressed addresses are left as they are. It returns how many suppressions were added.
+func (d *Database) Insert(ctx context.Context, entries []Suppression, global bool) (int64, error) {
+	tenant, err := writeTenant(ctx, global)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var inserted int64
+	for start := 0; start < len(entries); start += insertBatchSize {
+		batch := entries[start:min(start+insertBatchSize, len(entries))]
+
+		placeholders := make([]string, len(batch))
+		args := make([]any, 0, len(batch)*3)
+		for i, entry := range batch {
+			placeholders[i] = "(?, ?, ?)"
+			args = append(args, tenant, normalizeAddress(entry.Address), entry.Reason)
+		}
+
+		result, err := tx.ExecContext(ctx,
+			`INSERT IGNORE INTO suppressions (tenant_id, address, reason) VALUES `+strings.Join(placeholders, ", "),
+			args...,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert suppressions: %w", err)
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("failed to get inserted suppressions: %w", err)
+		}
+		inserted += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return inserted, nil
+}
+
+func scanSuppression(rows *sql.Rows) (Suppression, error) {
+	var s Suppression
+	if err := rows.Scan(&s.Tenant, &s.Address, &s.Reason, &s.CreatedAt); err != nil {
+		return Suppression{}, fmt.Errorf("failed to scan suppression: %w", err)
+	}
+	s.Global = s.Tenant == globalTenant
+	return s, nil
+}
+
+// each calls fn with the suppressions visible to the caller matching the params, without limit when Limit is 0
+func (d *Database) each(ctx context.Context, params ListParams, orderBy string, fn func(Suppression) error) error {
+	cond, args, err := readCondition(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT tenant_id, address, reason, created_at FROM suppressions WHERE ` + cond
+	if params.Address != "" {
+		query += ` AND address = ?`
+		args = append(args, normalizeAddress(params.Address))
+	}
+	if params.Reason != "" {
+		query += ` AND reason = ?`
+		args = append(args, params.Reason)
+	}
+	query += ` ORDER BY ` + orderBy
+	if params.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, params.Limit)
+	}
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSuppression(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return nil
+}
+
+// List returns the most recent suppressions visible to the caller
+func (d *Database) List(ctx context.Context, params ListParams) ([]Suppression, error) {
+	result := []Suppression{}
+	err := d.each(ctx, params, "created_at DESC, address", func(s Suppression) error {
+		result = append(result, s)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Export calls fn with every suppression visible to the caller, by address, without holding them in memory
+func (d *Database) Export(ctx context.Context, reason string, fn func(Suppression) error) error {
+	return d.each(ctx, ListParams{Reason: reason}, "address, reason, tenant_id", fn)
+}
+
+// Delete removes the suppressions of an address in the scope of the caller, for every reason when
+// reason is empty
+func (d *Database) Delete(ctx context.Context, address string, reason string, global bool) error {
+	tenant, err := writeTenant(ctx, global)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM suppressions WHERE tenant_id = ? AND address = ?`
+	args := []any{tenant, normalizeAddress(address)}
+	if reason != "" {
+		query += ` AND reason = ?`
+		args = append(args, reason)
+	}
+
+	result, err := d.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete suppressions: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get deleted suppressions: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrSuppressionNotFound, address)
+	}
+
+	return nil
+}
+
+// FindSuppressed returns the given addresses suppressed for the tenant of the caller, by its own
+// suppressions or by global ones
+func (d *Database) FindSuppressed(ctx context.Context, addresses []string) ([]string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Tenant == "" {
+		return nil, ErrNoTenant
+	}
+
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	placeholders := make([]string, len(addresses))
+	args := []any{principal.Tenant, globalTenant}
+	for i, address := range addresses {
+		placeholders[i] = "?"
+		args = append(args, normalizeAddress(address))
+	}
+
+	rows, err := d.db.QueryContext(ctx,
+		`SELECT DISTINCT address FROM suppressions
+		WHERE tenant_id IN (?, ?) AND address IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY address`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suppressions: %w", err)
+	}
+	defer rows.Close()
+
+	var suppressed []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return nil, fmt.Errorf("failed to scan suppressed address: %w", err)
+		}
+		suppressed = append(suppressed, address)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return suppressed, nil
+}
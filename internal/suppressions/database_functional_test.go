@@ -0,0 +1,124 @@
+package suppressions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"multicarrier-email-api/internal/auth"
+)
+
+func getTestDB(t *testing.T) *sql.DB {
+	host := os.Getenv("MYSQL_HOST")
+	port := os.Getenv("MYSQL_PORT")
+	user := os.Getenv("MYSQL_USER")
+	password := os.Getenv("MYSQL_PASSWORD")
+	database := os.Getenv("MYSQL_DATABASE")
+
+	if host == "" || user == "" || database == "" {
+		t.Skip("MySQL environment variables not set, skipping functional test")
+	}
+
+	if port == "" {
+		port = "3306"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, database)
+
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	require.NoError(t, db.Ping())
+
+	return db
+}
+
+func TestSuppressionsComponentWorkflow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db)
+
+	firstTenant := "tenant-" + uuid.NewString()
+	secondTenant := "tenant-" + uuid.NewString()
+	firstCtx := auth.NewContext(context.TODO(), auth.Principal{Tenant: firstTenant})
+	secondCtx := auth.NewContext(context.TODO(), auth.Principal{Tenant: secondTenant})
+	adminCtx := auth.NewContext(context.TODO(), auth.Principal{Tenant: "ops", SuperAdmin: true})
+
+	bounced := uuid.NewString() + "@example.com"
+	complained := uuid.NewString() + "@example.com"
+	blocked := uuid.NewString() + "@example.com"
+	defer func() {
+		_, _ = db.Exec("DELETE FROM suppressions WHERE address IN (?, ?, ?)", bounced, complained, blocked)
+	}()
+
+	inserted, err := sut.Insert(firstCtx, []Suppression{
+		{Address: bounced, Reason: ReasonBounce},
+		{Address: bounced, Reason: ReasonManual},
+		{Address: complained, Reason: ReasonComplaint},
+	}, false)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, inserted)
+
+	// importing again the same suppressions adds nothing
+	inserted, err = sut.Insert(firstCtx, []Suppression{{Address: bounced, Reason: ReasonBounce}}, false)
+	require.NoError(t, err)
+	require.Zero(t, inserted)
+
+	// only super admins manage global suppressions
+	_, err = sut.Insert(firstCtx, []Suppression{{Address: blocked, Reason: ReasonManual}}, true)
+	require.ErrorIs(t, err, ErrGlobalScope)
+	_, err = sut.Insert(adminCtx, []Suppression{{Address: blocked, Reason: ReasonManual}}, true)
+	require.NoError(t, err)
+
+	suppressed, err := sut.FindSuppressed(firstCtx, []string{bounced, complained, blocked, "other@example.com"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{bounced, complained, blocked}, suppressed)
+
+	// suppressions of a tenant do not apply to the others, global ones do
+	suppressed, err = sut.FindSuppressed(secondCtx, []string{bounced, complained, blocked})
+	require.NoError(t, err)
+	require.Equal(t, []string{blocked}, suppressed)
+
+	entries, err := sut.List(firstCtx, ListParams{Address: bounced})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, firstTenant, entries[0].Tenant)
+
+	entries, err = sut.List(secondCtx, ListParams{Address: blocked, Reason: ReasonManual})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, entries[0].Global)
+
+	var exported int
+	err = sut.Export(firstCtx, ReasonBounce, func(s Suppression) error {
+		if s.Address == bounced {
+			exported++
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, exported)
+
+	// removing a reason keeps the others
+	require.NoError(t, sut.Delete(firstCtx, bounced, ReasonBounce, false))
+	require.ErrorIs(t, sut.Delete(secondCtx, bounced, "", false), ErrSuppressionNotFound)
+
+	suppressed, err = sut.FindSuppressed(firstCtx, []string{bounced})
+	require.NoError(t, err)
+	require.Equal(t, []string{bounced}, suppressed)
+
+	require.NoError(t, sut.Delete(firstCtx, bounced, "", false))
+	suppressed, err = sut.FindSuppressed(firstCtx, []string{bounced})
+	require.NoError(t, err)
+	require.Empty(t, suppressed)
+}
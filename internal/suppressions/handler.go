@@ -0,0 +1,389 @@
+package suppressions
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"multicarrier-email-api/internal/mailbox"
+	"multicarrier-email-api/internal/response"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// csvHeader is the header row of imported and exported suppression lists. Imports only need the
+// address and reason columns.
+var csvHeader = []string{"address", "reason", "tenant", "global", "created_at"}
+
+type suppressionInput struct {
+	Address string `json:"address" validate:"required,email,max=320"`
+	Reason  string `json:"reason" validate:"required,oneof=bounce complaint manual"`
+	Global  bool   `json:"global"`
+}
+
+func (i suppressionInput) suppression() Suppression {
+	return Suppression{
+		Address: normalizeAddress(i.Address),
+		Reason:  i.Reason,
+		Global:  i.Global,
+	}
+}
+
+func newValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	// the email tag rejects internationalized addresses
+	_ = validate.RegisterValidation("email", mailbox.Validate)
+	return validate
+}
+
+// writeServiceError maps the errors of the suppression service to HTTP statuses
+func writeServiceError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, ErrSuppressionNotFound):
+		response.WriteError(http.StatusNotFound, w, "suppression not found")
+	case errors.Is(err, ErrGlobalScope):
+		response.WriteError(http.StatusForbidden, w, err.Error())
+	default:
+		slog.Error(fmt.Sprintf("error %s suppressions: %v", action, err))
+		response.WriteError(http.StatusInternalServerError, w, fmt.Sprintf("error %s suppressions", action))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error(fmt.Sprintf("error encoding response: %v", err))
+	}
+}
+
+// parseGlobal reads the global query parameter, which selects the suppressions shared by every tenant
+func parseGlobal(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("global")
+	if value == "" {
+		return false, true
+	}
+
+	global, err := strconv.ParseBool(value)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, "global must be true or false")
+		return false, false
+	}
+	return global, true
+}
+
+// parseReason reads the optional reason query parameter
+func parseReason(w http.ResponseWriter, r *http.Request) (string, bool) {
+	reason := r.URL.Query().Get("reason")
+	switch reason {
+	case "", ReasonBounce, ReasonComplaint, ReasonManual:
+		return reason, true
+	default:
+		response.WriteError(http.StatusBadRequest, w, "reason must be bounce, complaint or manual")
+		return "", false
+	}
+}
+
+type createSuppressionServiceInterface interface {
+	Create(ctx context.Context, entry Suppression) error
+}
+
+// CreateSuppressionHandler suppresses an address for the tenant of the caller, or for every tenant
+// when global
+type CreateSuppressionHandler struct {
+	suppressionService createSuppressionServiceInterface
+}
+
+func NewCreateSuppressionHandler(suppressionService createSuppressionServiceInterface) *CreateSuppressionHandler {
+	return &CreateSuppressionHandler{
+		suppressionService: suppressionService,
+	}
+}
+
+func (h *CreateSuppressionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var input suppressionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error unmarshalling request body: %v", err))
+		return
+	}
+	input.Address = strings.TrimSpace(input.Address)
+
+	if err := newValidator().Struct(input); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating request body: %v", err))
+		return
+	}
+
+	entry := input.suppression()
+	if err := h.suppressionService.Create(r.Context(), entry); err != nil {
+		writeServiceError(w, err, "creating")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+type listSuppressionsServiceInterface interface {
+	List(ctx context.Context, params ListParams) ([]Suppression, error)
+}
+
+// ListSuppressionsHandler returns the most recent suppressions visible to the caller, filtered by
+// the address and reason query parameters
+type ListSuppressionsHandler struct {
+	suppressionService listSuppressionsServiceInterface
+}
+
+func NewListSuppressionsHandler(suppressionService listSuppressionsServiceInterface) *ListSuppressionsHandler {
+	return &ListSuppressionsHandler{
+		suppressionService: suppressionService,
+	}
+}
+
+func (h *ListSuppressionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reason, ok := parseReason(w, r)
+	if !ok {
+		return
+	}
+
+	params := ListParams{
+		Address: r.URL.Query().Get("address"),
+		Reason:  reason,
+		Limit:   defaultListLimit,
+	}
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("limit must be an integer between 1 and %d", maxListLimit))
+			return
+		}
+		params.Limit = limit
+	}
+
+	entries, err := h.suppressionService.List(r.Context(), params)
+	if err != nil {
+		writeServiceError(w, err, "listing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, entries)
+}
+
+type deleteSuppressionServiceInterface interface {
+	Delete(ctx context.Context, address string, reason string, global bool) error
+}
+
+// DeleteSuppressionHandler removes the suppressions of an address, for every reason unless the
+// reason query parameter is given
+type DeleteSuppressionHandler struct {
+	suppressionService deleteSuppressionServiceInterface
+}
+
+func NewDeleteSuppressionHandler(suppressionService deleteSuppressionServiceInterface) *DeleteSuppressionHandler {
+	return &DeleteSuppressionHandler{
+		suppressionService: suppressionService,
+	}
+}
+
+func (h *DeleteSuppressionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	address := r.PathValue("address")
+	if address == "" {
+		response.WriteError(http.StatusBadRequest, w, "address parameter is required")
+		return
+	}
+
+	reason, ok := parseReason(w, r)
+	if !ok {
+		return
+	}
+
+	global, ok := parseGlobal(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.suppressionService.Delete(r.Context(), address, reason, global); err != nil {
+		writeServiceError(w, err, "deleting")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type importSuppressionsServiceInterface interface {
+	Import(ctx context.Context, entries []Suppression, global bool) (int64, error)
+}
+
+// ImportSuppressionsHandler stores a CSV list of suppressions with address and reason columns.
+// Every row is validated before storing, and a single invalid row rejects the whole list.
+type ImportSuppressionsHandler struct {
+	suppressionService importSuppressionsServiceInterface
+}
+
+func NewImportSuppressionsHandler(suppressionService importSuppressionsServiceInterface) *ImportSuppressionsHandler {
+	return &ImportSuppressionsHandler{
+		suppressionService: suppressionService,
+	}
+}
+
+type importResult struct {
+	Total    int   `json:"total"`
+	Imported int64 `json:"imported"`
+}
+
+// readImport decodes and validates the rows of an imported CSV list, reporting errors by line
+func readImport(body io.Reader, global bool) ([]Suppression, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("empty list, a header row with address and reason columns is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	addressColumn, hasAddress := columns["address"]
+	reasonColumn, hasReason := columns["reason"]
+	if !hasAddress || !hasReason {
+		return nil, errors.New("header row must have address and reason columns")
+	}
+
+	validate := newValidator()
+	var entries []Suppression
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if addressColumn >= len(record) || reasonColumn >= len(record) {
+			return nil, fmt.Errorf("line %d: missing address or reason", line)
+		}
+
+		input := suppressionInput{
+			Address: strings.TrimSpace(record[addressColumn]),
+			Reason:  strings.ToLower(strings.TrimSpace(record[reasonColumn])),
+			Global:  global,
+		}
+		if err := validate.Struct(input); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		entries = append(entries, input.suppression())
+	}
+
+	return entries, nil
+}
+
+func (h *ImportSuppressionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	global, ok := parseGlobal(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := readImport(r.Body, global)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error reading suppression list: %v", err))
+		return
+	}
+
+	imported, err := h.suppressionService.Import(r.Context(), entries, global)
+	if err != nil {
+		writeServiceError(w, err, "importing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, importResult{Total: len(entries), Imported: imported})
+}
+
+type exportSuppressionsServiceInterface interface {
+	Export(ctx context.Context, reason string, fn func(Suppression) error) error
+}
+
+// ExportSuppressionsHandler streams the suppressions visible to the caller as CSV, optionally
+// filtered by the reason query parameter. The output can be imported back.
+type ExportSuppressionsHandler struct {
+	suppressionService exportSuppressionsServiceInterface
+}
+
+func NewExportSuppressionsHandler(suppressionService exportSuppressionsServiceInterface) *ExportSuppressionsHandler {
+	return &ExportSuppressionsHandler{
+		suppressionService: suppressionService,
+	}
+}
+
+func (h *ExportSuppressionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reason, ok := parseReason(w, r)
+	if !ok {
+		return
+	}
+
+	writer := csv.NewWriter(w)
+	started := false
+
+	// the header row is written with the first suppression, so that query errors can still be reported
+	start := func() error {
+		started = true
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="suppressions.csv"`)
+		return writer.Write(csvHeader)
+	}
+
+	err := h.suppressionService.Export(r.Context(), reason, func(s Suppression) error {
+		if !started {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		return writer.Write([]string{
+			s.Address,
+			s.Reason,
+			s.Tenant,
+			strconv.FormatBool(s.Global),
+			s.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		if !started {
+			writeServiceError(w, err, "exporting")
+			return
+		}
+		// the response is already partially written, the client sees a truncated list
+		slog.Error(fmt.Sprintf("error exporting suppressions: %v", err))
+		return
+	}
+
+	if !started {
+		_ = start()
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		slog.Error(fmt.Sprintf("error writing suppressions export: %v", err))
+	}
+}
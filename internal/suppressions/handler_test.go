@@ -0,0 +1,305 @@
+package suppressions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type suppressionServiceMock struct {
+	returnErr      error
+	created        []Suppression
+	imported       []Suppression
+	importedGlobal bool
+	listParams     ListParams
+	deleted        []string
+	exported       []Suppression
+}
+
+func (m *suppressionServiceMock) Create(_ context.Context, entry Suppression) error {
+	m.created = append(m.created, entry)
+	return m.returnErr
+}
+
+func (m *suppressionServiceMock) Import(_ context.Context, entries []Suppression, global bool) (int64, error) {
+	m.imported = entries
+	m.importedGlobal = global
+	return int64(len(entries)) - 1, m.returnErr
+}
+
+func (m *suppressionServiceMock) List(_ context.Context, params ListParams) ([]Suppression, error) {
+	m.listParams = params
+	return []Suppression{
+		{Address: "user@example.com", Reason: ReasonBounce, Tenant: "tenant-a", CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{Address: "user@example.com", Reason: ReasonComplaint, Global: true, CreatedAt: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC)},
+	}, m.returnErr
+}
+
+func (m *suppressionServiceMock) Delete(_ context.Context, address string, reason string, global bool) error {
+	m.deleted = append(m.deleted, address, reason, map[bool]string{true: "global", false: "tenant"}[global])
+	return m.returnErr
+}
+
+func (m *suppressionServiceMock) Export(_ context.Context, _ string, fn func(Suppression) error) error {
+	if m.returnErr != nil {
+		return m.returnErr
+	}
+	for _, s := range m.exported {
+		if err := fn(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCreateSuppressionHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			body:               `{"address": " User@Example.com", "reason": "bounce"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{"address": "user@example.com", "reason": "bounce", "global": false}`,
+		},
+		{
+			name:               "internationalized domain",
+			body:               `{"address": "Jörg@Müller.de", "reason": "bounce"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{"address": "jörg@xn--mller-kva.de", "reason": "bounce", "global": false}`,
+		},
+		{
+			name:               "invalid reason",
+			body:               `{"address": "user@example.com", "reason": "spam"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'suppressionInput.Reason' Error:Field validation for 'Reason' failed on the 'oneof' tag"}`,
+		},
+		{
+			name:               "invalid address",
+			body:               `{"address": "user", "reason": "manual"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'suppressionInput.Address' Error:Field validation for 'Address' failed on the 'email' tag"}`,
+		},
+		{
+			name:               "global without super admin",
+			serviceErr:         ErrGlobalScope,
+			body:               `{"address": "user@example.com", "reason": "complaint", "global": true}`,
+			expectedStatusCode: http.StatusForbidden,
+			expectedBody:       `{"error": "global suppressions can only be managed by super admins"}`,
+		},
+		{
+			name:               "database error",
+			serviceErr:         errors.New("mock error"),
+			body:               `{"address": "user@example.com", "reason": "manual"}`,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error creating suppressions"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &suppressionServiceMock{returnErr: tc.serviceErr}
+			sut := NewCreateSuppressionHandler(service)
+
+			request := httptest.NewRequest(http.MethodPost, "/suppressions", strings.NewReader(tc.body))
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+		})
+	}
+}
+
+func TestListSuppressionsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		query              string
+		expectedStatusCode int
+		expectedParams     ListParams
+	}
+
+	testCases := []caseStruct{
+		{"defaults", "", http.StatusOK, ListParams{Limit: 100}},
+		{"filters", "?address=user@example.com&reason=bounce&limit=10", http.StatusOK, ListParams{Address: "user@example.com", Reason: ReasonBounce, Limit: 10}},
+		{"invalid reason", "?reason=spam", http.StatusBadRequest, ListParams{}},
+		{"invalid limit", "?limit=1001", http.StatusBadRequest, ListParams{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &suppressionServiceMock{}
+			sut := NewListSuppressionsHandler(service)
+
+			request := httptest.NewRequest(http.MethodGet, "/suppressions"+tc.query, nil)
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.Equal(t, tc.expectedParams, service.listParams)
+		})
+	}
+
+	service := &suppressionServiceMock{}
+	response := httptest.NewRecorder()
+	NewListSuppressionsHandler(service).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/suppressions", nil))
+
+	assert.JSONEq(t, `[
+		{"address": "user@example.com", "reason": "bounce", "tenant": "tenant-a", "global": false, "created_at": "2024-01-01T12:00:00Z"},
+		{"address": "user@example.com", "reason": "complaint", "global": true, "created_at": "2024-01-01T11:00:00Z"}
+	]`, response.Body.String())
+}
+
+func TestDeleteSuppressionHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		target             string
+		expectedStatusCode int
+		expectedDeleted    []string
+	}
+
+	testCases := []caseStruct{
+		{"every reason", nil, "/suppressions/user@example.com", http.StatusNoContent, []string{"user@example.com", "", "tenant"}},
+		{"single reason", nil, "/suppressions/user@example.com?reason=bounce", http.StatusNoContent, []string{"user@example.com", "bounce", "tenant"}},
+		{"global", nil, "/suppressions/user@example.com?global=true", http.StatusNoContent, []string{"user@example.com", "", "global"}},
+		{"invalid global", nil, "/suppressions/user@example.com?global=maybe", http.StatusBadRequest, nil},
+		{"not found", ErrSuppressionNotFound, "/suppressions/user@example.com", http.StatusNotFound, []string{"user@example.com", "", "tenant"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &suppressionServiceMock{returnErr: tc.serviceErr}
+
+			mux := http.NewServeMux()
+			mux.Handle("DELETE /suppressions/{address}", NewDeleteSuppressionHandler(service))
+
+			request := httptest.NewRequest(http.MethodDelete, tc.target, nil)
+			response := httptest.NewRecorder()
+
+			mux.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.Equal(t, tc.expectedDeleted, service.deleted)
+		})
+	}
+}
+
+func TestImportSuppressionsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		query              string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+		expectedImported   []Suppression
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			body:               "reason,address\nbounce,First@example.com\nComplaint, second@example.com\n",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"total": 2, "imported": 1}`,
+			expectedImported: []Suppression{
+				{Address: "first@example.com", Reason: ReasonBounce},
+				{Address: "second@example.com", Reason: ReasonComplaint},
+			},
+		},
+		{
+			name:               "global export imported back",
+			query:              "?global=true",
+			body:               "address,reason,tenant,global,created_at\nuser@example.com,manual,,true,2024-01-01T12:00:00Z\n",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"total": 1, "imported": 0}`,
+			expectedImported:   []Suppression{{Address: "user@example.com", Reason: ReasonManual, Global: true}},
+		},
+		{
+			name:               "missing columns",
+			body:               "email\nuser@example.com\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error reading suppression list: header row must have address and reason columns"}`,
+		},
+		{
+			name:               "invalid row",
+			body:               "address,reason\nfirst@example.com,bounce\nsecond,bounce\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error reading suppression list: line 3: Key: 'suppressionInput.Address' Error:Field validation for 'Address' failed on the 'email' tag"}`,
+		},
+		{
+			name:               "empty",
+			body:               "",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error reading suppression list: empty list, a header row with address and reason columns is required"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &suppressionServiceMock{}
+			sut := NewImportSuppressionsHandler(service)
+
+			request := httptest.NewRequest(http.MethodPost, "/suppressions/import"+tc.query, strings.NewReader(tc.body))
+			request.Header.Set("Content-Type", "text/csv")
+			response := httptest.NewRecorder()
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			assert.Equal(t, tc.expectedImported, service.imported)
+		})
+	}
+}
+
+func TestExportSuppressionsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	service := &suppressionServiceMock{exported: []Suppression{
+		{Address: "first@example.com", Reason: ReasonBounce, Tenant: "tenant-a", CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{Address: "second@example.com", Reason: ReasonManual, Global: true, CreatedAt: time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)},
+	}}
+	sut := NewExportSuppressionsHandler(service)
+
+	response := httptest.NewRecorder()
+	sut.ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/suppressions/export", nil))
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "text/csv", response.Header().Get("Content-Type"))
+	assert.Equal(t, "address,reason,tenant,global,created_at\n"+
+		"first@example.com,bounce,tenant-a,false,2024-01-01T12:00:00Z\n"+
+		"second@example.com,manual,,true,2024-01-02T12:00:00Z\n", response.Body.String())
+
+	failing := &suppressionServiceMock{returnErr: errors.New("mock error")}
+	response = httptest.NewRecorder()
+	NewExportSuppressionsHandler(failing).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/suppressions/export", nil))
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.JSONEq(t, `{"error": "error exporting suppressions"}`, response.Body.String())
+
+	empty := &suppressionServiceMock{}
+	response = httptest.NewRecorder()
+	NewExportSuppressionsHandler(empty).ServeHTTP(response, httptest.NewRequest(http.MethodGet, "/suppressions/export", nil))
+	assert.Equal(t, "address,reason,tenant,global,created_at\n", response.Body.String())
+}
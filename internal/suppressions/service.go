@@ -0,0 +1,50 @@
+package suppressions
+
+import (
+	"context"
+)
+
+type databaseInterface interface {
+	Insert(ctx context.Context, entries []Suppression, global bool) (int64, error)
+	List(ctx context.Context, params ListParams) ([]Suppression, error)
+	Export(ctx context.Context, reason string, fn func(Suppression) error) error
+	Delete(ctx context.Context, address string, reason string, global bool) error
+	FindSuppressed(ctx context.Context, addresses []string) ([]string, error)
+}
+
+type Service struct {
+	db databaseInterface
+}
+
+func NewService(db databaseInterface) *Service {
+	return &Service{
+		db: db,
+	}
+}
+
+func (s *Service) Create(ctx context.Context, entry Suppression) error {
+	_, err := s.db.Insert(ctx, []Suppression{entry}, entry.Global)
+	return err
+}
+
+// Import stores the entries all together or none of them, returning how many were not already suppressed
+func (s *Service) Import(ctx context.Context, entries []Suppression, global bool) (int64, error) {
+	return s.db.Insert(ctx, entries, global)
+}
+
+func (s *Service) List(ctx context.Context, params ListParams) ([]Suppression, error) {
+	return s.db.List(ctx, params)
+}
+
+func (s *Service) Export(ctx context.Context, reason string, fn func(Suppression) error) error {
+	return s.db.Export(ctx, reason, fn)
+}
+
+func (s *Service) Delete(ctx context.Context, address string, reason string, global bool) error {
+	return s.db.Delete(ctx, address, reason, global)
+}
+
+// FindSuppressed returns the given addresses the tenant of the caller must not send to
+func (s *Service) FindSuppressed(ctx context.Context, addresses []string) ([]string, error) {
+	return s.db.FindSuppressed(ctx, addresses)
+}
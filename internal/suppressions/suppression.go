@@ -0,0 +1,26 @@
+package suppressions
+
+import "time"
+
+const (
+	ReasonBounce    = "bounce"
+	ReasonComplaint = "complaint"
+	ReasonManual    = "manual"
+)
+
+// Suppression blocks the emails of a tenant, or of every tenant when global, to an address.
+// An address can be suppressed for several reasons, each one removed on its own.
+type Suppression struct {
+	Address   string    `json:"address"`
+	Reason    string    `json:"reason"`
+	Tenant    string    `json:"tenant,omitempty"`
+	Global    bool      `json:"global"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+}
+
+// ListParams filters suppressions by address and reason, empty values match any
+type ListParams struct {
+	Address string
+	Reason  string
+	Limit   int
+}
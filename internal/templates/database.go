@@ -0,0 +1,246 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"multicarrier-email-api/internal/auth"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	mysqlDuplicateEntryCode = 1062
+	mysqlDeadlockCode       = 1213
+)
+
+// insertVersionAttempts bounds the retries of InsertVersion transactions rolled back by a deadlock
+const insertVersionAttempts = 3
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrNoTenant         = errors.New("no tenant in context")
+)
+
+type Database struct {
+	db *sql.DB
+}
+
+func NewDatabase(db *sql.DB) *Database {
+	return &Database{
+		db: db,
+	}
+}
+
+// tenantCondition restricts a query to the templates of the tenant of the caller found in the
+// context. Super admins are not restricted.
+func tenantCondition(ctx context.Context, column string) (string, []any, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return "", nil, ErrNoTenant
+	}
+
+	if principal.SuperAdmin {
+		return "TRUE", nil, nil
+	}
+
+	return column + " = ?", []any{principal.Tenant}, nil
+}
+
+// InsertVersion stores the template as the next version of its id for the tenant of the caller, and
+// returns that version. When mustExist is false the id must be new, when it is true the id must
+// already have a version.
+func (d *Database) InsertVersion(ctx context.Context, t Template, mustExist bool) (int, error) {
+	for attempt := 1; ; attempt++ {
+		version, err := d.insertVersion(ctx, t, mustExist)
+		// concurrent creates lock the same gap of the index, so all but one of them may deadlock.
+		// Retried, they find the version inserted by the winner.
+		if attempt < insertVersionAttempts && isMySQLError(err, mysqlDeadlockCode) {
+			continue
+		}
+		return version, err
+	}
+}
+
+func (d *Database) insertVersion(ctx context.Context, t Template, mustExist bool) (int, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok || principal.Tenant == "" {
+		return 0, ErrNoTenant
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var latestVersion int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM templates WHERE tenant_id = ? AND id = ? FOR UPDATE`,
+		principal.Tenant, t.Id,
+	).Scan(&latestVersion)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get latest template version: %w", err)
+	}
+
+	if mustExist && latestVersion == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrTemplateNotFound, t.Id)
+	}
+	if !mustExist && latestVersion > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrTemplateExists, t.Id)
+	}
+
+	version := latestVersion + 1
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO templates (tenant_id, id, version, subject, body_html, body_text) VALUES (?, ?, ?, ?, ?, ?)`,
+		principal.Tenant, t.Id, version, t.Subject, t.BodyHTML, t.BodyText,
+	)
+	if err != nil {
+		// a concurrent create of the same id inserted its first version since the check above
+		if !mustExist && isMySQLError(err, mysqlDuplicateEntryCode) {
+			return 0, fmt.Errorf("%w: %s", ErrTemplateExists, t.Id)
+		}
+		return 0, fmt.Errorf("failed to insert template: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return version, nil
+}
+
+// Get returns the given version of a template, or the latest one when version is 0
+func (d *Database) Get(ctx context.Context, id string, version int) (Template, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return Template{}, err
+	}
+
+	query := `SELECT id, version, subject, body_html, body_text, created_at 
+		FROM templates 
+		WHERE id = ? AND version = ? AND ` + tenantCond
+	args := append([]any{id, version}, tenantArgs...)
+
+	if version == 0 {
+		query = `SELECT id, version, subject, body_html, body_text, created_at 
+			FROM templates 
+			WHERE id = ? AND ` + tenantCond + ` 
+			ORDER BY version DESC 
+			LIMIT 1`
+		args = append([]any{id}, tenantArgs...)
+	}
+
+	var t Template
+	err = d.db.QueryRowContext(ctx, query, args...).
+		Scan(&t.Id, &t.Version, &t.Subject, &t.BodyHTML, &t.BodyText, &t.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return Template{}, fmt.Errorf("%w: %s version %d", ErrTemplateNotFound, id, version)
+		}
+		return Template{}, fmt.Errorf("failed to get template: %w", err)
+	}
+
+	return t, nil
+}
+
+// ListLatest returns the latest version of every template
+func (d *Database) ListLatest(ctx context.Context) ([]Template, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
+	return d.query(ctx,
+		`SELECT t.id, t.version, t.subject, t.body_html, t.body_text, t.created_at 
+		FROM templates t 
+		JOIN (SELECT tenant_id, id, MAX(version) AS version FROM templates WHERE `+tenantCond+` GROUP BY tenant_id, id) latest 
+		ON t.tenant_id = latest.tenant_id AND t.id = latest.id AND t.version = latest.version 
+		ORDER BY t.id`,
+		tenantArgs...,
+	)
+}
+
+// ListVersions returns every version of a template, oldest first
+func (d *Database) ListVersions(ctx context.Context, id string) ([]Template, error) {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := d.query(ctx,
+		`SELECT id, version, subject, body_html, body_text, created_at 
+		FROM templates 
+		WHERE id = ? AND `+tenantCond+` 
+		ORDER BY version`,
+		append([]any{id}, tenantArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+	}
+
+	return versions, nil
+}
+
+// Delete removes every version of a template
+func (d *Database) Delete(ctx context.Context, id string) error {
+	tenantCond, tenantArgs, err := tenantCondition(ctx, "tenant_id")
+	if err != nil {
+		return err
+	}
+
+	result, err := d.db.ExecContext(ctx,
+		`DELETE FROM templates WHERE id = ? AND `+tenantCond,
+		append([]any{id}, tenantArgs...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete template: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrTemplateNotFound, id)
+	}
+
+	return nil
+}
+
+func (d *Database) query(ctx context.Context, query string, args ...any) ([]Template, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []Template{}
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(&t.Id, &t.Version, &t.Subject, &t.BodyHTML, &t.BodyText, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan template row: %w", err)
+		}
+		templates = append(templates, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating template rows: %w", err)
+	}
+
+	return templates, nil
+}
+
+// isMySQLError checks if the error is a MySQL error with the given number
+func isMySQLError(err error, number uint16) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == number
+}
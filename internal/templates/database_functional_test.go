@@ -0,0 +1,191 @@
+package templates
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"multicarrier-email-api/internal/auth"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestDB(t *testing.T) *sql.DB {
+	host := os.Getenv("MYSQL_HOST")
+	port := os.Getenv("MYSQL_PORT")
+	user := os.Getenv("MYSQL_USER")
+	password := os.Getenv("MYSQL_PASSWORD")
+	database := os.Getenv("MYSQL_DATABASE")
+
+	if host == "" || user == "" || database == "" {
+		t.Skip("MySQL environment variables not set, skipping functional test")
+	}
+
+	if port == "" {
+		port = "3306"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, database)
+
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	require.NoError(t, db.Ping())
+
+	return db
+}
+
+func tenantContext(tenant string) context.Context {
+	return auth.NewContext(context.TODO(), auth.Principal{Tenant: tenant})
+}
+
+func TestTemplatesComponentWorkflow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db)
+	ctx := tenantContext("tenant-a")
+
+	id := "test-" + uuid.NewString()
+	defer func() {
+		_, _ = db.Exec("DELETE FROM templates WHERE id = ?", id)
+	}()
+
+	// updating a template that does not exist fails
+	_, err := sut.InsertVersion(ctx, Template{Id: id, Subject: "Welcome", BodyText: "Hi"}, true)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+
+	version, err := sut.InsertVersion(ctx, Template{Id: id, Subject: "Welcome", BodyText: "Hi"}, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	// creating it again fails
+	_, err = sut.InsertVersion(ctx, Template{Id: id, Subject: "Welcome", BodyText: "Hi"}, false)
+	require.ErrorIs(t, err, ErrTemplateExists)
+
+	version, err = sut.InsertVersion(ctx, Template{Id: id, Subject: "Welcome back", BodyHTML: "<p>Hi</p>"}, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+
+	latest, err := sut.Get(ctx, id, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, latest.Version)
+	require.Equal(t, "Welcome back", latest.Subject)
+
+	first, err := sut.Get(ctx, id, 1)
+	require.NoError(t, err)
+	require.Equal(t, "Hi", first.BodyText)
+
+	versions, err := sut.ListVersions(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	all, err := sut.ListLatest(ctx)
+	require.NoError(t, err)
+	found := false
+	for _, tmpl := range all {
+		if tmpl.Id == id {
+			found = true
+			require.Equal(t, 2, tmpl.Version)
+		}
+	}
+	require.True(t, found, "template should be listed with its latest version")
+
+	require.NoError(t, sut.Delete(ctx, id))
+	require.ErrorIs(t, sut.Delete(ctx, id), ErrTemplateNotFound)
+
+	_, err = sut.Get(ctx, id, 0)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestInsertVersion_ConcurrentCreates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db)
+	ctx := tenantContext("tenant-a")
+
+	id := "test-" + uuid.NewString()
+	defer func() {
+		_, _ = db.Exec("DELETE FROM templates WHERE id = ?", id)
+	}()
+
+	const creates = 4
+	errs := make([]error, creates)
+	var wg sync.WaitGroup
+	for i := range creates {
+		wg.Go(func() {
+			_, errs[i] = sut.InsertVersion(ctx, Template{Id: id, Subject: "Welcome", BodyText: "Hi"}, false)
+		})
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, ErrTemplateExists)
+	}
+	require.Equal(t, 1, created)
+}
+
+func TestTemplatesTenantIsolation(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db)
+	tenantA := tenantContext("tenant-a")
+	tenantB := tenantContext("tenant-b")
+
+	id := "test-" + uuid.NewString()
+	defer func() {
+		_, _ = db.Exec("DELETE FROM templates WHERE id = ?", id)
+	}()
+
+	_, err := sut.InsertVersion(tenantA, Template{Id: id, Subject: "Welcome A", BodyText: "Hi"}, false)
+	require.NoError(t, err)
+
+	// another tenant neither sees nor changes the template, and may use the same id
+	_, err = sut.Get(tenantB, id, 0)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+	_, err = sut.ListVersions(tenantB, id)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+	_, err = sut.InsertVersion(tenantB, Template{Id: id, Subject: "Welcome B", BodyText: "Hi"}, true)
+	require.ErrorIs(t, err, ErrTemplateNotFound)
+	require.ErrorIs(t, sut.Delete(tenantB, id), ErrTemplateNotFound)
+
+	all, err := sut.ListLatest(tenantB)
+	require.NoError(t, err)
+	for _, tmpl := range all {
+		require.NotEqual(t, id, tmpl.Id)
+	}
+
+	version, err := sut.InsertVersion(tenantB, Template{Id: id, Subject: "Welcome B", BodyText: "Hi"}, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+
+	latest, err := sut.Get(tenantA, id, 0)
+	require.NoError(t, err)
+	require.Equal(t, "Welcome A", latest.Subject)
+
+	_, err = sut.Get(context.TODO(), id, 0)
+	require.ErrorIs(t, err, ErrNoTenant)
+}
@@ -0,0 +1,269 @@
+package templates
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"multicarrier-email-api/internal/response"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var templateIdPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+type templateInput struct {
+	Id       string `json:"id" validate:"required,max=100,template_id"`
+	Subject  string `json:"subject" validate:"required"`
+	BodyHTML string `json:"body_html" validate:"required_without=BodyText"`
+	BodyText string `json:"body_text" validate:"required_without=BodyHTML"`
+}
+
+func newValidator() *validator.Validate {
+	validate := validator.New(validator.WithRequiredStructEnabled())
+	_ = validate.RegisterValidation("template_id", func(fl validator.FieldLevel) bool {
+		return templateIdPattern.MatchString(fl.Field().String())
+	})
+	return validate
+}
+
+// writeServiceError maps the errors of the template service to HTTP statuses
+func writeServiceError(w http.ResponseWriter, err error, action string) {
+	switch {
+	case errors.Is(err, ErrTemplateNotFound):
+		response.WriteError(http.StatusNotFound, w, "template not found")
+	case errors.Is(err, ErrTemplateExists):
+		response.WriteError(http.StatusConflict, w, "template already exists")
+	case errors.Is(err, ErrInvalidTemplate):
+		response.WriteError(http.StatusBadRequest, w, err.Error())
+	default:
+		slog.Error(fmt.Sprintf("error %s template: %v", action, err))
+		response.WriteError(http.StatusInternalServerError, w, fmt.Sprintf("error %s template", action))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error(fmt.Sprintf("error encoding response: %v", err))
+	}
+}
+
+// decodeTemplateInput reads and validates a template from the request body. The id is taken
+// from the path when present.
+func decodeTemplateInput(w http.ResponseWriter, r *http.Request) (Template, bool) {
+	var input templateInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error unmarshalling request body: %v", err))
+		return Template{}, false
+	}
+
+	if id := r.PathValue("id"); id != "" {
+		input.Id = id
+	}
+
+	if err := newValidator().Struct(input); err != nil {
+		response.WriteError(http.StatusBadRequest, w, fmt.Sprintf("error validating request body: %v", err))
+		return Template{}, false
+	}
+
+	return Template{
+		Id:       input.Id,
+		Subject:  input.Subject,
+		BodyHTML: input.BodyHTML,
+		BodyText: input.BodyText,
+	}, true
+}
+
+type createTemplateServiceInterface interface {
+	Create(ctx context.Context, t Template) (Template, error)
+}
+
+type CreateTemplateHandler struct {
+	templateService createTemplateServiceInterface
+}
+
+func NewCreateTemplateHandler(templateService createTemplateServiceInterface) *CreateTemplateHandler {
+	return &CreateTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *CreateTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := decodeTemplateInput(w, r)
+	if !ok {
+		return
+	}
+
+	created, err := h.templateService.Create(context.TODO(), t)
+	if err != nil {
+		writeServiceError(w, err, "creating")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, created)
+}
+
+type updateTemplateServiceInterface interface {
+	Update(ctx context.Context, t Template) (Template, error)
+}
+
+// UpdateTemplateHandler stores a new version of an existing template
+type UpdateTemplateHandler struct {
+	templateService updateTemplateServiceInterface
+}
+
+func NewUpdateTemplateHandler(templateService updateTemplateServiceInterface) *UpdateTemplateHandler {
+	return &UpdateTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *UpdateTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, ok := decodeTemplateInput(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := h.templateService.Update(context.TODO(), t)
+	if err != nil {
+		writeServiceError(w, err, "updating")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
+
+type getTemplateServiceInterface interface {
+	Get(ctx context.Context, id string, version int) (Template, error)
+}
+
+// GetTemplateHandler returns the latest version of a template, or the one in the version query parameter
+type GetTemplateHandler struct {
+	templateService getTemplateServiceInterface
+}
+
+func NewGetTemplateHandler(templateService getTemplateServiceInterface) *GetTemplateHandler {
+	return &GetTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *GetTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	version := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		var err error
+		version, err = strconv.Atoi(v)
+		if err != nil || version < 1 {
+			response.WriteError(http.StatusBadRequest, w, "version must be a positive integer")
+			return
+		}
+	}
+
+	t, err := h.templateService.Get(context.TODO(), id, version)
+	if err != nil {
+		writeServiceError(w, err, "getting")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+type listTemplatesServiceInterface interface {
+	List(ctx context.Context) ([]Template, error)
+}
+
+// ListTemplatesHandler returns the latest version of every template
+type ListTemplatesHandler struct {
+	templateService listTemplatesServiceInterface
+}
+
+func NewListTemplatesHandler(templateService listTemplatesServiceInterface) *ListTemplatesHandler {
+	return &ListTemplatesHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *ListTemplatesHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	templates, err := h.templateService.List(context.TODO())
+	if err != nil {
+		writeServiceError(w, err, "listing")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, templates)
+}
+
+type listTemplateVersionsServiceInterface interface {
+	ListVersions(ctx context.Context, id string) ([]Template, error)
+}
+
+type ListTemplateVersionsHandler struct {
+	templateService listTemplateVersionsServiceInterface
+}
+
+func NewListTemplateVersionsHandler(templateService listTemplateVersionsServiceInterface) *ListTemplateVersionsHandler {
+	return &ListTemplateVersionsHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *ListTemplateVersionsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	versions, err := h.templateService.ListVersions(context.TODO(), id)
+	if err != nil {
+		writeServiceError(w, err, "listing versions of")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+type deleteTemplateServiceInterface interface {
+	Delete(ctx context.Context, id string) error
+}
+
+// DeleteTemplateHandler removes every version of a template. Queued emails are not affected,
+// since they are stored already rendered.
+type DeleteTemplateHandler struct {
+	templateService deleteTemplateServiceInterface
+}
+
+func NewDeleteTemplateHandler(templateService deleteTemplateServiceInterface) *DeleteTemplateHandler {
+	return &DeleteTemplateHandler{
+		templateService: templateService,
+	}
+}
+
+func (h *DeleteTemplateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		response.WriteError(http.StatusBadRequest, w, "id parameter is required")
+		return
+	}
+
+	if err := h.templateService.Delete(context.TODO(), id); err != nil {
+		writeServiceError(w, err, "deleting")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
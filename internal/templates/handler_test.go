@@ -0,0 +1,303 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type templateServiceMock struct {
+	returnErr     error
+	calledVersion int
+	created       Template
+}
+
+func (m *templateServiceMock) Create(_ context.Context, t Template) (Template, error) {
+	m.created = t
+	t.Version = 1
+	t.CreatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return t, m.returnErr
+}
+
+func (m *templateServiceMock) Update(_ context.Context, t Template) (Template, error) {
+	m.created = t
+	t.Version = 2
+	t.CreatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	return t, m.returnErr
+}
+
+func (m *templateServiceMock) Get(_ context.Context, id string, version int) (Template, error) {
+	m.calledVersion = version
+	if version == 0 {
+		version = 3
+	}
+	return Template{Id: id, Version: version, Subject: "Welcome", BodyText: "Hi", CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}, m.returnErr
+}
+
+func (m *templateServiceMock) List(_ context.Context) ([]Template, error) {
+	return []Template{}, m.returnErr
+}
+
+func (m *templateServiceMock) ListVersions(_ context.Context, id string) ([]Template, error) {
+	return []Template{{Id: id, Version: 1, Subject: "Welcome", BodyText: "Hi", CreatedAt: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}}, m.returnErr
+}
+
+func (m *templateServiceMock) Delete(_ context.Context, _ string) error {
+	return m.returnErr
+}
+
+func TestCreateTemplateHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			body:               `{"id": "welcome", "subject": "Welcome {{.name}}", "body_text": "Hi {{.name}}"}`,
+			expectedStatusCode: http.StatusCreated,
+			expectedBody:       `{"id": "welcome", "version": 1, "subject": "Welcome {{.name}}", "body_text": "Hi {{.name}}", "created_at": "2024-01-01T12:00:00Z"}`,
+		},
+		{
+			name:               "invalid id",
+			body:               `{"id": "../welcome", "subject": "Welcome", "body_text": "Hi"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'templateInput.Id' Error:Field validation for 'Id' failed on the 'template_id' tag"}`,
+		},
+		{
+			name:               "missing bodies",
+			body:               `{"id": "welcome", "subject": "Welcome"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "error validating request body: Key: 'templateInput.BodyHTML' Error:Field validation for 'BodyHTML' failed on the 'required_without' tag\nKey: 'templateInput.BodyText' Error:Field validation for 'BodyText' failed on the 'required_without' tag"}`,
+		},
+		{
+			name:               "invalid template",
+			serviceErr:         fmt.Errorf("%w: subject: template: subject:1: unclosed action", ErrInvalidTemplate),
+			body:               `{"id": "welcome", "subject": "Welcome {{.name", "body_text": "Hi"}`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "invalid template: subject: template: subject:1: unclosed action"}`,
+		},
+		{
+			name:               "already exists",
+			serviceErr:         fmt.Errorf("%w: welcome", ErrTemplateExists),
+			body:               `{"id": "welcome", "subject": "Welcome", "body_text": "Hi"}`,
+			expectedStatusCode: http.StatusConflict,
+			expectedBody:       `{"error": "template already exists"}`,
+		},
+		{
+			name:               "service error",
+			serviceErr:         errors.New("mock error"),
+			body:               `{"id": "welcome", "subject": "Welcome", "body_text": "Hi"}`,
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error creating template"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/templates", strings.NewReader(tc.body))
+			response := httptest.NewRecorder()
+
+			service := &templateServiceMock{returnErr: tc.serviceErr}
+			sut := NewCreateTemplateHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+		})
+	}
+}
+
+func TestUpdateTemplateHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"id": "welcome", "version": 2, "subject": "Welcome back", "body_html": "<p>Hi</p>", "created_at": "2024-01-01T12:00:00Z"}`,
+		},
+		{
+			name:               "not found",
+			serviceErr:         fmt.Errorf("%w: welcome", ErrTemplateNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "template not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPut, "/templates/welcome", strings.NewReader(`{"subject": "Welcome back", "body_html": "<p>Hi</p>"}`))
+			request.SetPathValue("id", "welcome")
+			response := httptest.NewRecorder()
+
+			service := &templateServiceMock{returnErr: tc.serviceErr}
+			sut := NewUpdateTemplateHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			assert.Equal(t, "welcome", service.created.Id, "the id is taken from the path")
+		})
+	}
+}
+
+func TestGetTemplateHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		query              string
+		expectedStatusCode int
+		expectedVersion    int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "latest version",
+			query:              "",
+			expectedStatusCode: http.StatusOK,
+			expectedVersion:    0,
+			expectedBody:       `{"id": "welcome", "version": 3, "subject": "Welcome", "body_text": "Hi", "created_at": "2024-01-01T12:00:00Z"}`,
+		},
+		{
+			name:               "pinned version",
+			query:              "?version=2",
+			expectedStatusCode: http.StatusOK,
+			expectedVersion:    2,
+			expectedBody:       `{"id": "welcome", "version": 2, "subject": "Welcome", "body_text": "Hi", "created_at": "2024-01-01T12:00:00Z"}`,
+		},
+		{
+			name:               "invalid version",
+			query:              "?version=latest",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "version must be a positive integer"}`,
+		},
+		{
+			name:               "not found",
+			serviceErr:         fmt.Errorf("%w: welcome version 0", ErrTemplateNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "template not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/templates/welcome"+tc.query, nil)
+			request.SetPathValue("id", "welcome")
+			response := httptest.NewRecorder()
+
+			service := &templateServiceMock{returnErr: tc.serviceErr}
+			sut := NewGetTemplateHandler(service)
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.Equal(t, tc.expectedVersion, service.calledVersion)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+		})
+	}
+}
+
+func TestListTemplateVersionsHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	request := httptest.NewRequest(http.MethodGet, "/templates/welcome/versions", nil)
+	request.SetPathValue("id", "welcome")
+	response := httptest.NewRecorder()
+
+	sut := NewListTemplateVersionsHandler(&templateServiceMock{})
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `[{"id": "welcome", "version": 1, "subject": "Welcome", "body_text": "Hi", "created_at": "2024-01-01T12:00:00Z"}]`, response.Body.String())
+}
+
+func TestListTemplatesHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	request := httptest.NewRequest(http.MethodGet, "/templates", nil)
+	response := httptest.NewRecorder()
+
+	sut := NewListTemplatesHandler(&templateServiceMock{})
+
+	sut.ServeHTTP(response, request)
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `[]`, response.Body.String())
+}
+
+func TestDeleteTemplateHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		expectedStatusCode int
+		expectedBody       string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "success",
+			expectedStatusCode: http.StatusNoContent,
+			expectedBody:       "",
+		},
+		{
+			name:               "not found",
+			serviceErr:         fmt.Errorf("%w: welcome", ErrTemplateNotFound),
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "template not found"}`,
+		},
+		{
+			name:               "service error",
+			serviceErr:         errors.New("mock error"),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error deleting template"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodDelete, "/templates/welcome", nil)
+			request.SetPathValue("id", "welcome")
+			response := httptest.NewRecorder()
+
+			sut := NewDeleteTemplateHandler(&templateServiceMock{returnErr: tc.serviceErr})
+
+			sut.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			if tc.expectedBody == "" {
+				assert.Empty(t, response.Body.String())
+			} else {
+				assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			}
+		})
+	}
+}
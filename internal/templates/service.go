@@ -0,0 +1,162 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+var (
+	ErrInvalidTemplate  = errors.New("invalid template")
+	ErrInvalidVariables = errors.New("variables do not satisfy the template")
+)
+
+type databaseInterface interface {
+	InsertVersion(ctx context.Context, t Template, mustExist bool) (int, error)
+	Get(ctx context.Context, id string, version int) (Template, error)
+	ListLatest(ctx context.Context) ([]Template, error)
+	ListVersions(ctx context.Context, id string) ([]Template, error)
+	Delete(ctx context.Context, id string) error
+}
+
+type Service struct {
+	db databaseInterface
+}
+
+func NewService(db databaseInterface) *Service {
+	return &Service{
+		db: db,
+	}
+}
+
+// compiled holds the parsed sources of a template version. Missing variables are execution errors.
+type compiled struct {
+	subject  *texttemplate.Template
+	bodyHTML *htmltemplate.Template
+	bodyText *texttemplate.Template
+}
+
+func compile(t Template) (*compiled, error) {
+	c := &compiled{}
+	var err error
+
+	if c.subject, err = texttemplate.New("subject").Option("missingkey=error").Parse(t.Subject); err != nil {
+		return nil, fmt.Errorf("%w: subject: %w", ErrInvalidTemplate, err)
+	}
+
+	if t.BodyHTML != "" {
+		if c.bodyHTML, err = htmltemplate.New("body_html").Option("missingkey=error").Parse(t.BodyHTML); err != nil {
+			return nil, fmt.Errorf("%w: body_html: %w", ErrInvalidTemplate, err)
+		}
+	}
+
+	if t.BodyText != "" {
+		if c.bodyText, err = texttemplate.New("body_text").Option("missingkey=error").Parse(t.BodyText); err != nil {
+			return nil, fmt.Errorf("%w: body_text: %w", ErrInvalidTemplate, err)
+		}
+	}
+
+	return c, nil
+}
+
+func execute(name string, execute func(buf *bytes.Buffer) error) (string, error) {
+	var buf bytes.Buffer
+	if err := execute(&buf); err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrInvalidVariables, name, err)
+	}
+	return buf.String(), nil
+}
+
+func (c *compiled) render(variables map[string]any) (Rendered, error) {
+	var rendered Rendered
+	var err error
+
+	rendered.Subject, err = execute("subject", func(buf *bytes.Buffer) error { return c.subject.Execute(buf, variables) })
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	if strings.ContainsAny(rendered.Subject, "\r\n") {
+		return Rendered{}, fmt.Errorf("%w: subject: line breaks are not allowed", ErrInvalidVariables)
+	}
+
+	if c.bodyHTML != nil {
+		rendered.BodyHTML, err = execute("body_html", func(buf *bytes.Buffer) error { return c.bodyHTML.Execute(buf, variables) })
+		if err != nil {
+			return Rendered{}, err
+		}
+	}
+
+	if c.bodyText != nil {
+		rendered.BodyText, err = execute("body_text", func(buf *bytes.Buffer) error { return c.bodyText.Execute(buf, variables) })
+		if err != nil {
+			return Rendered{}, err
+		}
+	}
+
+	return rendered, nil
+}
+
+func (s *Service) Create(ctx context.Context, t Template) (Template, error) {
+	return s.insertVersion(ctx, t, false)
+}
+
+// Update stores the template as a new version of an existing id
+func (s *Service) Update(ctx context.Context, t Template) (Template, error) {
+	return s.insertVersion(ctx, t, true)
+}
+
+func (s *Service) insertVersion(ctx context.Context, t Template, mustExist bool) (Template, error) {
+	if _, err := compile(t); err != nil {
+		return Template{}, err
+	}
+
+	version, err := s.db.InsertVersion(ctx, t, mustExist)
+	if err != nil {
+		return Template{}, err
+	}
+
+	return s.db.Get(ctx, t.Id, version)
+}
+
+func (s *Service) Get(ctx context.Context, id string, version int) (Template, error) {
+	return s.db.Get(ctx, id, version)
+}
+
+func (s *Service) List(ctx context.Context) ([]Template, error) {
+	return s.db.ListLatest(ctx)
+}
+
+func (s *Service) ListVersions(ctx context.Context, id string) ([]Template, error) {
+	return s.db.ListVersions(ctx, id)
+}
+
+func (s *Service) Delete(ctx context.Context, id string) error {
+	return s.db.Delete(ctx, id)
+}
+
+// Render executes the given version of a template, or the latest one when version is 0.
+// The returned Rendered carries the resolved version so that the email can pin it.
+func (s *Service) Render(ctx context.Context, id string, version int, variables map[string]any) (Rendered, error) {
+	t, err := s.db.Get(ctx, id, version)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	c, err := compile(t)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	rendered, err := c.render(variables)
+	if err != nil {
+		return Rendered{}, err
+	}
+
+	rendered.Version = t.Version
+	return rendered, nil
+}
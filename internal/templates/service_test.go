@@ -0,0 +1,253 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type databaseMock struct {
+	templates          map[string][]Template
+	insertVersionCalls int
+	returnErr          error
+}
+
+func newDatabaseMock(templates ...Template) *databaseMock {
+	m := &databaseMock{templates: map[string][]Template{}}
+	for _, t := range templates {
+		m.templates[t.Id] = append(m.templates[t.Id], t)
+	}
+	return m
+}
+
+func (m *databaseMock) InsertVersion(_ context.Context, t Template, mustExist bool) (int, error) {
+	m.insertVersionCalls++
+
+	if m.returnErr != nil {
+		return 0, m.returnErr
+	}
+
+	versions := m.templates[t.Id]
+	if mustExist && len(versions) == 0 {
+		return 0, ErrTemplateNotFound
+	}
+	if !mustExist && len(versions) > 0 {
+		return 0, ErrTemplateExists
+	}
+
+	t.Version = len(versions) + 1
+	t.CreatedAt = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.templates[t.Id] = append(versions, t)
+	return t.Version, nil
+}
+
+func (m *databaseMock) Get(_ context.Context, id string, version int) (Template, error) {
+	if m.returnErr != nil {
+		return Template{}, m.returnErr
+	}
+
+	versions := m.templates[id]
+	if version == 0 && len(versions) > 0 {
+		return versions[len(versions)-1], nil
+	}
+	for _, t := range versions {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("%w: %s version %d", ErrTemplateNotFound, id, version)
+}
+
+func (m *databaseMock) ListLatest(_ context.Context) ([]Template, error) {
+	return nil, m.returnErr
+}
+
+func (m *databaseMock) ListVersions(_ context.Context, id string) ([]Template, error) {
+	return m.templates[id], m.returnErr
+}
+
+func (m *databaseMock) Delete(_ context.Context, _ string) error {
+	return m.returnErr
+}
+
+func TestService_Create(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name               string
+		template           Template
+		existing           []Template
+		expectedErr        error
+		expectedInsertCall int
+	}{
+		{
+			name:               "valid template",
+			template:           Template{Id: "welcome", Subject: "Welcome {{.name}}", BodyHTML: "<p>Hi {{.name}}</p>", BodyText: "Hi {{.name}}"},
+			expectedInsertCall: 1,
+		},
+		{
+			name:               "invalid subject",
+			template:           Template{Id: "welcome", Subject: "Welcome {{.name", BodyText: "Hi"},
+			expectedErr:        ErrInvalidTemplate,
+			expectedInsertCall: 0,
+		},
+		{
+			name:               "invalid html body",
+			template:           Template{Id: "welcome", Subject: "Welcome", BodyHTML: "<p>{{if .name}}</p>"},
+			expectedErr:        ErrInvalidTemplate,
+			expectedInsertCall: 0,
+		},
+		{
+			name:               "already exists",
+			template:           Template{Id: "welcome", Subject: "Welcome", BodyText: "Hi"},
+			existing:           []Template{{Id: "welcome", Version: 1, Subject: "Welcome", BodyText: "Hi"}},
+			expectedErr:        ErrTemplateExists,
+			expectedInsertCall: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			database := newDatabaseMock(tc.existing...)
+			sut := NewService(database)
+
+			created, err := sut.Create(context.TODO(), tc.template)
+
+			assert.Equal(t, tc.expectedInsertCall, database.insertVersionCalls)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, 1, created.Version)
+			assert.Equal(t, tc.template.Subject, created.Subject)
+		})
+	}
+}
+
+func TestService_Update(t *testing.T) {
+	t.Parallel()
+
+	database := newDatabaseMock(Template{Id: "welcome", Version: 1, Subject: "Welcome", BodyText: "Hi"})
+	sut := NewService(database)
+
+	updated, err := sut.Update(context.TODO(), Template{Id: "welcome", Subject: "Welcome back", BodyText: "Hi again"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, updated.Version)
+	assert.Equal(t, "Welcome back", updated.Subject)
+
+	_, err = sut.Update(context.TODO(), Template{Id: "missing", Subject: "Welcome", BodyText: "Hi"})
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestService_Render(t *testing.T) {
+	t.Parallel()
+
+	database := newDatabaseMock(
+		Template{
+			Id:       "invoice",
+			Version:  1,
+			Subject:  "Invoice {{.number}}",
+			BodyHTML: "<p>Dear {{.customer.name}}, invoice {{.number}} is attached</p>",
+			BodyText: "Dear {{.customer.name}}, invoice {{.number}} is attached",
+		},
+		Template{
+			Id:       "invoice",
+			Version:  2,
+			Subject:  "Your invoice {{.number}}",
+			BodyText: "Invoice {{.number}}",
+		},
+	)
+
+	testCases := []struct {
+		name        string
+		version     int
+		variables   map[string]any
+		expected    Rendered
+		expectedErr error
+	}{
+		{
+			name:      "pinned version",
+			version:   1,
+			variables: map[string]any{"number": "2024/001", "customer": map[string]any{"name": "<Jane>"}},
+			expected: Rendered{
+				Version:  1,
+				Subject:  "Invoice 2024/001",
+				BodyHTML: "<p>Dear &lt;Jane&gt;, invoice 2024/001 is attached</p>",
+				BodyText: "Dear <Jane>, invoice 2024/001 is attached",
+			},
+		},
+		{
+			name:      "latest version",
+			version:   0,
+			variables: map[string]any{"number": "2024/002"},
+			expected: Rendered{
+				Version:  2,
+				Subject:  "Your invoice 2024/002",
+				BodyText: "Invoice 2024/002",
+			},
+		},
+		{
+			name:        "missing variable",
+			version:     1,
+			variables:   map[string]any{"number": "2024/001"},
+			expectedErr: ErrInvalidVariables,
+		},
+		{
+			name:        "no variables",
+			version:     2,
+			variables:   nil,
+			expectedErr: ErrInvalidVariables,
+		},
+		{
+			name:        "line break in subject",
+			version:     2,
+			variables:   map[string]any{"number": "1\r\nBcc: victim@example.com"},
+			expectedErr: ErrInvalidVariables,
+		},
+		{
+			name:        "unknown version",
+			version:     3,
+			variables:   map[string]any{"number": "2024/001"},
+			expectedErr: ErrTemplateNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			sut := NewService(database)
+
+			rendered, err := sut.Render(context.TODO(), "invoice", tc.version, tc.variables)
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, rendered)
+		})
+	}
+}
+
+func TestService_Render_DatabaseError(t *testing.T) {
+	t.Parallel()
+
+	database := newDatabaseMock()
+	database.returnErr = errors.New("mock error")
+	sut := NewService(database)
+
+	_, err := sut.Render(context.TODO(), "invoice", 0, nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInvalidVariables)
+	assert.NotErrorIs(t, err, ErrTemplateNotFound)
+}
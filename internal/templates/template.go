@@ -0,0 +1,22 @@
+package templates
+
+import "time"
+
+// Template is a version of a stored email template. Subject and BodyText are text/template
+// sources, BodyHTML is an html/template source.
+type Template struct {
+	Id        string    `json:"id"`
+	Version   int       `json:"version"`
+	Subject   string    `json:"subject"`
+	BodyHTML  string    `json:"body_html,omitempty"`
+	BodyText  string    `json:"body_text,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rendered is the output of a template version executed with a set of variables
+type Rendered struct {
+	Version  int
+	Subject  string
+	BodyHTML string
+	BodyText string
+}
@@ -0,0 +1,71 @@
+package unsubscribe
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"multicarrier-email-api/internal/mailbox"
+)
+
+type Database struct {
+	db *sql.DB
+}
+
+func NewDatabase(db *sql.DB) *Database {
+	return &Database{
+		db: db,
+	}
+}
+
+// suppressionReason is the reason of the suppressions recorded for opt-outs
+const suppressionReason = "manual"
+
+// Record stores the opt-out of the recipients of the claims, together with a manual suppression of
+// each recipient for the tenant, so that intake rejects later emails to them. Both are written in a
+// single transaction. Repeated opt-outs are ignored.
+func (d *Database) Record(ctx context.Context, claims Claims) error {
+	// an empty tenant_id would suppress the recipients for every tenant
+	if claims.Tenant == "" {
+		return fmt.Errorf("%w: no tenant", ErrInvalidToken)
+	}
+
+	placeholders := make([]string, len(claims.Recipients))
+	args := make([]any, 0, len(claims.Recipients)*3)
+	suppressionArgs := make([]any, 0, len(claims.Recipients)*3)
+	for i, recipient := range claims.Recipients {
+		placeholders[i] = "(?, ?, ?)"
+		args = append(args, claims.Tenant, claims.EmailId, strings.ToLower(recipient))
+		// addresses are normalized as the suppressions handler does, with punycode domains
+		suppressionArgs = append(suppressionArgs, claims.Tenant, strings.ToLower(mailbox.ToASCII(strings.TrimSpace(recipient))), suppressionReason)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT IGNORE INTO unsubscriptions (tenant_id, email_id, recipient) VALUES `+strings.Join(placeholders, ", "),
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert unsubscriptions: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT IGNORE INTO suppressions (tenant_id, address, reason) VALUES `+strings.Join(placeholders, ", "),
+		suppressionArgs...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert suppressions: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
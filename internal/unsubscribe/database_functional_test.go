@@ -0,0 +1,84 @@
+package unsubscribe
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestDB(t *testing.T) *sql.DB {
+	host := os.Getenv("MYSQL_HOST")
+	port := os.Getenv("MYSQL_PORT")
+	user := os.Getenv("MYSQL_USER")
+	password := os.Getenv("MYSQL_PASSWORD")
+	database := os.Getenv("MYSQL_DATABASE")
+
+	if host == "" || user == "" || database == "" {
+		t.Skip("MySQL environment variables not set, skipping functional test")
+	}
+
+	if port == "" {
+		port = "3306"
+	}
+
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true", user, password, host, port, database)
+
+	db, err := sql.Open("mysql", dsn)
+	require.NoError(t, err)
+	require.NoError(t, db.Ping())
+
+	return db
+}
+
+func TestRecordUnsubscription(t *testing.T) {
+	if testing.Short() {
+		t.Skip("component tests are skipped in short mode")
+	}
+
+	db := getTestDB(t)
+	defer db.Close()
+
+	sut := NewDatabase(db)
+	ctx := context.TODO()
+
+	emailId := uuid.NewString()
+	tenant := "tenant-" + uuid.NewString()
+	defer func() {
+		_, _ = db.Exec("DELETE FROM unsubscriptions WHERE email_id = ?", emailId)
+		_, _ = db.Exec("DELETE FROM suppressions WHERE tenant_id = ?", tenant)
+	}()
+
+	claims := Claims{Tenant: tenant, EmailId: emailId, Recipients: []string{"First@example.com", "second@bücher.example"}}
+
+	require.NoError(t, sut.Record(ctx, claims))
+	// a second click of the same link is not an error
+	require.NoError(t, sut.Record(ctx, claims))
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM unsubscriptions WHERE email_id = ? AND tenant_id = ?", emailId, tenant).Scan(&count))
+	require.Equal(t, 2, count)
+
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM unsubscriptions WHERE email_id = ? AND recipient = ?", emailId, "first@example.com").Scan(&count))
+	require.Equal(t, 1, count)
+
+	// the opt-outs are enforced as manual suppressions of the tenant
+	var addresses []string
+	rows, err := db.Query("SELECT address FROM suppressions WHERE tenant_id = ? AND reason = 'manual' ORDER BY address", tenant)
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var address string
+		require.NoError(t, rows.Scan(&address))
+		addresses = append(addresses, address)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, []string{"first@example.com", "second@xn--bcher-kva.example"}, addresses)
+
+	require.ErrorIs(t, sut.Record(ctx, Claims{EmailId: emailId, Recipients: []string{"first@example.com"}}), ErrInvalidToken)
+}
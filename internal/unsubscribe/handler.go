@@ -0,0 +1,62 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"multicarrier-email-api/internal/response"
+)
+
+// oneClickBody is the form value mailbox providers post to one-click links (RFC 8058 section 3.2)
+const oneClickBody = "One-Click"
+
+// maxFormBytes bounds the one-click request bodies, which hold a single short form field
+const maxFormBytes = 4 << 10
+
+type unsubscribeServiceInterface interface {
+	Unsubscribe(ctx context.Context, token string) error
+}
+
+// UnsubscribeHandler serves the one-click links of List-Unsubscribe headers. It is called by
+// mailbox providers, so it is not authenticated: the signed token is the credential.
+type UnsubscribeHandler struct {
+	unsubscribeService unsubscribeServiceInterface
+}
+
+func NewUnsubscribeHandler(unsubscribeService unsubscribeServiceInterface) *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		unsubscribeService: unsubscribeService,
+	}
+}
+
+// ServeHTTP accepts the one-click body as application/x-www-form-urlencoded or multipart/form-data,
+// as RFC 8058 section 3.1 allows both
+func (h *UnsubscribeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	err := r.ParseMultipartForm(maxFormBytes)
+	if errors.Is(err, http.ErrNotMultipart) {
+		// url-encoded bodies are parsed before the multipart check
+		err = nil
+	}
+	if err != nil || r.PostForm.Get("List-Unsubscribe") != oneClickBody {
+		response.WriteError(http.StatusBadRequest, w, "request body must be List-Unsubscribe=One-Click")
+		return
+	}
+
+	if err := h.unsubscribeService.Unsubscribe(r.Context(), r.PathValue("token")); err != nil {
+		if errors.Is(err, ErrInvalidToken) {
+			response.WriteError(http.StatusNotFound, w, "unsubscribe link not found")
+			return
+		}
+		slog.Error(fmt.Sprintf("error recording unsubscription: %v", err))
+		response.WriteError(http.StatusInternalServerError, w, "error recording unsubscription")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("{}"))
+}
@@ -0,0 +1,106 @@
+package unsubscribe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type unsubscribeServiceMock struct {
+	returnErr   error
+	calledToken string
+}
+
+func (m *unsubscribeServiceMock) Unsubscribe(_ context.Context, token string) error {
+	m.calledToken = token
+	return m.returnErr
+}
+
+func TestUnsubscribeHandler_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	type caseStruct struct {
+		name               string
+		serviceErr         error
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedBody       string
+		expectedToken      string
+	}
+
+	testCases := []caseStruct{
+		{
+			name:               "one-click",
+			body:               "List-Unsubscribe=One-Click",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{}`,
+			expectedToken:      "abc.def",
+		},
+		{
+			name:               "multipart one-click",
+			contentType:        "multipart/form-data; boundary=boundary",
+			body:               "--boundary\r\nContent-Disposition: form-data; name=\"List-Unsubscribe\"\r\n\r\nOne-Click\r\n--boundary--\r\n",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{}`,
+			expectedToken:      "abc.def",
+		},
+		{
+			name:               "multipart without one-click field",
+			contentType:        "multipart/form-data; boundary=boundary",
+			body:               "--boundary\r\nContent-Disposition: form-data; name=\"other\"\r\n\r\nOne-Click\r\n--boundary--\r\n",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "request body must be List-Unsubscribe=One-Click"}`,
+		},
+		{
+			name:               "missing one-click body",
+			body:               "",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `{"error": "request body must be List-Unsubscribe=One-Click"}`,
+		},
+		{
+			name:               "invalid token",
+			serviceErr:         ErrInvalidToken,
+			body:               "List-Unsubscribe=One-Click",
+			expectedStatusCode: http.StatusNotFound,
+			expectedBody:       `{"error": "unsubscribe link not found"}`,
+			expectedToken:      "abc.def",
+		},
+		{
+			name:               "database error",
+			serviceErr:         errors.New("mock error"),
+			body:               "List-Unsubscribe=One-Click",
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedBody:       `{"error": "error recording unsubscription"}`,
+			expectedToken:      "abc.def",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := &unsubscribeServiceMock{returnErr: tc.serviceErr}
+
+			mux := http.NewServeMux()
+			mux.Handle("POST /unsubscribe/{token}", NewUnsubscribeHandler(service))
+
+			request := httptest.NewRequest(http.MethodPost, "/unsubscribe/abc.def", strings.NewReader(tc.body))
+			contentType := tc.contentType
+			if contentType == "" {
+				contentType = "application/x-www-form-urlencoded"
+			}
+			request.Header.Set("Content-Type", contentType)
+			response := httptest.NewRecorder()
+
+			mux.ServeHTTP(response, request)
+
+			assert.Equal(t, tc.expectedStatusCode, response.Code)
+			assert.JSONEq(t, tc.expectedBody, response.Body.String())
+			assert.Equal(t, tc.expectedToken, service.calledToken)
+		})
+	}
+}
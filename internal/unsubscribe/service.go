@@ -0,0 +1,45 @@
+package unsubscribe
+
+import (
+	"context"
+	"strings"
+)
+
+type databaseInterface interface {
+	Record(ctx context.Context, claims Claims) error
+}
+
+type Service struct {
+	signer  *Signer
+	db      databaseInterface
+	baseURL string
+}
+
+// NewService serves one-click unsubscribe links under the given public base URL of the API
+func NewService(signer *Signer, db databaseInterface, baseURL string) *Service {
+	return &Service{
+		signer:  signer,
+		db:      db,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// OneClickURL returns the RFC 8058 link recording the opt-out of the recipients of the claims
+func (s *Service) OneClickURL(claims Claims) (string, error) {
+	token, err := s.signer.Sign(claims)
+	if err != nil {
+		return "", err
+	}
+
+	return s.baseURL + "/unsubscribe/" + token, nil
+}
+
+// Unsubscribe records the opt-out of a one-click link, returning ErrInvalidToken for forged or altered links
+func (s *Service) Unsubscribe(ctx context.Context, token string) error {
+	claims, err := s.signer.Verify(token)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Record(ctx, claims)
+}
@@ -0,0 +1,39 @@
+package unsubscribe
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type databaseMock struct {
+	recorded []Claims
+}
+
+func (m *databaseMock) Record(_ context.Context, claims Claims) error {
+	m.recorded = append(m.recorded, claims)
+	return nil
+}
+
+func TestService_OneClickURLAndUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	db := &databaseMock{}
+	sut := NewService(NewSigner([]byte("secret")), db, "https://mail-api.example.com/")
+
+	claims := Claims{Tenant: "tenant-a", EmailId: "65ed6bfa-063c-5219-844d-e099c88a17f4", Recipients: []string{"user@example.com"}}
+
+	link, err := sut.OneClickURL(claims)
+	assert.NoError(t, err)
+
+	token, found := strings.CutPrefix(link, "https://mail-api.example.com/unsubscribe/")
+	assert.True(t, found, link)
+
+	assert.NoError(t, sut.Unsubscribe(context.TODO(), token))
+	assert.Equal(t, []Claims{claims}, db.recorded)
+
+	assert.ErrorIs(t, sut.Unsubscribe(context.TODO(), token+"x"), ErrInvalidToken)
+	assert.Len(t, db.recorded, 1)
+}
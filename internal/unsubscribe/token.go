@@ -0,0 +1,77 @@
+package unsubscribe
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+// Claims identify the recipients opting out through a one-click unsubscribe link
+type Claims struct {
+	Tenant     string   `json:"t"`
+	EmailId    string   `json:"e"`
+	Recipients []string `json:"r"`
+}
+
+// Signer issues and verifies unsubscribe tokens. A token is the base64url encoded claims followed
+// by their HMAC-SHA256, so that links cannot be forged or altered for other recipients.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+func (s *Signer) mac(encodedClaims string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(encodedClaims))
+	return h.Sum(nil)
+}
+
+// Sign returns the token of the claims. The same claims always give the same token.
+func (s *Signer) Sign(claims Claims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode unsubscribe claims: %w", err)
+	}
+
+	encodedClaims := base64.RawURLEncoding.EncodeToString(data)
+	signature := base64.RawURLEncoding.EncodeToString(s.mac(encodedClaims))
+
+	return encodedClaims + "." + signature, nil
+}
+
+// Verify returns the claims of a token signed with the key of the signer
+func (s *Signer) Verify(token string) (Claims, error) {
+	encodedClaims, encodedSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, s.mac(encodedClaims)) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encodedClaims)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Tenant == "" || claims.EmailId == "" || len(claims.Recipients) == 0 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,42 @@
+package unsubscribe
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSigner(t *testing.T) {
+	t.Parallel()
+
+	sut := NewSigner([]byte("secret"))
+	claims := Claims{Tenant: "tenant-a", EmailId: "65ed6bfa-063c-5219-844d-e099c88a17f4", Recipients: []string{"user@example.com"}}
+
+	token, err := sut.Sign(claims)
+	assert.NoError(t, err)
+
+	again, err := sut.Sign(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, token, again, "tokens must be deterministic so that payload hashes are stable on retries")
+
+	verified, err := sut.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, claims, verified)
+
+	forged, err := sut.Sign(Claims{Tenant: "tenant-a", EmailId: claims.EmailId, Recipients: []string{"other@example.com"}})
+	assert.NoError(t, err)
+	encodedClaims, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+
+	_, err = sut.Verify(encodedClaims + "." + signature)
+	assert.ErrorIs(t, err, ErrInvalidToken, "claims of another token must not verify")
+
+	_, err = NewSigner([]byte("other-secret")).Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken, "tokens of another key must not verify")
+
+	for _, malformed := range []string{"", "no-dot", ".", "e30.", token + "x"} {
+		_, err = sut.Verify(malformed)
+		assert.ErrorIs(t, err, ErrInvalidToken, malformed)
+	}
+}